@@ -102,7 +102,7 @@ require (
 	golang.org/x/net v0.20.0 // indirect
 	golang.org/x/oauth2 v0.16.0
 	golang.org/x/sys v0.16.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/text v0.14.0
 	google.golang.org/api v0.157.0
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/grpc v1.60.1 // indirect
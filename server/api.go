@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -62,22 +64,24 @@ func (a *APIServer) googleCallback(ctx *gin.Context) {
 }
 
 func (a *APIServer) newPoll(ctx *gin.Context, accountID int64) {
-	pollsNumber, err := CountPolls(ctx, a.db, accountID)
+	poll := Poll{}
+	err := readBody(ctx, &poll)
 	if err != nil {
-		logger.Error("failed to retrieve poll count", zap.Any("error", err))
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "invalid request payload"})
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
-	if pollsNumber >= MAX_POLLS_PER_ACCOUNT {
-		logger.Error("Poll limit reached", zap.Int64("accountID", accountID))
-		ctx.AbortWithStatusJSON(http.StatusTeapot, gin.H{"error": fmt.Sprintf("poll limit of %d reached. delete some polls before creating a new one.", MAX_POLLS_PER_ACCOUNT)})
+
+	if !a.checkPollQuota(ctx, accountID, poll.WorkspaceID) {
 		return
 	}
 
-	poll := Poll{}
-	err = readBody(ctx, &poll)
+	workspace, err := a.getPollWorkspace(ctx, poll, accountID)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if err := workspace.Settings.ValidatePollAnswerScale(poll.AnswerScale); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -90,35 +94,25 @@ func (a *APIServer) newPoll(ctx *gin.Context, accountID int64) {
 	ctx.JSON(http.StatusOK, gin.H{"data": createdPoll})
 }
 
-func (a *APIServer) listPolls(ctx *gin.Context, accountID int64) {
-	polls, err := ListPolls(ctx, a.db, accountID)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+func (a *APIServer) getPoll(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"data": polls})
-}
-
-func (a *APIServer) getPoll(ctx *gin.Context) {
-	pollID := ctx.Params.ByName("id")
-	if pollID == "" {
-		logger.Error("invalid poll id")
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+	availabilities, err := ListVotes(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
 		return
 	}
 
-	poll, err := GetPoll(ctx, a.db, pollID)
+	rsvps, err := ListPollRSVPs(ctx, a.db, poll.ID)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
 		return
 	}
-	if reflect.ValueOf(poll).IsZero() {
-		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
-		return
-	}
 
-	availabilities, err := ListVotes(ctx, a.db, pollID)
+	locales, err := ListPollHolidayLocales(ctx, a.db, poll)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
 		return
@@ -127,6 +121,8 @@ func (a *APIServer) getPoll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"data": map[string]interface{}{
 		"poll":           poll,
 		"availabilities": availabilities,
+		"rsvps":          rsvps,
+		"holidays":       FlagHolidayOptions(poll.Options, locales),
 	}})
 }
 
@@ -147,6 +143,120 @@ func (a *APIServer) deletePoll(ctx *gin.Context, accountID int64) {
 	ctx.JSON(http.StatusOK, gin.H{"data": poll})
 }
 
+type FinalizePollRequest struct {
+	OptionID string `json:"option_id"`
+}
+
+func (a *APIServer) finalizePoll(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	request := FinalizePollRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if request.OptionID != "" && !slices.ContainsFunc(poll.Options, func(option PollOption) bool { return option.ID == request.OptionID }) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid option id"})
+		return
+	}
+
+	finalizedPoll, err := FinalizePoll(ctx, a.db, accountID, poll.ID, request.OptionID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": finalizedPoll})
+}
+
+// getPollICS returns the calendar invite of a finalized poll for the invitees and participants. With the "invite"
+// parameter it is the calendar invite of that invitee, whose replies are recorded as RSVPs. Its organizer is the reply
+// address of the invite, so only editors get it.
+func (a *APIServer) getPollICS(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollViewer)
+	if !ok {
+		return
+	}
+
+	organizer, err := GetAccountByID(ctx, a.db, poll.AccountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	if rawInviteID := ctx.Query("invite"); rawInviteID != "" {
+		role, err := GetPollRole(ctx, a.db, poll.ID, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if !HasPollRole(role, PollEditor) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+			return
+		}
+
+		inviteID, err := strconv.ParseInt(rawInviteID, 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter invite"})
+			return
+		}
+		invite, err := GetPollInvite(ctx, a.db, inviteID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if invite.ID == 0 || invite.PollID != poll.ID {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+			return
+		}
+
+		ics, ok := FinalizedPollICS(poll, organizer, inviteReplyAddress(invite), []string{invite.Email}, time.Now())
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "poll is not finalized"})
+			return
+		}
+
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.ics"`, poll.ID, invite.ID))
+		ctx.Data(http.StatusOK, "text/calendar; charset=utf-8; method=REQUEST", []byte(ics))
+		return
+	}
+
+	invites, err := ListPollInvites(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	votes, err := ListVotes(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	attendees := []string{}
+	for _, invite := range invites {
+		attendees = append(attendees, invite.Email)
+	}
+	for _, vote := range votes {
+		if vote.AccountEmail != "" && !vote.AutoFilled && vote.AccountEmail != organizer.Email && !slices.Contains(attendees, vote.AccountEmail) {
+			attendees = append(attendees, vote.AccountEmail)
+		}
+	}
+
+	ics, ok := FinalizedPollICS(poll, organizer, "", attendees, time.Now())
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "poll is not finalized"})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ics"`, poll.ID))
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8; method=REQUEST", []byte(ics))
+}
+
 func (a *APIServer) newVote(ctx *gin.Context, accountID int64) {
 	pollID := ctx.Params.ByName("id")
 	if pollID == "" {
@@ -162,16 +272,20 @@ func (a *APIServer) newVote(ctx *gin.Context, accountID int64) {
 		return
 	}
 
+	_, answers, ok := a.getPollAnswers(ctx, pollID, accountID)
+	if !ok {
+		return
+	}
 	for _, availability := range availabilities {
-		if !slices.Contains(AllOptionAnswer, availability.Answer) {
+		if !slices.Contains(answers, availability.Answer) {
 			logger.Infof("invalid availability %s", availability.Answer)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest,
-				gin.H{"error": fmt.Sprintf("invalid availability. needs to be one of: %s", strings.Join(AllOptionAnswer, ", "))})
+				gin.H{"error": fmt.Sprintf("invalid availability. needs to be one of: %s", strings.Join(answers, ", "))})
 			return
 		}
 	}
 
-	pollAccountAvailability, err := NewVote(ctx, a.db, accountID, PollAccountAvailability{
+	pollAccountAvailability, err := NewVote(ctx, a.db, accountID, accountID, PollAccountAvailability{
 		PollID:         pollID,
 		AccountID:      accountID,
 		Availabilities: availabilities,
@@ -200,6 +314,38 @@ func (a *APIServer) getVote(ctx *gin.Context, accountID int64) {
 	ctx.JSON(http.StatusOK, gin.H{"data": pollAccountAvailability})
 }
 
+// getManagedPoll loads the poll in the id path parameter, aborting the request
+// when it does not exist or the account does not have at least the minimum role in it.
+func (a *APIServer) getManagedPoll(ctx *gin.Context, accountID int64, minimum PollRole) (Poll, bool) {
+	pollID, err := getPathParam(ctx, "id")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return Poll{}, false
+	}
+
+	role, err := GetPollRole(ctx, a.db, pollID, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+	if !HasPollRole(role, minimum) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, false
+	}
+
+	poll, err := GetPoll(ctx, a.db, pollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, false
+	}
+
+	return poll, true
+}
+
 func WithAccountID(handler func(*gin.Context, int64)) func(*gin.Context) {
 	return func(ctx *gin.Context) {
 		value := ctx.Value(ACCOUNT_ID_KEY)
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+type AuditAction = string
+
+const (
+	AuditPollCreated          AuditAction = "poll.created"
+	AuditPollDeleted          AuditAction = "poll.deleted"
+	AuditPollRestored         AuditAction = "poll.restored"
+	AuditPollFinalized        AuditAction = "poll.finalized"
+	AuditPollReopened         AuditAction = "poll.reopened"
+	AuditPollTransferred      AuditAction = "poll.transferred"
+	AuditPollWorkspaceChanged AuditAction = "poll.workspace_changed"
+	AuditCommentsLockChanged  AuditAction = "poll.comments_lock_changed"
+	AuditMemberChanged        AuditAction = "member.changed"
+	AuditMemberRemoved        AuditAction = "member.removed"
+	AuditVoteChanged          AuditAction = "vote.changed"
+	AuditVoteRemoved          AuditAction = "vote.removed"
+)
+
+const (
+	AUDIT_PAGE_SIZE     = 100
+	AUDIT_MAX_PAGE_SIZE = 500
+)
+
+// PollAuditEntry is a change to a poll, made by the actor. The subject is the account whose vote or membership changed.
+// Old and New hold the values before and after the change, Old is empty when there was nothing before.
+type PollAuditEntry struct {
+	ID           int64           `json:"id"`
+	PollID       string          `json:"poll_id"`
+	ActorID      int64           `json:"actor_id,omitempty"`
+	ActorEmail   string          `json:"actor_email,omitempty"`
+	Action       AuditAction     `json:"action"`
+	SubjectID    int64           `json:"subject_id,omitempty"`
+	SubjectEmail string          `json:"subject_email,omitempty"`
+	Old          json.RawMessage `json:"old,omitempty"`
+	New          json.RawMessage `json:"new,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// auditVote is the value of a vote in the audit trail, the participant names the voter of an offline vote.
+type auditVote struct {
+	Participant    string               `json:"participant,omitempty"`
+	Availabilities []OptionAvailability `json:"availabilities"`
+	AutoFilled     bool                 `json:"auto_filled,omitempty"`
+}
+
+// pollAudit is an entry of the audit trail before it is stored, Old and New are left empty when nil.
+type pollAudit struct {
+	PollID    string
+	ActorID   int64
+	Action    AuditAction
+	SubjectID int64
+	Old       interface{}
+	New       interface{}
+}
+
+// voteAudit is the entry of a vote cast or changed by the actor, previous is nil for the first vote.
+// Offline votes have no account as subject, their participant names the voter.
+func voteAudit(actorID int64, vote PollAccountAvailability, previous *auditVote) pollAudit {
+	audit := pollAudit{
+		PollID:    vote.PollID,
+		ActorID:   actorID,
+		Action:    AuditVoteChanged,
+		SubjectID: vote.AccountID,
+		New:       auditVote{Availabilities: vote.Availabilities, AutoFilled: vote.AutoFilled},
+	}
+	if vote.OfflineID != 0 {
+		audit.SubjectID = 0
+		audit.New = auditVote{Participant: vote.ParticipantName, Availabilities: vote.Availabilities}
+	}
+	if previous != nil {
+		audit.Old = *previous
+	}
+
+	return audit
+}
+
+// voteRemovedAudit is the entry of a vote removed by the actor, keeping its last answers.
+func voteRemovedAudit(actorID int64, pollID string, subjectID int64, previous auditVote) pollAudit {
+	return pollAudit{PollID: pollID, ActorID: actorID, Action: AuditVoteRemoved, SubjectID: subjectID, Old: previous}
+}
+
+// memberAudit is the entry of a member role changed by the actor, the previous role is empty for new members
+// and the role is empty for removed ones.
+func memberAudit(actorID int64, pollID string, accountID int64, previous PollRole, role PollRole) pollAudit {
+	audit := pollAudit{PollID: pollID, ActorID: actorID, Action: AuditMemberChanged, SubjectID: accountID}
+	if previous != "" {
+		audit.Old = previous
+	}
+	if role == "" {
+		audit.Action = AuditMemberRemoved
+	} else {
+		audit.New = role
+	}
+
+	return audit
+}
+
+// finalizationAudit is the entry of a poll finalized on an option, or reopened when there is no final option.
+func finalizationAudit(actorID int64, pollID string, previousOptionID string, finalOptionID string) pollAudit {
+	audit := pollAudit{PollID: pollID, ActorID: actorID, Action: AuditPollFinalized}
+	if previousOptionID != "" {
+		audit.Old = previousOptionID
+	}
+	if finalOptionID == "" {
+		audit.Action = AuditPollReopened
+	} else {
+		audit.New = finalOptionID
+	}
+
+	return audit
+}
+
+// insertAudit stores an entry built for a change, in the transaction of the change.
+func insertAudit(ctx context.Context, tx *sql.Tx, audit pollAudit) error {
+	return insertPollAudit(ctx, tx, audit.PollID, audit.ActorID, audit.Action, audit.SubjectID, audit.Old, audit.New)
+}
+
+// insertPollAudit appends an entry to the audit trail of the poll, in the transaction of the change.
+// The trail is append-only, entries are only deleted with the poll.
+func insertPollAudit(ctx context.Context, tx *sql.Tx, pollID string, actorID int64, action AuditAction, subjectID int64, old interface{}, new interface{}) error {
+	values := []sql.NullString{}
+	for _, value := range []interface{}{old, new} {
+		if value == nil {
+			values = append(values, sql.NullString{})
+			continue
+		}
+
+		marshaled, err := json.Marshal(value)
+		if err != nil {
+			logger.Error("failed to marshal poll audit value", zap.String("action", action), zap.Error(err))
+			return err
+		}
+		values = append(values, sql.NullString{String: string(marshaled), Valid: true})
+	}
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO poll_audit (poll_id, actor_id, action, subject_id, old, new)
+VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, 0), $5, $6);`, pollID, actorID, action, subjectID, values[0], values[1])
+	if err != nil {
+		logger.Error("failed to create poll audit entry", zap.String("action", action), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// ListPollAudit returns the audit trail of the poll in the order of the changes, optionally only the changes about an account.
+func ListPollAudit(ctx context.Context, db *sql.DB, pollID string, subjectID int64, after int64, limit int) ([]PollAuditEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT poll_audit.id, poll_audit.poll_id, COALESCE(poll_audit.actor_id, 0), COALESCE(actors.email, ''), poll_audit.action,
+	COALESCE(poll_audit.subject_id, 0), COALESCE(subjects.email, ''), COALESCE(poll_audit.old::TEXT, ''), COALESCE(poll_audit.new::TEXT, ''),
+	poll_audit.created_at
+FROM poll_audit
+LEFT JOIN accounts AS actors ON poll_audit.actor_id = actors.id
+LEFT JOIN accounts AS subjects ON poll_audit.subject_id = subjects.id
+WHERE poll_audit.poll_id = $1 AND ($2 = 0 OR poll_audit.subject_id = $2) AND poll_audit.id > $3
+ORDER BY poll_audit.id
+LIMIT $4;`, pollID, subjectID, after, limit)
+	if err != nil {
+		logger.Error("failed to list poll audit", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []PollAuditEntry{}
+	for rows.Next() {
+		var entry PollAuditEntry
+		var old string
+		var new string
+		err := rows.Scan(&entry.ID, &entry.PollID, &entry.ActorID, &entry.ActorEmail, &entry.Action,
+			&entry.SubjectID, &entry.SubjectEmail, &old, &new, &entry.CreatedAt)
+		if err != nil {
+			logger.Error("failed to scan poll audit entry", zap.Error(err))
+			return nil, err
+		}
+		if old != "" {
+			entry.Old = json.RawMessage(old)
+		}
+		if new != "" {
+			entry.New = json.RawMessage(new)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll audit", zap.Error(err))
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// listPollAudit shows the audit trail to the organizers of the poll, the "account" parameter keeps the changes about one account.
+func (a *APIServer) listPollAudit(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	query := ctx.Request.URL.Query()
+	after, limit, err := parsePage(query, AUDIT_PAGE_SIZE, AUDIT_MAX_PAGE_SIZE)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var subjectID int64
+	if value := query.Get("account"); value != "" {
+		subjectID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || subjectID < 1 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter account"})
+			return
+		}
+	}
+
+	// Fetching one more entry than requested tells if there is a next page.
+	entries, err := ListPollAudit(ctx, a.db, poll.ID, subjectID, after, limit+1)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	response := gin.H{"data": entries}
+	if len(entries) > limit {
+		response["data"] = entries[:limit]
+		response["next"] = strconv.FormatInt(entries[limit-1].ID, 10)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
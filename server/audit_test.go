@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func auditJSON(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	marshaled, _ := json.Marshal(value)
+	return string(marshaled)
+}
+
+func TestPollAudits(t *testing.T) {
+	answers := []OptionAvailability{{OptionID: "a", Answer: Maybe}}
+	previous := auditVote{Availabilities: []OptionAvailability{{OptionID: "a", Answer: Available}}, AutoFilled: true}
+	offlinePrevious := auditVote{Participant: "Grandma", Availabilities: []OptionAvailability{}}
+
+	testCases := []struct {
+		name     string
+		audit    pollAudit
+		expected pollAudit
+	}{
+		{
+			"first vote",
+			voteAudit(2, PollAccountAvailability{PollID: "poll", AccountID: 2, Availabilities: answers}, nil),
+			pollAudit{"poll", 2, AuditVoteChanged, 2, "", `{"availabilities":[{"option_id":"a","answer":"maybe"}]}`},
+		},
+		{
+			"vote confirming an auto-filled one",
+			voteAudit(2, PollAccountAvailability{PollID: "poll", AccountID: 2, Availabilities: answers}, &previous),
+			pollAudit{"poll", 2, AuditVoteChanged, 2,
+				`{"availabilities":[{"option_id":"a","answer":"available"}],"auto_filled":true}`,
+				`{"availabilities":[{"option_id":"a","answer":"maybe"}]}`},
+		},
+		{
+			"auto-filled vote",
+			voteAudit(2, PollAccountAvailability{PollID: "poll", AccountID: 2, Availabilities: answers, AutoFilled: true}, nil),
+			pollAudit{"poll", 2, AuditVoteChanged, 2, "", `{"availabilities":[{"option_id":"a","answer":"maybe"}],"auto_filled":true}`},
+		},
+		{
+			"vote imported by the organizer",
+			voteAudit(1, PollAccountAvailability{PollID: "poll", AccountID: 2, Availabilities: answers}, nil),
+			pollAudit{"poll", 1, AuditVoteChanged, 2, "", `{"availabilities":[{"option_id":"a","answer":"maybe"}]}`},
+		},
+		{
+			"offline vote",
+			voteAudit(1, PollAccountAvailability{PollID: "poll", OfflineID: 5, ParticipantName: "Grandma", Availabilities: answers}, nil),
+			pollAudit{"poll", 1, AuditVoteChanged, 0, "", `{"participant":"Grandma","availabilities":[{"option_id":"a","answer":"maybe"}]}`},
+		},
+		{
+			"renamed offline vote",
+			voteAudit(3, PollAccountAvailability{PollID: "poll", OfflineID: 5, ParticipantName: "Granny", Availabilities: answers}, &offlinePrevious),
+			pollAudit{"poll", 3, AuditVoteChanged, 0,
+				`{"participant":"Grandma","availabilities":[]}`,
+				`{"participant":"Granny","availabilities":[{"option_id":"a","answer":"maybe"}]}`},
+		},
+		{
+			"removed offline vote",
+			voteRemovedAudit(1, "poll", 0, offlinePrevious),
+			pollAudit{"poll", 1, AuditVoteRemoved, 0, `{"participant":"Grandma","availabilities":[]}`, ""},
+		},
+		{
+			"new member",
+			memberAudit(1, "poll", 4, "", PollViewer),
+			pollAudit{"poll", 1, AuditMemberChanged, 4, "", `"viewer"`},
+		},
+		{
+			"promoted member",
+			memberAudit(1, "poll", 4, PollViewer, PollEditor),
+			pollAudit{"poll", 1, AuditMemberChanged, 4, `"viewer"`, `"editor"`},
+		},
+		{
+			"removed member",
+			memberAudit(1, "poll", 4, PollEditor, ""),
+			pollAudit{"poll", 1, AuditMemberRemoved, 4, `"editor"`, ""},
+		},
+		{
+			"finalized poll",
+			finalizationAudit(1, "poll", "", "a"),
+			pollAudit{"poll", 1, AuditPollFinalized, 0, "", `"a"`},
+		},
+		{
+			"finalized on another option",
+			finalizationAudit(4, "poll", "a", "b"),
+			pollAudit{"poll", 4, AuditPollFinalized, 0, `"a"`, `"b"`},
+		},
+		{
+			"reopened poll",
+			finalizationAudit(1, "poll", "b", ""),
+			pollAudit{"poll", 1, AuditPollReopened, 0, `"b"`, ""},
+		},
+	}
+
+	for _, testCase := range testCases {
+		audit := testCase.audit
+		if audit.PollID != testCase.expected.PollID || audit.ActorID != testCase.expected.ActorID ||
+			audit.Action != testCase.expected.Action || audit.SubjectID != testCase.expected.SubjectID {
+			t.Errorf("Expected the %s entry %+v, but got %+v", testCase.name, testCase.expected, audit)
+		}
+		if old := auditJSON(audit.Old); old != testCase.expected.Old {
+			t.Errorf("Expected the %s entry to have old %s, but got %s", testCase.name, testCase.expected.Old, old)
+		}
+		if new := auditJSON(audit.New); new != testCase.expected.New {
+			t.Errorf("Expected the %s entry to have new %s, but got %s", testCase.name, testCase.expected.New, new)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	ICS_IMPORT_MAX_SIZE = 5 << 20
+	ICS_IMPORT_TIMEOUT  = 10 * time.Second
+)
+
+var ErrICSTooLarge = errors.New("calendar is too large")
+
+// calendarAllowedNetworks are the networks, besides the internet, that calendars can be fetched from. Calendars are
+// often served from the local network (e.g. a NAS or groupware server), so the operator lists them in
+// CALENDAR_ALLOWED_NETWORKS.
+var calendarAllowedNetworks []*net.IPNet
+
+// ParseCalendarAllowedNetworks reads a comma separated list of CIDRs or addresses.
+func ParseCalendarAllowedNetworks(value string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s", entry)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// isCalendarIP accepts public addresses and the ones in the allowed networks, so the loopback, link-local and
+// private addresses are refused unless the operator allowed them.
+func isCalendarIP(ip net.IP) bool {
+	for _, network := range calendarAllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return isPublicIP(ip)
+}
+
+// newCalendarClient fetches the calendars at the urls given by the accounts. Like newWebhookClient, the address
+// is checked when dialing, so names resolving to internal addresses and redirects to them are refused as well.
+func newCalendarClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isCalendarIP(ip) {
+				return fmt.Errorf("calendar address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("calendar redirected too many times")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("calendar redirected to %s, which is not an http(s) url", req.URL)
+			}
+			return nil
+		},
+	}
+}
+
+// BusyPeriod is a time range in which an account is not available.
+type BusyPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SuggestAvailabilities answers unavailable for the options overlapping a busy period and available for the others.
+func SuggestAvailabilities(options []PollOption, busy []BusyPeriod) []OptionAvailability {
+	availabilities := []OptionAvailability{}
+	for _, option := range options {
+		answer := Available
+		for _, period := range busy {
+			if period.Start.Before(option.End) && period.End.After(option.Start) {
+				answer = Unavailable
+				break
+			}
+		}
+		availabilities = append(availabilities, OptionAvailability{OptionID: option.ID, Answer: answer})
+	}
+
+	return availabilities
+}
+
+// optionsWindow is the time range covered by the options of a poll.
+func optionsWindow(options []PollOption) (time.Time, time.Time) {
+	var from, to time.Time
+	for _, option := range options {
+		if from.IsZero() || option.Start.Before(from) {
+			from = option.Start
+		}
+		if to.IsZero() || option.End.After(to) {
+			to = option.End
+		}
+	}
+	return from, to
+}
+
+// ICalBusyPeriods expands the busy events of a calendar that overlap [from, to).
+// Recurring events are expanded with their RRULE, RDATE and EXDATE, and occurrences moved
+// or cancelled with a RECURRENCE-ID replace the original one. Free and cancelled events are ignored.
+func ICalBusyPeriods(calendar *ICalComponent, from time.Time, to time.Time, floating *time.Location) []BusyPeriod {
+	events := calendar.Children("VEVENT")
+
+	overridden := map[string]map[int64]bool{}
+	for _, event := range events {
+		recurrenceID, ok := event.Prop("RECURRENCE-ID")
+		if !ok {
+			continue
+		}
+		t, _, err := parseICalTime(recurrenceID, floating)
+		if err != nil {
+			continue
+		}
+		uid := event.Value("UID")
+		if overridden[uid] == nil {
+			overridden[uid] = map[int64]bool{}
+		}
+		overridden[uid][t.Unix()] = true
+	}
+
+	busy := []BusyPeriod{}
+	for _, event := range events {
+		if strings.EqualFold(event.Value("STATUS"), "CANCELLED") || strings.EqualFold(event.Value("TRANSP"), "TRANSPARENT") {
+			continue
+		}
+
+		dtstart, ok := event.Prop("DTSTART")
+		if !ok {
+			continue
+		}
+		start, allDay, err := parseICalTime(dtstart, floating)
+		if err != nil {
+			logger.Warn("ignoring event with invalid start", zap.String("uid", event.Value("UID")), zap.Error(err))
+			continue
+		}
+
+		duration, err := icalEventDuration(event, start, allDay, floating)
+		if err != nil {
+			logger.Warn("ignoring event with invalid end", zap.String("uid", event.Value("UID")), zap.Error(err))
+			continue
+		}
+
+		starts := []time.Time{start}
+		if _, isOverride := event.Prop("RECURRENCE-ID"); !isOverride {
+			// A day earlier, so the all day occurrences still overlapping from are kept across daylight saving changes.
+			starts, err = icalEventOccurrences(event, start, from.Add(-duration).AddDate(0, 0, -1), to, floating)
+			if err != nil {
+				logger.Warn("ignoring event with invalid recurrence", zap.String("uid", event.Value("UID")), zap.Error(err))
+				continue
+			}
+			excluded := overridden[event.Value("UID")]
+			for _, exdate := range event.Props("EXDATE") {
+				for _, value := range strings.Split(exdate.Value, ",") {
+					t, _, err := parseICalTime(ICalProperty{Params: exdate.Params, Value: value}, floating)
+					if err == nil {
+						if excluded == nil {
+							excluded = map[int64]bool{}
+						}
+						excluded[t.Unix()] = true
+					}
+				}
+			}
+
+			remaining := []time.Time{}
+			for _, t := range starts {
+				if !excluded[t.Unix()] {
+					remaining = append(remaining, t)
+				}
+			}
+			starts = remaining
+		}
+
+		for _, t := range starts {
+			period := BusyPeriod{Start: t, End: t.Add(duration)}
+			if allDay {
+				period.End = t.AddDate(0, 0, int(duration/(24*time.Hour)))
+			}
+			if period.Start.Before(to) && period.End.After(from) {
+				busy = append(busy, period)
+			}
+		}
+	}
+
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+
+	return busy
+}
+
+// icalEventDuration reads the length of an event from DTEND or DURATION,
+// events without either last one day when all day and are instantaneous otherwise.
+func icalEventDuration(event *ICalComponent, start time.Time, allDay bool, floating *time.Location) (time.Duration, error) {
+	if dtend, ok := event.Prop("DTEND"); ok {
+		end, _, err := parseICalTime(dtend, floating)
+		if err != nil {
+			return 0, err
+		}
+		if end.Before(start) {
+			return 0, fmt.Errorf("end %s before start %s", end, start)
+		}
+		return end.Sub(start), nil
+	}
+
+	if value := event.Value("DURATION"); value != "" {
+		duration, err := parseICalDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		if duration < 0 {
+			return 0, fmt.Errorf("negative duration %s", value)
+		}
+		return duration, nil
+	}
+
+	if allDay {
+		return 24 * time.Hour, nil
+	}
+	return 0, nil
+}
+
+// icalEventOccurrences lists the starts of an event before the end time, including its RDATEs.
+// The occurrences of its recurrence rule are only expanded from the from time.
+func icalEventOccurrences(event *ICalComponent, start time.Time, from time.Time, end time.Time, floating *time.Location) ([]time.Time, error) {
+	starts := []time.Time{start}
+
+	if rrule := event.Value("RRULE"); rrule != "" {
+		rule, err := ParseRecurrenceRule(rrule, start.Location())
+		if err != nil {
+			return nil, err
+		}
+		starts = rule.Occurrences(start, from, end)
+	}
+
+	for _, rdate := range event.Props("RDATE") {
+		if rdate.Params["VALUE"] == "PERIOD" {
+			continue
+		}
+		for _, value := range strings.Split(rdate.Value, ",") {
+			t, _, err := parseICalTime(ICalProperty{Params: rdate.Params, Value: value}, floating)
+			if err == nil && t.Before(end) {
+				starts = append(starts, t)
+			}
+		}
+	}
+
+	return starts, nil
+}
+
+// fetchICS downloads a calendar from an http(s) or webcal url, on the internet or in calendarAllowedNetworks.
+func fetchICS(ctx context.Context, rawURL string) ([]byte, error) {
+	calendarURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	switch calendarURL.Scheme {
+	case "http", "https":
+	case "webcal":
+		calendarURL.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported url scheme %s", calendarURL.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ICS_IMPORT_TIMEOUT)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, calendarURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/calendar")
+
+	response, err := newCalendarClient(ICS_IMPORT_TIMEOUT).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return readLimited(response.Body, ICS_IMPORT_MAX_SIZE)
+}
+
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrICSTooLarge
+	}
+	return data, nil
+}
+
+type ImportICSRequest struct {
+	URL string `json:"url"`
+}
+
+// importVoteICS suggests a vote from a calendar, uploaded as the multipart file field or
+// fetched from the url in the body. Floating times are read in the timezone query parameter.
+// Nothing is stored, the suggestion is confirmed by submitting it through newVote.
+func (a *APIServer) importVoteICS(ctx *gin.Context, accountID int64) {
+	var err error
+	floating := time.UTC
+	if timezone := ctx.Query("timezone"); timezone != "" {
+		floating, err = time.LoadLocation(timezone)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter timezone"})
+			return
+		}
+	}
+
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	var data []byte
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing calendar file"})
+			return
+		}
+		if file.Size > ICS_IMPORT_MAX_SIZE {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrICSTooLarge.Error()})
+			return
+		}
+		reader, err := file.Open()
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid calendar file"})
+			return
+		}
+		defer reader.Close()
+		data, err = readLimited(reader, ICS_IMPORT_MAX_SIZE)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid calendar file"})
+			return
+		}
+	} else {
+		request := ImportICSRequest{}
+		err = readBody(ctx, &request)
+		if err != nil || request.URL == "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		data, err = fetchICS(ctx, request.URL)
+		if err != nil {
+			logger.Warn("failed to fetch calendar", zap.String("url", request.URL), zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "failed to fetch calendar"})
+			return
+		}
+	}
+
+	calendar, err := ParseICal(strings.NewReader(string(data)))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid calendar"})
+		return
+	}
+
+	from, to := optionsWindow(poll.Options)
+	busy := ICalBusyPeriods(calendar, from, to, floating)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"vote": PollAccountAvailability{
+			PollID:         poll.ID,
+			AccountID:      accountID,
+			Availabilities: SuggestAvailabilities(poll.Options, busy),
+		},
+		"busy": busy,
+	}})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceRuleOccurrences(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatalf("Failed to load location: %s", err)
+	}
+
+	testCases := []struct {
+		rule     string
+		start    time.Time
+		expected []string
+	}{
+		{"FREQ=DAILY;COUNT=3", time.Date(2024, 3, 30, 9, 0, 0, 0, lisbon),
+			[]string{"2024-03-30 09:00", "2024-03-31 09:00", "2024-04-01 09:00"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20240520T000000Z", time.Date(2024, 5, 2, 10, 0, 0, 0, lisbon),
+			[]string{"2024-05-02 10:00", "2024-05-14 10:00", "2024-05-16 10:00"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", time.Date(2024, 1, 26, 15, 0, 0, 0, time.UTC),
+			[]string{"2024-01-26 15:00", "2024-02-23 15:00", "2024-03-29 15:00"}},
+		{"FREQ=MONTHLY;COUNT=3", time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC),
+			[]string{"2024-01-31 08:00", "2024-03-31 08:00", "2024-05-31 08:00"}},
+		{"FREQ=YEARLY;BYMONTH=2,8;BYMONTHDAY=1", time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC),
+			[]string{"2024-02-01 08:00", "2024-08-01 08:00", "2025-02-01 08:00"}},
+	}
+
+	for _, testCase := range testCases {
+		rule, err := ParseRecurrenceRule(testCase.rule, testCase.start.Location())
+		if err != nil {
+			t.Errorf("Expected %s to parse, but got %s", testCase.rule, err)
+			continue
+		}
+
+		occurrences := []string{}
+		for _, occurrence := range rule.Occurrences(testCase.start, testCase.start, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			occurrences = append(occurrences, occurrence.Format("2006-01-02 15:04"))
+		}
+		if !reflect.DeepEqual(occurrences, testCase.expected) {
+			t.Errorf("Expected %v for %s, but got %v", testCase.expected, testCase.rule, occurrences)
+		}
+	}
+
+	// Long running series are expanded from the window, not from their first occurrence.
+	windowTestCases := []struct {
+		rule     string
+		start    time.Time
+		expected []string
+	}{
+		{"FREQ=DAILY", time.Date(1990, 1, 1, 9, 0, 0, 0, lisbon),
+			[]string{"2024-07-01 09:00", "2024-07-02 09:00"}},
+		{"FREQ=DAILY;INTERVAL=3", time.Date(1990, 1, 2, 9, 0, 0, 0, time.UTC),
+			[]string{"2024-07-02 09:00"}},
+		{"FREQ=WEEKLY;BYDAY=MO,TU", time.Date(1980, 1, 1, 8, 0, 0, 0, time.UTC),
+			[]string{"2024-07-01 08:00", "2024-07-02 08:00"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=2", time.Date(1950, 3, 2, 8, 0, 0, 0, time.UTC),
+			[]string{"2024-07-02 08:00"}},
+		{"FREQ=DAILY;COUNT=12601", time.Date(1990, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2024-07-01 09:00"}},
+		{"FREQ=DAILY;COUNT=12600", time.Date(1990, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{}},
+	}
+
+	for _, testCase := range windowTestCases {
+		rule, err := ParseRecurrenceRule(testCase.rule, testCase.start.Location())
+		if err != nil {
+			t.Errorf("Expected %s to parse, but got %s", testCase.rule, err)
+			continue
+		}
+
+		occurrences := []string{}
+		for _, occurrence := range rule.Occurrences(testCase.start, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)) {
+			occurrences = append(occurrences, occurrence.Format("2006-01-02 15:04"))
+		}
+		if !reflect.DeepEqual(occurrences, testCase.expected) {
+			t.Errorf("Expected %v for %s from %s, but got %v", testCase.expected, testCase.rule, testCase.start, occurrences)
+		}
+	}
+
+	if _, err := ParseRecurrenceRule("FREQ=SECONDLY", time.UTC); err == nil {
+		t.Errorf("Expected unsupported frequency to fail")
+	}
+}
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup\r\n" +
+	"DTSTART;TZID=Europe/Lisbon:20240506T093000\r\n" +
+	"DURATION:PT30M\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n" +
+	"EXDATE;TZID=Europe/Lisbon:20240508T093000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Lisbon:20240513T093000\r\n" +
+	"DTSTART;TZID=Europe/Lisbon:20240513T150000\r\n" +
+	"DTEND;TZID=Europe/Lisbon:20240513T153000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday\r\n" +
+	"DTSTART;VALUE=DATE:20240510\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:birthday\r\n" +
+	"DTSTART;VALUE=DATE:20240509\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled\r\n" +
+	"DTSTART:20240509T090000Z\r\n" +
+	"DTEND:20240509T100000Z\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICalBusyPeriods(t *testing.T) {
+	calendar, err := ParseICal(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("Expected calendar to parse, but got %s", err)
+	}
+
+	option := func(id string, start string) PollOption {
+		s, _ := time.Parse(time.RFC3339, start)
+		return PollOption{ID: id, Start: s, End: s.Add(time.Hour)}
+	}
+	options := []PollOption{
+		option("monday", "2024-05-06T08:00:00Z"),
+		option("wednesday", "2024-05-08T08:00:00Z"),
+		option("thursday", "2024-05-09T08:00:00Z"),
+		option("friday", "2024-05-10T12:00:00Z"),
+		option("moved-from", "2024-05-13T08:00:00Z"),
+		option("moved-to", "2024-05-13T14:00:00Z"),
+		option("next-wednesday", "2024-05-15T08:00:00Z"),
+	}
+
+	from, to := optionsWindow(options)
+	busy := ICalBusyPeriods(calendar, from, to, time.UTC)
+	if len(busy) != 4 {
+		t.Errorf("Expected 4 busy periods, but got %v", busy)
+	}
+
+	expected := []OptionAvailability{
+		{"monday", Unavailable},
+		{"wednesday", Available},
+		{"thursday", Available},
+		{"friday", Unavailable},
+		{"moved-from", Available},
+		{"moved-to", Unavailable},
+		{"next-wednesday", Unavailable},
+	}
+	if availabilities := SuggestAvailabilities(options, busy); !reflect.DeepEqual(availabilities, expected) {
+		t.Errorf("Expected %v, but got %v", expected, availabilities)
+	}
+}
+
+func TestParseICalDuration(t *testing.T) {
+	testCases := map[string]time.Duration{
+		"PT30M":   30 * time.Minute,
+		"P1DT2H":  26 * time.Hour,
+		"P1W":     7 * 24 * time.Hour,
+		"-PT15M":  -15 * time.Minute,
+		"PT1H30S": time.Hour + 30*time.Second,
+	}
+
+	for value, expected := range testCases {
+		duration, err := parseICalDuration(value)
+		if err != nil || duration != expected {
+			t.Errorf("Expected %s for %s, but got %s (%v)", expected, value, duration, err)
+		}
+	}
+
+	for _, value := range []string{"", "1H", "PT", "P1H"} {
+		if _, err := parseICalDuration(value); err == nil {
+			t.Errorf("Expected %q to fail", value)
+		}
+	}
+}
+
+func TestIsCalendarIP(t *testing.T) {
+	allowed, err := ParseCalendarAllowedNetworks("192.168.1.0/24, 10.0.0.12")
+	if err != nil {
+		t.Fatalf("Expected networks to parse, but got %s", err)
+	}
+	if _, err := ParseCalendarAllowedNetworks("192.168.1.0/33"); err == nil {
+		t.Errorf("Expected invalid network to be rejected")
+	}
+
+	defer func(networks []*net.IPNet) { calendarAllowedNetworks = networks }(calendarAllowedNetworks)
+	calendarAllowedNetworks = allowed
+
+	testCases := map[string]bool{
+		"93.184.216.34":   true,
+		"192.168.1.20":    true,
+		"10.0.0.12":       true,
+		"10.0.0.13":       false,
+		"127.0.0.1":       false,
+		"169.254.169.254": false,
+		"::1":             false,
+	}
+
+	for address, expected := range testCases {
+		if allowed := isCalendarIP(net.ParseIP(address)); allowed != expected {
+			t.Errorf("Expected %s to be allowed %t, but got %t", address, expected, allowed)
+		}
+	}
+}
+
+func TestFetchICSInternalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+	}))
+	defer server.Close()
+
+	if _, err := fetchICS(context.Background(), server.URL); err == nil {
+		t.Errorf("Expected the loopback address to be refused at dial time")
+	}
+
+	defer func(networks []*net.IPNet) { calendarAllowedNetworks = networks }(calendarAllowedNetworks)
+	calendarAllowedNetworks, _ = ParseCalendarAllowedNetworks("127.0.0.1")
+	if _, err := fetchICS(context.Background(), server.URL); err != nil {
+		t.Errorf("Expected the allowed address to be reached, but got %s", err)
+	}
+}
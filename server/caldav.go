@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	CALDAV_TIMEOUT  = 10 * time.Second
+	CALDAV_MAX_SIZE = 5 << 20
+)
+
+var ErrCalDAVNotConnected = errors.New("caldav account not connected")
+
+// CalDAVClient talks to a single calendar collection of a CalDAV server, e.g. Nextcloud or Radicale.
+// The server has to be on the internet or in calendarAllowedNetworks, like the calendars fetched by fetchICS.
+type CalDAVClient struct {
+	calendarURL string
+	username    string
+	password    string
+	client      *http.Client
+}
+
+func NewCalDAVClient(calendarURL string, username string, password string) (*CalDAVClient, error) {
+	parsedURL, err := url.Parse(calendarURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return nil, fmt.Errorf("invalid calendar url %s", calendarURL)
+	}
+
+	return &CalDAVClient{
+		calendarURL: strings.TrimSuffix(calendarURL, "/") + "/",
+		username:    username,
+		password:    password,
+		client:      newCalendarClient(CALDAV_TIMEOUT),
+	}, nil
+}
+
+func (c *CalDAVClient) do(ctx context.Context, method string, target string, headers map[string]string, body string) (*http.Response, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	request.SetBasicAuth(c.username, c.password)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := readLimited(response.Body, CALDAV_MAX_SIZE)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, data, nil
+}
+
+// Check verifies the url is a calendar collection the credentials can access.
+func (c *CalDAVClient) Check(ctx context.Context) error {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`
+	response, data, err := c.do(ctx, "PROPFIND", c.calendarURL, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	}, body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	if !bytes.Contains(data, []byte("calendar")) {
+		return fmt.Errorf("%s is not a calendar collection", c.calendarURL)
+	}
+
+	return nil
+}
+
+// FreeBusy runs a free-busy-query REPORT (RFC 4791 7.10) on the calendar between from and to.
+func (c *CalDAVClient) FreeBusy(ctx context.Context, from time.Time, to time.Time) ([]BusyPeriod, error) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>`+
+		`<C:free-busy-query xmlns:C="urn:ietf:params:xml:ns:caldav"><C:time-range start="%s" end="%s"/></C:free-busy-query>`,
+		icalTime(from), icalTime(to))
+	response, data, err := c.do(ctx, "REPORT", c.calendarURL, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	}, body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	calendar, err := ParseICal(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return ICalFreeBusyPeriods(calendar)
+}
+
+// PutEvent creates or replaces the event with the uid, returning its url.
+func (c *CalDAVClient) PutEvent(ctx context.Context, uid string, ics string) (string, error) {
+	href := c.calendarURL + url.PathEscape(uid) + ".ics"
+	response, _, err := c.do(ctx, http.MethodPut, href, map[string]string{
+		"Content-Type": "text/calendar; charset=utf-8",
+	}, ics)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return href, nil
+}
+
+func (c *CalDAVClient) DeleteEvent(ctx context.Context, href string) error {
+	if !strings.HasPrefix(href, c.calendarURL) {
+		return fmt.Errorf("event %s is not in calendar %s", href, c.calendarURL)
+	}
+
+	response, _, err := c.do(ctx, http.MethodDelete, href, nil, "")
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 300 && response.StatusCode != http.StatusNotFound && response.StatusCode != http.StatusGone {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// ICalFreeBusyPeriods reads the busy FREEBUSY periods of the VFREEBUSY components of a calendar.
+func ICalFreeBusyPeriods(calendar *ICalComponent) ([]BusyPeriod, error) {
+	busy := []BusyPeriod{}
+	for _, freeBusy := range calendar.Children("VFREEBUSY") {
+		for _, property := range freeBusy.Props("FREEBUSY") {
+			if fbtype := strings.ToUpper(property.Params["FBTYPE"]); fbtype == "FREE" {
+				continue
+			}
+
+			for _, period := range strings.Split(property.Value, ",") {
+				startValue, endValue, found := strings.Cut(period, "/")
+				if !found {
+					return nil, fmt.Errorf("invalid period %s", period)
+				}
+				start, _, err := parseICalTimeValue(startValue, false, time.UTC)
+				if err != nil {
+					return nil, err
+				}
+
+				var end time.Time
+				if strings.HasPrefix(endValue, "P") {
+					duration, err := parseICalDuration(endValue)
+					if err != nil {
+						return nil, err
+					}
+					end = start.Add(duration)
+				} else {
+					end, _, err = parseICalTimeValue(endValue, false, time.UTC)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				busy = append(busy, BusyPeriod{Start: start, End: end})
+			}
+		}
+	}
+
+	return busy, nil
+}
+
+func caldavHoldUID(pollID string, optionID string) string {
+	return pollID + "-" + optionID + "-hold@roodle"
+}
+
+// CalDAVHoldICS is a tentative event blocking an option the account answered available, until the poll is finalized.
+func CalDAVHoldICS(poll Poll, option PollOption, now time.Time) string {
+	w := &ICalWriter{}
+	w.Prop("BEGIN", "VCALENDAR")
+	w.Prop("VERSION", "2.0")
+	w.Prop("PRODID", ICAL_PRODID)
+	w.Prop("BEGIN", "VEVENT")
+	w.Prop("UID", caldavHoldUID(poll.ID, option.ID))
+	w.Prop("DTSTAMP", icalTime(now))
+	w.Prop("DTSTART", icalTime(option.Start))
+	w.Prop("DTEND", icalTime(option.End))
+	w.Prop("SUMMARY", icalEscape("(Hold) "+poll.Title))
+	if poll.Location != "" {
+		w.Prop("LOCATION", icalEscape(poll.Location))
+	}
+	w.Prop("URL", pollURL(poll.ID))
+	w.Prop("STATUS", "TENTATIVE")
+	w.Prop("TRANSP", "OPAQUE")
+	w.Prop("END", "VEVENT")
+	w.Prop("END", "VCALENDAR")
+
+	return w.String()
+}
+
+type CalDAV struct {
+	db  *sql.DB
+	api *APIServer
+}
+
+func NewCalDAV(db *sql.DB) *CalDAV {
+	return &CalDAV{db: db, api: NewAPIServer(db)}
+}
+
+// Client returns the client of the calendar registered by the account.
+func (c *CalDAV) Client(ctx context.Context, accountID int64) (*CalDAVClient, CalDAVAccount, error) {
+	account, encryptedPassword, err := GetCalDAVAccount(ctx, c.db, accountID)
+	if err != nil {
+		return nil, CalDAVAccount{}, err
+	}
+	if account.URL == "" {
+		return nil, CalDAVAccount{}, ErrCalDAVNotConnected
+	}
+
+	password, err := decryptSecret(encryptedPassword)
+	if err != nil {
+		logger.Error("failed to decrypt caldav password", zap.Int64("accountID", accountID), zap.Error(err))
+		return nil, CalDAVAccount{}, err
+	}
+
+	client, err := NewCalDAVClient(account.URL, account.Username, password)
+	if err != nil {
+		return nil, CalDAVAccount{}, err
+	}
+
+	return client, account, nil
+}
+
+// SyncHolds makes the holds of an account match the options it answered available.
+func (c *CalDAV) SyncHolds(ctx context.Context, poll Poll, accountID int64, availabilities []OptionAvailability) error {
+	wanted := map[string]bool{}
+	if poll.FinalOptionID == "" {
+		for _, availability := range availabilities {
+			if availability.Answer == Available {
+				wanted[availability.OptionID] = true
+			}
+		}
+	}
+
+	return c.syncHolds(ctx, poll, accountID, wanted)
+}
+
+// ReleaseHolds removes every hold of an account for a poll.
+func (c *CalDAV) ReleaseHolds(ctx context.Context, pollID string, accountID int64) error {
+	return c.syncHolds(ctx, Poll{ID: pollID}, accountID, map[string]bool{})
+}
+
+func (c *CalDAV) syncHolds(ctx context.Context, poll Poll, accountID int64, wanted map[string]bool) error {
+	holds, err := ListCalDAVHolds(ctx, c.db, poll.ID)
+	if err != nil {
+		return err
+	}
+
+	client, account, err := c.Client(ctx, accountID)
+	if errors.Is(err, ErrCalDAVNotConnected) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !account.Holds {
+		wanted = map[string]bool{}
+	}
+
+	existing := map[string]bool{}
+	for _, hold := range holds {
+		if hold.AccountID != accountID {
+			continue
+		}
+		existing[hold.OptionID] = true
+		if wanted[hold.OptionID] {
+			continue
+		}
+
+		if err := client.DeleteEvent(ctx, hold.Href); err != nil {
+			logger.Error("failed to delete caldav hold", zap.String("pollID", poll.ID), zap.Int64("accountID", accountID), zap.Error(err))
+			return err
+		}
+		if err := DeleteCalDAVHold(ctx, c.db, poll.ID, accountID, hold.OptionID); err != nil {
+			return err
+		}
+	}
+
+	for _, option := range poll.Options {
+		if !wanted[option.ID] || existing[option.ID] {
+			continue
+		}
+
+		href, err := client.PutEvent(ctx, caldavHoldUID(poll.ID, option.ID), CalDAVHoldICS(poll, option, time.Now()))
+		if err != nil {
+			logger.Error("failed to create caldav hold", zap.String("pollID", poll.ID), zap.Int64("accountID", accountID), zap.Error(err))
+			return err
+		}
+		if err := NewCalDAVHold(ctx, c.db, CalDAVHold{PollID: poll.ID, AccountID: accountID, OptionID: option.ID, Href: href}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *CalDAV) getCalDAVAccount(ctx *gin.Context, accountID int64) {
+	account, _, err := GetCalDAVAccount(ctx, c.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if account.URL == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "caldav account not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": account})
+}
+
+// setCalDAVAccount registers the calendar of the account, after checking the credentials against the server.
+func (c *CalDAV) setCalDAVAccount(ctx *gin.Context, accountID int64) {
+	account := CalDAVAccount{}
+	err := readBody(ctx, &account)
+	if err != nil || account.URL == "" || account.Password == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	client, err := NewCalDAVClient(account.URL, account.Username, account.Password)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := client.Check(ctx); err != nil {
+		logger.Warn("failed to check caldav account", zap.String("url", account.URL), zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to access calendar"})
+		return
+	}
+
+	encryptedPassword, err := encryptSecret(account.Password)
+	if err != nil {
+		logger.Error("failed to encrypt caldav password", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	// The holds in the previous calendar can only be deleted with its client, the new one refuses them.
+	holds, err := ListAccountCalDAVHolds(ctx, c.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	previousHolds := []CalDAVHold{}
+	for _, hold := range holds {
+		if !strings.HasPrefix(hold.Href, client.calendarURL) {
+			previousHolds = append(previousHolds, hold)
+		}
+	}
+	c.deleteHoldEvents(ctx, accountID, previousHolds)
+
+	account.URL = client.calendarURL
+	err = SetCalDAVAccount(ctx, c.db, accountID, account, encryptedPassword)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	account.Password = ""
+	ctx.JSON(http.StatusOK, gin.H{"data": account})
+}
+
+// deleteCalDAVAccount removes the holds still in the calendar before forgetting the account.
+func (c *CalDAV) deleteCalDAVAccount(ctx *gin.Context, accountID int64) {
+	holds, err := ListAccountCalDAVHolds(ctx, c.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	c.deleteHoldEvents(ctx, accountID, holds)
+
+	err = DeleteCalDAVAccount(ctx, c.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+// caldavVote suggests a vote from the free/busy of the registered calendar, like importVoteICS.
+func (c *CalDAV) caldavVote(ctx *gin.Context, accountID int64) {
+	poll, ok := c.api.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	client, _, err := c.Client(ctx, accountID)
+	if errors.Is(err, ErrCalDAVNotConnected) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	busy := []BusyPeriod{}
+	if len(poll.Options) > 0 {
+		from, to := optionsWindow(poll.Options)
+		busy, err = client.FreeBusy(ctx, from, to)
+		if err != nil {
+			logger.Error("failed to query caldav free/busy", zap.Int64("accountID", accountID), zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "failed to query calendar"})
+			return
+		}
+
+		holds, err := ListCalDAVHolds(ctx, c.db, poll.ID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		busy = withoutHolds(busy, poll, accountID, holds)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"vote": PollAccountAvailability{
+			PollID:         poll.ID,
+			AccountID:      accountID,
+			Availabilities: SuggestAvailabilities(poll.Options, busy),
+		},
+		"busy": busy,
+	}})
+}
+
+// withoutHolds drops the busy periods of the holds the account has for the poll, they would mark its own answers unavailable.
+func withoutHolds(busy []BusyPeriod, poll Poll, accountID int64, holds []CalDAVHold) []BusyPeriod {
+	held := map[string]bool{}
+	for _, hold := range holds {
+		if hold.AccountID == accountID {
+			held[hold.OptionID] = true
+		}
+	}
+
+	remaining := []BusyPeriod{}
+	for _, period := range busy {
+		isHold := false
+		for _, option := range poll.Options {
+			if held[option.ID] && period.Start.Equal(option.Start) && period.End.Equal(option.End) {
+				isHold = true
+				break
+			}
+		}
+		if !isHold {
+			remaining = append(remaining, period)
+		}
+	}
+
+	return remaining
+}
+
+// CalDAVConsumer writes tentative holds for the options voters answered available and removes them once the poll is
+// final or deleted, writing them again if the poll is restored.
+type CalDAVConsumer struct {
+	caldav *CalDAV
+}
+
+func NewCalDAVConsumer(db *sql.DB) *CalDAVConsumer {
+	return &CalDAVConsumer{caldav: NewCalDAV(db)}
+}
+
+func (c *CalDAVConsumer) Name() string {
+	return "caldav"
+}
+
+func (c *CalDAVConsumer) Consume(ctx context.Context, event PollEvent) error {
+	db := c.caldav.db
+
+	switch event.Type {
+	case VoteCastEvent:
+		payload := PollAccountAvailability{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			logger.Error("failed to unmarshal vote event payload", zap.Int64("eventID", event.ID), zap.Error(err))
+			return nil
+		}
+		// Offline votes have no account, so no calendar to hold the options in.
+		if payload.AccountID == 0 {
+			return nil
+		}
+
+		poll, err := GetPoll(ctx, db, event.PollID)
+		if err != nil || reflect.ValueOf(poll).IsZero() {
+			return err
+		}
+		// The current vote is used instead of the payload, so retried or reordered deliveries converge.
+		vote, err := GetVote(ctx, db, payload.AccountID, event.PollID)
+		if err != nil {
+			return err
+		}
+		// Auto-filled votes hold nothing until the account confirms them.
+		if vote.AutoFilled {
+			return c.caldav.ReleaseHolds(ctx, poll.ID, payload.AccountID)
+		}
+		return c.caldav.SyncHolds(ctx, poll, payload.AccountID, vote.Availabilities)
+	case PollRestoredEvent:
+		poll, err := GetPoll(ctx, db, event.PollID)
+		if err != nil || reflect.ValueOf(poll).IsZero() {
+			return err
+		}
+		votes, err := ListVotes(ctx, db, event.PollID)
+		if err != nil {
+			return err
+		}
+
+		// The holds were released when the poll was deleted, the confirmed votes hold their options again.
+		var syncErr error
+		for _, vote := range votes {
+			if vote.AccountID == 0 || vote.AutoFilled {
+				continue
+			}
+			if err := c.caldav.SyncHolds(ctx, poll, vote.AccountID, vote.Availabilities); err != nil {
+				syncErr = err
+			}
+		}
+		return syncErr
+	case PollFinalizedEvent, PollDeletedEvent:
+		holds, err := ListCalDAVHolds(ctx, db, event.PollID)
+		if err != nil {
+			return err
+		}
+
+		released := map[int64]bool{}
+		var releaseErr error
+		for _, hold := range holds {
+			if released[hold.AccountID] {
+				continue
+			}
+			released[hold.AccountID] = true
+			if err := c.caldav.ReleaseHolds(ctx, event.PollID, hold.AccountID); err != nil {
+				releaseErr = err
+			}
+		}
+		return releaseErr
+	}
+
+	return nil
+}
+
+// deleteHoldEvents removes the holds from the calendar currently registered by the account, on a best effort basis
+// since the account is being replaced or deleted and the rows are dropped anyway.
+func (c *CalDAV) deleteHoldEvents(ctx context.Context, accountID int64, holds []CalDAVHold) {
+	if len(holds) == 0 {
+		return
+	}
+
+	client, _, err := c.Client(ctx, accountID)
+	if err != nil {
+		return
+	}
+	for _, hold := range holds {
+		if err := client.DeleteEvent(ctx, hold.Href); err != nil {
+			logger.Warn("failed to delete caldav hold", zap.String("href", hold.Href), zap.Error(err))
+		}
+	}
+}
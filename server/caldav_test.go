@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newCalDAVStandIn serves a single calendar collection at /calendars/jane/personal/.
+func newCalDAVStandIn(t *testing.T) (*httptest.Server, map[string]string) {
+	var mutex sync.Mutex
+	events := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "jane" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/calendars/jane/personal/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.Method {
+		case "PROPFIND":
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">` +
+				`<d:response><d:href>/calendars/jane/personal/</d:href><d:propstat><d:prop>` +
+				`<d:resourcetype><d:collection/><cal:calendar/></d:resourcetype>` +
+				`</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`))
+		case "REPORT":
+			if r.Header.Get("Depth") != "1" || !strings.Contains(string(body), `start="20240506T080000Z" end="20240508T090000Z"`) {
+				t.Errorf("Unexpected free-busy-query %s", body)
+			}
+			w.Header().Set("Content-Type", "text/calendar")
+			w.Write([]byte("BEGIN:VCALENDAR\r\nBEGIN:VFREEBUSY\r\n" +
+				"FREEBUSY;FBTYPE=BUSY:20240506T083000Z/PT1H,20240507T120000Z/20240507T130000Z\r\n" +
+				"FREEBUSY;FBTYPE=FREE:20240508T080000Z/PT1H\r\n" +
+				"END:VFREEBUSY\r\nEND:VCALENDAR\r\n"))
+		case http.MethodPut:
+			_, exists := events[r.URL.Path]
+			events[r.URL.Path] = string(body)
+			if exists {
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				w.WriteHeader(http.StatusCreated)
+			}
+		case http.MethodDelete:
+			if _, exists := events[r.URL.Path]; !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(events, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	return server, events
+}
+
+func TestCalDAVClient(t *testing.T) {
+	server, events := newCalDAVStandIn(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	internal, _ := NewCalDAVClient(server.URL+"/calendars/jane/personal", "jane", "secret")
+	if err := internal.Check(ctx); err == nil {
+		t.Errorf("Expected the loopback address to be refused unless allowed")
+	}
+
+	defer func(networks []*net.IPNet) { calendarAllowedNetworks = networks }(calendarAllowedNetworks)
+	calendarAllowedNetworks, _ = ParseCalendarAllowedNetworks("127.0.0.1")
+
+	client, err := NewCalDAVClient(server.URL+"/calendars/jane/personal", "jane", "secret")
+	if err != nil {
+		t.Fatalf("Expected client, but got %s", err)
+	}
+	if err := client.Check(ctx); err != nil {
+		t.Errorf("Expected calendar to be accessible, but got %s", err)
+	}
+
+	wrongPassword, _ := NewCalDAVClient(server.URL+"/calendars/jane/personal/", "jane", "wrong")
+	if err := wrongPassword.Check(ctx); err == nil {
+		t.Errorf("Expected wrong credentials to be rejected")
+	}
+
+	start := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	poll := Poll{ID: "poll", PollBase: PollBase{Title: "Planning", Options: []PollOption{
+		{ID: "o1", Start: start, End: start.Add(time.Hour)},
+		{ID: "o2", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+		{ID: "o3", Start: start.Add(28 * time.Hour), End: start.Add(29 * time.Hour)},
+		{ID: "o4", Start: start.Add(48 * time.Hour), End: start.Add(49 * time.Hour)},
+	}}}
+
+	from, to := optionsWindow(poll.Options)
+	busy, err := client.FreeBusy(ctx, from, to)
+	if err != nil {
+		t.Fatalf("Expected busy periods, but got %s", err)
+	}
+	expected := []OptionAvailability{{"o1", Unavailable}, {"o2", Available}, {"o3", Unavailable}, {"o4", Available}}
+	if availabilities := SuggestAvailabilities(poll.Options, busy); !reflect.DeepEqual(availabilities, expected) {
+		t.Errorf("Expected %v, but got %v", expected, availabilities)
+	}
+
+	href, err := client.PutEvent(ctx, caldavHoldUID(poll.ID, "o2"), CalDAVHoldICS(poll, poll.Options[1], start))
+	if err != nil {
+		t.Fatalf("Expected hold to be created, but got %s", err)
+	}
+	hold := events["/calendars/jane/personal/poll-o2-hold@roodle.ics"]
+	if !strings.Contains(hold, "STATUS:TENTATIVE") || !strings.Contains(hold, "DTSTART:20240507T080000Z") {
+		t.Errorf("Unexpected hold %q", hold)
+	}
+
+	if err := client.DeleteEvent(ctx, href); err != nil || len(events) != 0 {
+		t.Errorf("Expected hold to be deleted, but got %v (%d events)", err, len(events))
+	}
+	if err := client.DeleteEvent(ctx, href); err != nil {
+		t.Errorf("Expected deleting a missing hold to succeed, but got %s", err)
+	}
+	if err := client.DeleteEvent(ctx, "http://elsewhere.test/event.ics"); err == nil {
+		t.Errorf("Expected deleting an event outside the calendar to fail")
+	}
+}
+
+func TestWithoutHolds(t *testing.T) {
+	start := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	poll := Poll{ID: "poll", PollBase: PollBase{Options: []PollOption{
+		{ID: "o1", Start: start, End: start.Add(time.Hour)},
+		{ID: "o2", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+	}}}
+	busy := []BusyPeriod{
+		{Start: start, End: start.Add(time.Hour)},
+		{Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+	}
+	holds := []CalDAVHold{{PollID: "poll", AccountID: 1, OptionID: "o1"}, {PollID: "poll", AccountID: 2, OptionID: "o2"}}
+
+	remaining := withoutHolds(busy, poll, 1, holds)
+	if len(remaining) != 1 || !remaining[0].Start.Equal(busy[1].Start) {
+		t.Errorf("Expected only the busy period of o2, but got %v", remaining)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	COMMENT_MAX_LENGTH     = 5000
+	COMMENTS_PAGE_SIZE     = 50
+	COMMENTS_MAX_PAGE_SIZE = 200
+)
+
+type CommentRequest struct {
+	Body     string `json:"body"`
+	OptionID string `json:"option_id"`
+}
+
+// Validate trims the body and checks the referenced option belongs to the poll.
+func (r *CommentRequest) Validate(poll Poll) error {
+	r.Body = strings.TrimSpace(r.Body)
+	if r.Body == "" {
+		return fmt.Errorf("missing body")
+	}
+	if utf8.RuneCountInString(r.Body) > COMMENT_MAX_LENGTH {
+		return fmt.Errorf("body too long, the maximum is %d characters", COMMENT_MAX_LENGTH)
+	}
+
+	if r.OptionID != "" {
+		found := false
+		for _, option := range poll.Options {
+			found = found || option.ID == r.OptionID
+		}
+		if !found {
+			return fmt.Errorf("invalid option %s", r.OptionID)
+		}
+	}
+
+	return nil
+}
+
+// getVisiblePoll loads the poll from the path for an account allowed to see it, see canViewPoll.
+func (a *APIServer) getVisiblePoll(ctx *gin.Context, accountID int64) (Poll, bool) {
+	pollID, err := getPathParam(ctx, "id")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return Poll{}, false
+	}
+
+	poll, err := GetPoll(ctx, a.db, pollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, false
+	}
+
+	visible, err := a.canViewPoll(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+	if !visible {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, false
+	}
+
+	return poll, true
+}
+
+// getPollComment loads the comment from the path, making sure it belongs to the poll.
+func (a *APIServer) getPollComment(ctx *gin.Context, poll Poll) (PollComment, bool) {
+	commentID, err := strconv.ParseInt(ctx.Params.ByName("commentID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter commentID"})
+		return PollComment{}, false
+	}
+
+	comment, err := GetPollComment(ctx, a.db, poll.ID, commentID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return PollComment{}, false
+	}
+	if comment.ID == 0 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return PollComment{}, false
+	}
+
+	return comment, true
+}
+
+// canModerateComments tells if the account organizes the poll, as its owner or an editor.
+func (a *APIServer) canModerateComments(ctx *gin.Context, poll Poll, accountID int64) (bool, error) {
+	role, err := GetPollRole(ctx, a.db, poll.ID, accountID)
+	if err != nil {
+		return false, err
+	}
+
+	return HasPollRole(role, PollEditor), nil
+}
+
+// checkCommentsOpen rejects changes from participants once an organizer locked the thread, organizers can still moderate.
+func (a *APIServer) checkCommentsOpen(ctx *gin.Context, poll Poll, accountID int64) bool {
+	locked, err := GetPollCommentsLocked(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return false
+	}
+	if !locked {
+		return true
+	}
+
+	moderator, err := a.canModerateComments(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return false
+	}
+	if !moderator {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "comments are locked"})
+		return false
+	}
+
+	return true
+}
+
+func (a *APIServer) listPollComments(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	after, limit, err := parsePage(ctx.Request.URL.Query(), COMMENTS_PAGE_SIZE, COMMENTS_MAX_PAGE_SIZE)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fetching one more comment than requested tells if there is a next page.
+	comments, err := ListPollComments(ctx, a.db, poll.ID, after, limit+1)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	locked, err := GetPollCommentsLocked(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	response := gin.H{"data": comments, "locked": locked}
+	if len(comments) > limit {
+		response["data"] = comments[:limit]
+		response["next"] = strconv.FormatInt(comments[limit-1].ID, 10)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (a *APIServer) newPollComment(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	request := CommentRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := request.Validate(poll); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !a.checkCommentsOpen(ctx, poll, accountID) {
+		return
+	}
+
+	comment, err := NewPollComment(ctx, a.db, PollComment{PollID: poll.ID, AccountID: accountID, OptionID: request.OptionID, Body: request.Body})
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	account, err := GetAccountByID(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	comment.AccountEmail = account.Email
+
+	ctx.JSON(http.StatusOK, gin.H{"data": comment})
+}
+
+// editPollComment changes the body of a comment, only its author can edit it.
+func (a *APIServer) editPollComment(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+	comment, ok := a.getPollComment(ctx, poll)
+	if !ok {
+		return
+	}
+	if comment.AccountID != accountID {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only the author can edit a comment"})
+		return
+	}
+
+	request := CommentRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	// The option a comment refers to does not change when editing it.
+	request.OptionID = ""
+	if err := request.Validate(poll); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !a.checkCommentsOpen(ctx, poll, accountID) {
+		return
+	}
+
+	comment, err = UpdatePollComment(ctx, a.db, poll.ID, comment.ID, request.Body)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": comment})
+}
+
+// deletePollComment removes a comment, either by its author or by an organizer of the poll moderating the thread.
+func (a *APIServer) deletePollComment(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+	comment, ok := a.getPollComment(ctx, poll)
+	if !ok {
+		return
+	}
+	if comment.AccountID != accountID {
+		moderator, err := a.canModerateComments(ctx, poll, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if !moderator {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only the author or a poll organizer can delete a comment"})
+			return
+		}
+	}
+
+	if !a.checkCommentsOpen(ctx, poll, accountID) {
+		return
+	}
+
+	err := DeletePollComment(ctx, a.db, poll.ID, comment.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": comment})
+}
+
+type LockCommentsRequest struct {
+	Locked bool `json:"locked"`
+}
+
+func (a *APIServer) lockPollComments(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	request := LockCommentsRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	err = SetPollCommentsLocked(ctx, a.db, accountID, poll.ID, request.Locked)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": request})
+}
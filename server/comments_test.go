@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommentRequestValidate(t *testing.T) {
+	poll := Poll{ID: "poll", PollBase: PollBase{Options: []PollOption{{ID: "o1"}}}}
+
+	request := CommentRequest{Body: "  Works for me  ", OptionID: "o1"}
+	if err := request.Validate(poll); err != nil || request.Body != "Works for me" {
+		t.Errorf("Expected trimmed valid comment, but got %q (%v)", request.Body, err)
+	}
+
+	testCases := []CommentRequest{
+		{Body: "   "},
+		{Body: strings.Repeat("a", COMMENT_MAX_LENGTH+1)},
+		{Body: "Works for me", OptionID: "o2"},
+	}
+	for _, request := range testCases {
+		if err := request.Validate(poll); err == nil {
+			t.Errorf("Expected %+v to be invalid", request)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	sqltrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -68,6 +71,18 @@ func prepareDB(ctx context.Context, db *sql.DB) error {
 		return err
 	}
 
+	_, err = db.ExecContext(ctx, `ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "country" TEXT NOT NULL DEFAULT '';`)
+	if err != nil {
+		logger.Error("failed to add country column to accounts table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "region" TEXT NOT NULL DEFAULT '';`)
+	if err != nil {
+		logger.Error("failed to add region column to accounts table", zap.Error(err))
+		return err
+	}
+
 	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "polls" (
 		"id"          VARCHAR(12) NOT NULL PRIMARY KEY,
 		"account_id"  BIGSERIAL   NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
@@ -81,6 +96,77 @@ func prepareDB(ctx context.Context, db *sql.DB) error {
 		return err
 	}
 
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "final_option_id" VARCHAR(12);`)
+	if err != nil {
+		logger.Error("failed to add final_option_id column to polls table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "comments_locked" BOOL NOT NULL DEFAULT false;`)
+	if err != nil {
+		logger.Error("failed to add comments_locked column to polls table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "answer_scale" JSONB;`)
+	if err != nil {
+		logger.Error("failed to add answer_scale column to polls table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "created_at" TIMESTAMPTZ NOT NULL DEFAULT now();`)
+	if err != nil {
+		logger.Error("failed to add created_at column to polls table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMPTZ;`)
+	if err != nil {
+		logger.Error("failed to add deleted_at column to polls table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "polls_deleted_idx" ON polls (deleted_at) WHERE deleted_at IS NOT NULL;`)
+	if err != nil {
+		logger.Error("failed to create polls_deleted_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "polls_account_created_idx" ON polls (account_id, created_at);`)
+	if err != nil {
+		logger.Error("failed to create polls_account_created_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "workspaces" (
+		"id"         BIGSERIAL   NOT NULL PRIMARY KEY,
+		"name"       TEXT        NOT NULL,
+		"settings"   JSONB       NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create workspaces table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "workspace_members" (
+		"workspace_id" BIGINT      NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		"account_id"   BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"role"         TEXT        NOT NULL,
+		"created_at"   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY(workspace_id, account_id)
+	);`)
+	if err != nil {
+		logger.Error("failed to create workspace_members table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE "polls" ADD COLUMN IF NOT EXISTS "workspace_id" BIGINT REFERENCES workspaces(id) ON DELETE SET NULL;`)
+	if err != nil {
+		logger.Error("failed to add workspace_id column to polls table", zap.Error(err))
+		return err
+	}
+
 	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_account_availability" (
 		"poll_id"        VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
 		"account_id"     BIGSERIAL   NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
@@ -92,9 +178,312 @@ func prepareDB(ctx context.Context, db *sql.DB) error {
 		return err
 	}
 
+	_, err = db.ExecContext(ctx, `ALTER TABLE "poll_account_availability" ADD COLUMN IF NOT EXISTS "auto_filled" BOOL NOT NULL DEFAULT false;`)
+	if err != nil {
+		logger.Error("failed to add auto_filled column to poll_account_availability table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_events" (
+		"id"           BIGSERIAL   NOT NULL PRIMARY KEY,
+		"poll_id"      VARCHAR(12) NOT NULL,
+		"type"         TEXT        NOT NULL,
+		"payload"      JSONB       NOT NULL,
+		"created_at"   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		"available_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		"attempts"     INT         NOT NULL DEFAULT 0,
+		"delivered_at" TIMESTAMPTZ,
+		"last_error"   TEXT
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_events table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "poll_events_pending_idx"
+		ON poll_events (available_at) WHERE delivered_at IS NULL;`)
+	if err != nil {
+		logger.Error("failed to create poll_events_pending_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_event_deliveries" (
+		"event_id"     BIGINT      NOT NULL REFERENCES poll_events(id) ON DELETE CASCADE,
+		"consumer"     TEXT        NOT NULL,
+		"delivered_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY(event_id, consumer)
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_event_deliveries table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "slack_messages" (
+		"poll_id"    VARCHAR(12) NOT NULL,
+		"channel_id" TEXT        NOT NULL,
+		"ts"         TEXT        NOT NULL,
+		PRIMARY KEY(poll_id, channel_id, ts)
+	);`)
+	if err != nil {
+		logger.Error("failed to create slack_messages table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "notification_channels" (
+		"id"             BIGSERIAL   NOT NULL PRIMARY KEY,
+		"account_id"     BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"poll_id"        VARCHAR(12) REFERENCES polls(id) ON DELETE CASCADE,
+		"kind"           TEXT        NOT NULL,
+		"url"            TEXT        NOT NULL,
+		"vote_threshold" INT         NOT NULL DEFAULT 0
+	);`)
+	if err != nil {
+		logger.Error("failed to create notification_channels table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "notification_deliveries" (
+		"channel_id" BIGINT NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+		"event_id"   BIGINT NOT NULL,
+		PRIMARY KEY(channel_id, event_id)
+	);`)
+	if err != nil {
+		logger.Error("failed to create notification_deliveries table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "notification_thresholds" (
+		"channel_id" BIGINT      NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		PRIMARY KEY(channel_id, poll_id)
+	);`)
+	if err != nil {
+		logger.Error("failed to create notification_thresholds table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_invites" (
+		"id"         BIGSERIAL   NOT NULL PRIMARY KEY,
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"email"      TEXT        NOT NULL,
+		"token"      VARCHAR(32) NOT NULL UNIQUE,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE(poll_id, email)
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_invites table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "vote_link_nonces" (
+		"nonce"      VARCHAR(16) NOT NULL PRIMARY KEY,
+		"invite_id"  BIGINT      NOT NULL REFERENCES poll_invites(id) ON DELETE CASCADE,
+		"expires_at" TIMESTAMPTZ NOT NULL,
+		"used_at"    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create vote_link_nonces table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_rsvps" (
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"email"      TEXT        NOT NULL,
+		"status"     TEXT        NOT NULL,
+		"updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY(poll_id, email)
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_rsvps table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "calendar_feeds" (
+		"account_id" BIGINT      NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+		"token"      VARCHAR(32) NOT NULL UNIQUE,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create calendar_feeds table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "google_calendar_tokens" (
+		"account_id"    BIGINT      NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+		"refresh_token" BYTEA       NOT NULL,
+		"scopes"        TEXT        NOT NULL,
+		"updated_at"    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create google_calendar_tokens table", zap.Error(err))
+		return err
+	}
+
+	// No reference to polls, the event is deleted after the poll is.
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "google_calendar_events" (
+		"poll_id"    VARCHAR(12) NOT NULL PRIMARY KEY,
+		"account_id" BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"event_id"   TEXT        NOT NULL
+	);`)
+	if err != nil {
+		logger.Error("failed to create google_calendar_events table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "caldav_accounts" (
+		"account_id" BIGINT NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+		"url"        TEXT   NOT NULL,
+		"username"   TEXT   NOT NULL,
+		"password"   BYTEA  NOT NULL,
+		"holds"      BOOL   NOT NULL DEFAULT false
+	);`)
+	if err != nil {
+		logger.Error("failed to create caldav_accounts table", zap.Error(err))
+		return err
+	}
+
+	// No reference to polls, the holds are removed after the poll is deleted.
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "caldav_holds" (
+		"poll_id"    VARCHAR(12) NOT NULL,
+		"account_id" BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"option_id"  VARCHAR(12) NOT NULL,
+		"href"       TEXT        NOT NULL,
+		PRIMARY KEY(poll_id, account_id, option_id)
+	);`)
+	if err != nil {
+		logger.Error("failed to create caldav_holds table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "availability_profiles" (
+		"account_id" BIGINT NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+		"profile"    JSONB  NOT NULL
+	);`)
+	if err != nil {
+		logger.Error("failed to create availability_profiles table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_members" (
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"account_id" BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"role"       TEXT        NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY(poll_id, account_id)
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_members table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_comments" (
+		"id"         BIGSERIAL   NOT NULL PRIMARY KEY,
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"account_id" BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"option_id"  VARCHAR(12),
+		"body"       TEXT        NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+		"edited_at"  TIMESTAMPTZ
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_comments table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "poll_comments_poll_idx" ON poll_comments (poll_id, id);`)
+	if err != nil {
+		logger.Error("failed to create poll_comments_poll_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_templates" (
+		"id"           BIGSERIAL   NOT NULL PRIMARY KEY,
+		"account_id"   BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+		"name"         TEXT        NOT NULL,
+		"title"        TEXT        NOT NULL,
+		"description"  TEXT        NOT NULL,
+		"location"     TEXT        NOT NULL,
+		"duration"     INT         NOT NULL,
+		"answer_scale" JSONB       NOT NULL DEFAULT '[]',
+		"invites"      JSONB       NOT NULL DEFAULT '[]',
+		"created_at"   TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_templates table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "poll_templates_account_idx" ON poll_templates (account_id);`)
+	if err != nil {
+		logger.Error("failed to create poll_templates_account_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_audit" (
+		"id"         BIGSERIAL   NOT NULL PRIMARY KEY,
+		"poll_id"    VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"actor_id"   BIGINT      REFERENCES accounts(id) ON DELETE SET NULL,
+		"action"     TEXT        NOT NULL,
+		"subject_id" BIGINT      REFERENCES accounts(id) ON DELETE SET NULL,
+		"old"        JSONB,
+		"new"        JSONB,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_audit table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "poll_audit_poll_idx" ON poll_audit (poll_id, id);`)
+	if err != nil {
+		logger.Error("failed to create poll_audit_poll_idx index", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "poll_offline_votes" (
+		"id"             BIGSERIAL   NOT NULL PRIMARY KEY,
+		"poll_id"        VARCHAR(12) NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		"name"           TEXT        NOT NULL,
+		"availabilities" JSONB       NOT NULL,
+		"recorded_by"    BIGINT      REFERENCES accounts(id) ON DELETE SET NULL,
+		"created_at"     TIMESTAMPTZ NOT NULL DEFAULT now(),
+		"updated_at"     TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		logger.Error("failed to create poll_offline_votes table", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "poll_offline_votes_poll_idx" ON poll_offline_votes (poll_id);`)
+	if err != nil {
+		logger.Error("failed to create poll_offline_votes_poll_idx index", zap.Error(err))
+		return err
+	}
+
+	fullTextSearch = supportsFullTextSearch(ctx, db)
+	if fullTextSearch {
+		_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "polls_search_idx" ON polls USING GIN (`+pollSearchDocument+`);`)
+		if err != nil {
+			logger.Error("failed to create polls_search_idx index", zap.Error(err))
+			return err
+		}
+	}
+
 	return nil
 }
 
+// supportsFullTextSearch tells if the database has the Postgres full-text search functions, CockroachDB only has part of them.
+func supportsFullTextSearch(ctx context.Context, db *sql.DB) bool {
+	var rank float64
+	err := db.QueryRowContext(ctx, `SELECT ts_rank(to_tsvector('simple', 'roodle'), websearch_to_tsquery('simple', 'roodle'));`).Scan(&rank)
+	if err != nil {
+		logger.Info("full-text search is not supported, falling back to pattern matching", zap.Error(err))
+		return false
+	}
+
+	return true
+}
+
 func NewAccount(ctx context.Context, db *sql.DB, account Account) (Account, error) {
 	sqlStatement := `
 INSERT INTO accounts (email, username, name)
@@ -130,87 +519,308 @@ func GetAccount(ctx context.Context, db *sql.DB, accountEmail string) (int64, er
 	return accountID, nil
 }
 
-func NewPoll(ctx context.Context, db *sql.DB, accountID int64, poll Poll) (Poll, error) {
-	poll.ID = randomAlphanumeric(12)
+// GetAccountByID returns the account with the id, or an empty account when it does not exist.
+func GetAccountByID(ctx context.Context, db *sql.DB, accountID int64) (Account, error) {
+	account := Account{ID: accountID}
+	err := db.
+		QueryRowContext(ctx, "SELECT email, username, name FROM accounts WHERE id = $1", accountID).
+		Scan(&account.Email, &account.Username, &account.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no account found for id %d\n", accountID)
+			return Account{}, nil
+		}
 
-	sqlStatement := `
-INSERT INTO polls (id, account_id, title, description, location, options)
-VALUES ($1, $2, $3, $4, $5, $6);`
+		logger.Error("failed to retrieve account", zap.Error(err))
+		return Account{}, err
+	}
+
+	return account, nil
+}
+
+// GetOrCreateAccount returns the id of the account with the same email, creating it when missing.
+func GetOrCreateAccount(ctx context.Context, db *sql.DB, account Account) (int64, error) {
+	accountID, err := GetAccount(ctx, db, account.Email)
+	if err != nil {
+		return -1, err
+	}
+	if accountID != -1 {
+		return accountID, nil
+	}
+
+	account, err = NewAccount(ctx, db, account)
+	if err != nil {
+		return -1, err
+	}
 
+	return account.ID, nil
+}
+
+func NewPoll(ctx context.Context, db *sql.DB, accountID int64, poll Poll) (Poll, error) {
 	for idx := range poll.Options {
 		poll.Options[idx].ID = randomAlphanumeric(12)
 	}
 
-	marshaledOptions, err := json.Marshal(poll.Options)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("failed to marshal poll options", zap.Error(err))
+		logger.Error("failed to start transaction", zap.Error(err))
 		return Poll{}, err
 	}
-	_, err = db.ExecContext(ctx, sqlStatement, poll.ID, accountID, poll.Title, poll.Description, poll.Location, string(marshaledOptions))
+	defer tx.Rollback()
+
+	poll, err = insertPoll(ctx, tx, accountID, poll)
 	if err != nil {
-		logger.Error("failed to create poll", zap.Error(err))
+		return Poll{}, err
+	}
+
+	err = insertPollEvent(ctx, tx, poll.ID, PollCreatedEvent, PollEventPayload{AccountID: accountID, Poll: poll})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll creation", zap.Error(err))
 		return Poll{}, err
 	}
 
 	return poll, nil
 }
 
-func GetPoll(ctx context.Context, db *sql.DB, pollID string) (Poll, error) {
-	sqlStatement := `SELECT title, description, location, jsonb_pretty(options) AS options
-		FROM polls
-		WHERE id = $1;`
+// NewPollWithInvites creates the poll together with its invites, members and comments lock in one transaction, so a
+// poll created from a template or cloned from another one is never left half copied.
+func NewPollWithInvites(ctx context.Context, db *sql.DB, accountID int64, poll Poll, emails []string, members []PollMember, commentsLocked bool) (Poll, error) {
+	for idx := range poll.Options {
+		poll.Options[idx].ID = randomAlphanumeric(12)
+	}
 
-	var title string
-	var description string
-	var location string
-	var options string
-	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("failed to retrieve poll", zap.Error(err))
+		logger.Error("failed to start transaction", zap.Error(err))
 		return Poll{}, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	exists := rows.Next()
-	if !exists {
-		logger.Debugf("no poll found for id %s\n", pollID)
-		return Poll{}, nil
+	poll, err = insertPoll(ctx, tx, accountID, poll)
+	if err != nil {
+		return Poll{}, err
 	}
-	err = rows.Scan(&title, &description, &location, &options)
-	if err := rows.Err(); err != nil {
-		logger.Error("failed to read poll fields", zap.Error(err))
+
+	err = insertPollInvites(ctx, tx, poll.ID, emails)
+	if err != nil {
 		return Poll{}, err
 	}
 
-	pollOptions := []PollOption{}
-	err = json.Unmarshal([]byte(options), &pollOptions)
+	for _, member := range members {
+		err = setPollMember(ctx, tx, accountID, poll.ID, member.AccountID, member.Role)
+		if err != nil {
+			return Poll{}, err
+		}
+	}
+
+	if commentsLocked {
+		err = setPollCommentsLocked(ctx, tx, accountID, poll.ID, true)
+		if err != nil {
+			return Poll{}, err
+		}
+	}
+
+	err = insertPollEvent(ctx, tx, poll.ID, PollCreatedEvent, PollEventPayload{AccountID: accountID, Poll: poll})
 	if err != nil {
-		logger.Error("failed to unmarshal poll options", zap.Error(err))
+		return Poll{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll creation", zap.Error(err))
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+// NewImportedPoll creates a poll brought from another tool together with its votes, in one transaction.
+// The options keep their ids, so the votes can answer them. The audit trail records the import,
+// but no events are queued: consumers would notify, sync and relay the votes of a poll that is only moving.
+func NewImportedPoll(ctx context.Context, db *sql.DB, accountID int64, poll Poll, votes []PollAccountAvailability) (Poll, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Poll{}, err
+	}
+	defer tx.Rollback()
+
+	poll, err = insertPoll(ctx, tx, accountID, poll)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	for _, vote := range votes {
+		vote.PollID = poll.ID
+		if vote.AccountID == 0 {
+			_, err = insertOfflineVote(ctx, tx, accountID, vote)
+			if err != nil {
+				return Poll{}, err
+			}
+			continue
+		}
+
+		marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+		if err != nil {
+			logger.Error("failed to marshal vote availabilities", zap.Error(err))
+			return Poll{}, err
+		}
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO poll_account_availability (account_id, poll_id, availabilities)
+VALUES ($1, $2, $3);`, vote.AccountID, vote.PollID, marshaledAvailabilities)
+		if err != nil {
+			logger.Error("failed to create vote", zap.Error(err))
+			return Poll{}, err
+		}
+
+		err = insertAudit(ctx, tx, voteAudit(accountID, vote, nil))
+		if err != nil {
+			return Poll{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll import", zap.Error(err))
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+// insertPoll creates the poll with a new id, its options already have theirs.
+func insertPoll(ctx context.Context, tx *sql.Tx, accountID int64, poll Poll) (Poll, error) {
+	poll.ID = randomAlphanumeric(12)
+
+	sqlStatement := `
+INSERT INTO polls (id, account_id, title, description, location, options, workspace_id, answer_scale)
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), $8);`
+
+	marshaledOptions, err := json.Marshal(poll.Options)
+	if err != nil {
+		logger.Error("failed to marshal poll options", zap.Error(err))
+		return Poll{}, err
+	}
+
+	var marshaledAnswerScale sql.NullString
+	if len(poll.AnswerScale) > 0 {
+		marshaled, err := json.Marshal(poll.AnswerScale)
+		if err != nil {
+			logger.Error("failed to marshal poll answer scale", zap.Error(err))
+			return Poll{}, err
+		}
+		marshaledAnswerScale = sql.NullString{String: string(marshaled), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStatement, poll.ID, accountID, poll.Title, poll.Description, poll.Location, string(marshaledOptions), poll.WorkspaceID, marshaledAnswerScale)
+	if err != nil {
+		logger.Error("failed to create poll", zap.Error(err))
+		return Poll{}, err
+	}
+
+	poll.AccountID = accountID
+
+	err = insertPollAudit(ctx, tx, poll.ID, accountID, AuditPollCreated, 0, nil, poll)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+func GetPoll(ctx context.Context, db *sql.DB, pollID string) (Poll, error) {
+	sqlStatement := `SELECT account_id, title, description, location, jsonb_pretty(options) AS options, COALESCE(final_option_id, ''),
+			COALESCE(workspace_id, 0), COALESCE(answer_scale, '[]')
+		FROM polls
+		WHERE id = $1 AND deleted_at IS NULL;`
+
+	var accountID int64
+	var workspaceID int64
+	var title string
+	var description string
+	var location string
+	var options string
+	var finalOptionID string
+	var answerScale string
+	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve poll", zap.Error(err))
+		return Poll{}, err
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+	if !exists {
+		logger.Debugf("no poll found for id %s\n", pollID)
+		return Poll{}, nil
+	}
+	err = rows.Scan(&accountID, &title, &description, &location, &options, &finalOptionID, &workspaceID, &answerScale)
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to read poll fields", zap.Error(err))
+		return Poll{}, err
+	}
+
+	pollOptions := []PollOption{}
+	err = json.Unmarshal([]byte(options), &pollOptions)
+	if err != nil {
+		logger.Error("failed to unmarshal poll options", zap.Error(err))
+		return Poll{}, err
+	}
+
+	pollAnswerScale := []OptionAnswer{}
+	err = json.Unmarshal([]byte(answerScale), &pollAnswerScale)
+	if err != nil {
+		logger.Error("failed to unmarshal poll answer scale", zap.Error(err))
 		return Poll{}, err
 	}
 
 	return Poll{
-		ID: pollID,
+		ID:        pollID,
+		AccountID: accountID,
 		PollBase: PollBase{
 			Title:       title,
 			Description: description,
 			Location:    location,
 			Options:     pollOptions,
 		},
+		FinalOptionID: finalOptionID,
+		WorkspaceID:   workspaceID,
+		AnswerScale:   pollAnswerScale,
 	}, nil
 }
 
+// ListPolls returns the polls the account owns or co-organizes as an editor.
 func ListPolls(ctx context.Context, db *sql.DB, accountID int64) ([]Poll, error) {
-	sqlStatement := `SELECT id, title, description, location, jsonb_pretty(options) AS options
+	return listPolls(ctx, db, `account_id = $1 OR id IN (SELECT poll_id FROM poll_members WHERE account_id = $1 AND role = 'editor')`, accountID)
+}
+
+// ListWorkspacePolls returns the polls shared in the workspace.
+func ListWorkspacePolls(ctx context.Context, db *sql.DB, workspaceID int64) ([]Poll, error) {
+	return listPolls(ctx, db, `workspace_id = $1`, workspaceID)
+}
+
+// listPolls returns the polls matching the condition, leaving out the polls in the trash.
+func listPolls(ctx context.Context, db *sql.DB, condition string, args ...interface{}) ([]Poll, error) {
+	sqlStatement := `SELECT id, account_id, title, description, location, jsonb_pretty(options) AS options, COALESCE(final_option_id, ''),
+			COALESCE(workspace_id, 0), COALESCE(answer_scale, '[]')
 		FROM polls
-		WHERE account_id = $1;`
+		WHERE deleted_at IS NULL AND (` + condition + `);`
 
 	var id string
+	var ownerID int64
+	var workspaceID int64
 	var title string
 	var description string
 	var location string
 	var options string
-	rows, err := db.QueryContext(ctx, sqlStatement, accountID)
+	var finalOptionID string
+	var answerScale string
+	rows, err := db.QueryContext(ctx, sqlStatement, args...)
 	if err != nil {
 		logger.Error("failed to retrieve polls", zap.Error(err))
 		return nil, err
@@ -219,7 +829,7 @@ func ListPolls(ctx context.Context, db *sql.DB, accountID int64) ([]Poll, error)
 
 	polls := []Poll{}
 	for rows.Next() {
-		err = rows.Scan(&id, &title, &description, &location, &options)
+		err = rows.Scan(&id, &ownerID, &title, &description, &location, &options, &finalOptionID, &workspaceID, &answerScale)
 		if err := rows.Err(); err != nil {
 			logger.Error("failed to read poll fields", zap.Error(err))
 			continue
@@ -232,22 +842,233 @@ func ListPolls(ctx context.Context, db *sql.DB, accountID int64) ([]Poll, error)
 			continue
 		}
 
+		pollAnswerScale := []OptionAnswer{}
+		err = json.Unmarshal([]byte(answerScale), &pollAnswerScale)
+		if err != nil {
+			logger.Error("failed to unmarshal poll answer scale", zap.Error(err))
+			continue
+		}
+
 		polls = append(polls, Poll{
-			ID: id,
+			ID:        id,
+			AccountID: ownerID,
 			PollBase: PollBase{
 				Title:       title,
 				Description: description,
 				Location:    location,
 				Options:     pollOptions,
 			},
+			FinalOptionID: finalOptionID,
+			WorkspaceID:   workspaceID,
+			AnswerScale:   pollAnswerScale,
 		})
 	}
 
 	return polls, nil
 }
 
+// accountPollsQuery selects the ids of the polls the account $1 owns, organizes, voted in or was invited to.
+const accountPollsQuery = `
+	SELECT id FROM polls WHERE account_id = $1
+	UNION SELECT poll_id FROM poll_members WHERE account_id = $1
+	UNION SELECT poll_id FROM poll_account_availability WHERE account_id = $1
+	UNION SELECT poll_id FROM poll_invites INNER JOIN accounts ON poll_invites.email = accounts.email WHERE accounts.id = $1`
+
+// ListPollSummaries returns a page of the polls the account owns, organizes, voted in or was invited to, see PollListQuery.
+func ListPollSummaries(ctx context.Context, db *sql.DB, accountID int64, query PollListQuery) ([]PollSummary, error) {
+	args := []interface{}{accountID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{}
+	switch query.Role {
+	case POLL_LIST_ROLE_OWNER:
+		conditions = append(conditions, `role = 'owner'`)
+	case POLL_LIST_ROLE_PARTICIPANT:
+		conditions = append(conditions, `role IN ('editor', 'viewer', 'participant')`)
+	case POLL_LIST_ROLE_INVITED:
+		conditions = append(conditions, `role = 'invited'`)
+	}
+	switch query.State {
+	case POLL_LIST_STATE_OPEN:
+		conditions = append(conditions, `final_option_id = ''`)
+	case POLL_LIST_STATE_FINALIZED:
+		conditions = append(conditions, `final_option_id <> ''`)
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, `ends_at > `+arg(query.From))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, `starts_at < `+arg(query.To))
+	}
+	if query.Search != "" {
+		pattern := arg("%" + escapeLikePattern(query.Search) + "%")
+		conditions = append(conditions, `(title ILIKE `+pattern+` OR description ILIKE `+pattern+` OR location ILIKE `+pattern+`)`)
+	}
+
+	sortKey, sortType, direction, comparison := `created_at`, `TIMESTAMPTZ`, `DESC`, `<`
+	switch query.Sort {
+	case POLL_LIST_SORT_TITLE:
+		sortKey, sortType, direction, comparison = `lower(title)`, `TEXT`, `ASC`, `>`
+	case POLL_LIST_SORT_START:
+		sortKey, sortType, direction, comparison = `COALESCE(starts_at, created_at)`, `TIMESTAMPTZ`, `ASC`, `>`
+	}
+	if query.AfterID != "" {
+		conditions = append(conditions, `(`+sortKey+`, id) `+comparison+` (`+arg(query.AfterKey)+`::`+sortType+`, `+arg(query.AfterID)+`)`)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = `WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	sqlStatement := `
+WITH listed AS (
+	SELECT polls.id, polls.title, polls.description, polls.location, COALESCE(polls.final_option_id, '') AS final_option_id,
+		COALESCE(polls.workspace_id, 0) AS workspace_id, polls.created_at, jsonb_array_length(polls.options) AS option_count,
+		(SELECT min((o->>'start')::TIMESTAMPTZ) FROM jsonb_array_elements(polls.options) AS o) AS starts_at,
+		(SELECT max((o->>'end')::TIMESTAMPTZ) FROM jsonb_array_elements(polls.options) AS o) AS ends_at,
+		(SELECT count(*) FROM poll_account_availability AS votes WHERE votes.poll_id = polls.id) AS participants,
+		CASE
+			WHEN polls.account_id = $1 THEN 'owner'
+			WHEN poll_members.role IS NOT NULL THEN poll_members.role
+			WHEN poll_account_availability.account_id IS NOT NULL THEN 'participant'
+			ELSE 'invited'
+		END AS role
+	FROM polls
+	LEFT JOIN poll_members ON poll_members.poll_id = polls.id AND poll_members.account_id = $1
+	LEFT JOIN poll_account_availability ON poll_account_availability.poll_id = polls.id AND poll_account_availability.account_id = $1
+	WHERE polls.deleted_at IS NULL AND polls.id IN (` + accountPollsQuery + `)
+)
+SELECT id, title, location, role, final_option_id, workspace_id, option_count, starts_at, ends_at, participants, created_at, lower(title)
+FROM listed
+` + where + `
+ORDER BY ` + sortKey + ` ` + direction + `, id ` + direction + `
+LIMIT ` + arg(query.Limit) + `;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		logger.Error("failed to list poll summaries", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []PollSummary{}
+	for rows.Next() {
+		var summary PollSummary
+		var startsAt sql.NullTime
+		var endsAt sql.NullTime
+		err := rows.Scan(&summary.ID, &summary.Title, &summary.Location, &summary.Role, &summary.FinalOptionID, &summary.WorkspaceID,
+			&summary.OptionCount, &startsAt, &endsAt, &summary.Participants, &summary.CreatedAt, &summary.SortTitle)
+		if err != nil {
+			logger.Error("failed to scan poll summary", zap.Error(err))
+			return nil, err
+		}
+		if startsAt.Valid {
+			summary.StartsAt = &startsAt.Time
+		}
+		if endsAt.Valid {
+			summary.EndsAt = &endsAt.Time
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll summaries", zap.Error(err))
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// escapeLikePattern makes the wildcards of a search literal in a LIKE pattern.
+func escapeLikePattern(search string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+}
+
+// pollSearchDocument is the weighted full-text document of a poll, titles rank above locations and descriptions.
+const pollSearchDocument = `(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', location), 'B') || setweight(to_tsvector('simple', description), 'C'))`
+
+// SearchPolls ranks the polls the account can see against the query, with Postgres full-text search when available
+// and pattern matching every term otherwise, weighting the fields the same way.
+func SearchPolls(ctx context.Context, db *sql.DB, accountID int64, query PollSearchQuery) ([]PollSearchResult, error) {
+	args := []interface{}{accountID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{`polls.deleted_at IS NULL`, `polls.id IN (` + accountPollsQuery + `
+	UNION SELECT polls.id FROM polls INNER JOIN workspace_members ON polls.workspace_id = workspace_members.workspace_id
+		WHERE workspace_members.account_id = $1)`}
+
+	var rank string
+	if fullTextSearch {
+		tsQuery := `websearch_to_tsquery('simple', ` + arg(query.Text) + `)`
+		rank = `ts_rank(` + pollSearchDocument + `, ` + tsQuery + `)`
+		conditions = append(conditions, pollSearchDocument+` @@ `+tsQuery)
+	} else {
+		ranks := []string{}
+		for _, term := range query.Terms {
+			pattern := arg("%" + escapeLikePattern(term) + "%")
+			ranks = append(ranks, `(CASE WHEN title ILIKE `+pattern+` THEN 1.0 ELSE 0 END)`,
+				`(CASE WHEN location ILIKE `+pattern+` THEN 0.4 ELSE 0 END)`,
+				`(CASE WHEN description ILIKE `+pattern+` THEN 0.2 ELSE 0 END)`)
+			conditions = append(conditions, `(title ILIKE `+pattern+` OR location ILIKE `+pattern+` OR description ILIKE `+pattern+`)`)
+		}
+		for _, term := range query.Excluded {
+			pattern := arg("%" + escapeLikePattern(term) + "%")
+			conditions = append(conditions, `NOT (title ILIKE `+pattern+` OR location ILIKE `+pattern+` OR description ILIKE `+pattern+`)`)
+		}
+		rank = `(` + strings.Join(ranks, ` + `) + `)`
+	}
+
+	if query.Owner != "" {
+		conditions = append(conditions, `lower(accounts.email) = lower(`+arg(query.Owner)+`)`)
+	}
+	switch query.State {
+	case POLL_LIST_STATE_OPEN:
+		conditions = append(conditions, `polls.final_option_id IS NULL`)
+	case POLL_LIST_STATE_FINALIZED:
+		conditions = append(conditions, `polls.final_option_id IS NOT NULL`)
+	}
+
+	sqlStatement := `
+SELECT polls.id, title, description, location, COALESCE(polls.final_option_id, ''), accounts.email, ` + rank + ` AS rank
+FROM polls INNER JOIN accounts ON polls.account_id = accounts.id
+WHERE ` + strings.Join(conditions, ` AND `) + `
+ORDER BY rank DESC, polls.id
+LIMIT ` + arg(query.Limit) + `;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		logger.Error("failed to search polls", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []PollSearchResult{}
+	for rows.Next() {
+		var result PollSearchResult
+		err := rows.Scan(&result.ID, &result.Title, &result.Description, &result.Location, &result.FinalOptionID, &result.OwnerEmail, &result.Rank)
+		if err != nil {
+			logger.Error("failed to scan poll search result", zap.Error(err))
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to search polls", zap.Error(err))
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CountPolls counts the personal polls of the account, polls in a workspace count towards its own quota.
 func CountPolls(ctx context.Context, db *sql.DB, accountID int64) (int64, error) {
-	sqlStatement := `SELECT count(*) FROM polls WHERE account_id = $1;`
+	sqlStatement := `SELECT count(*) FROM polls WHERE account_id = $1 AND workspace_id IS NULL AND deleted_at IS NULL;`
 
 	var count int64
 	err := db.QueryRowContext(ctx, sqlStatement, accountID).Scan(&count)
@@ -261,148 +1082,2337 @@ func CountPolls(ctx context.Context, db *sql.DB, accountID int64) (int64, error)
 
 func DeletePoll(ctx context.Context, db *sql.DB, accountID int64, pollID string) (Poll, error) {
 	sqlStatement := `
-	DELETE FROM polls
-	WHERE account_id = $1 AND id = $2
-	RETURNING (title, description, location, jsonb_pretty(options));`
+	UPDATE polls
+	SET deleted_at = now()
+	WHERE account_id = $1 AND id = $2 AND deleted_at IS NULL
+	RETURNING title, description, location, jsonb_pretty(options);`
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Poll{}, err
+	}
+	defer tx.Rollback()
 
 	var title string
 	var description string
 	var location string
 	var options string
-	rows, err := db.QueryContext(ctx, sqlStatement, accountID, pollID)
+	err = tx.QueryRowContext(ctx, sqlStatement, accountID, pollID).Scan(&title, &description, &location, &options)
 	if err != nil {
-		logger.Error("failed to delete poll", zap.Error(err))
-		return Poll{}, err
-	}
-	defer rows.Close()
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll found for id %s\n", pollID)
+			return Poll{}, nil
+		}
 
-	exists := rows.Next()
-	if !exists {
-		logger.Debugf("no poll found for id %s\n", pollID)
-		return Poll{}, nil
-	}
-	err = rows.Scan(&title, &description, &location, &options)
-	if err := rows.Err(); err != nil {
-		logger.Error("failed to read poll fields", zap.Error(err))
+		logger.Error("failed to delete poll", zap.Error(err))
 		return Poll{}, err
 	}
 
 	pollOptions := []PollOption{}
 	err = json.Unmarshal([]byte(options), &pollOptions)
 	if err != nil {
-		// Poll already deleted at this point so makes no sense to return an error to the user
+		// Poll is moved to the trash with the transaction so makes no sense to return an error to the user
 		// Just logging the error for debug purposes
 		logger.Error("failed to unmarshal poll options", zap.Any("options", options), zap.Error(err))
 	}
 
-	return Poll{
-		ID: pollID,
+	poll := Poll{
+		ID:        pollID,
+		AccountID: accountID,
 		PollBase: PollBase{
 			Title:       title,
 			Description: description,
 			Location:    location,
 			Options:     pollOptions,
 		},
-	}, nil
-}
+	}
 
-func NewVote(ctx context.Context, db *sql.DB, accountID int64, vote PollAccountAvailability) (PollAccountAvailability, error) {
-	sqlStatement := `
-INSERT INTO poll_account_availability (account_id, poll_id, availabilities)
-VALUES ($1, $2, $3)
-ON CONFLICT (account_id, poll_id)
-DO UPDATE SET availabilities = EXCLUDED.availabilities;`
-	marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+	err = insertPollEvent(ctx, tx, pollID, PollDeletedEvent, PollEventPayload{AccountID: accountID, Poll: poll})
 	if err != nil {
-		logger.Error("failed to marshal vote availabilities", zap.Error(err))
-		return PollAccountAvailability{}, err
+		return Poll{}, err
 	}
-	_, err = db.ExecContext(ctx, sqlStatement, accountID, vote.PollID, marshaledAvailabilities)
+
+	err = insertPollAudit(ctx, tx, pollID, accountID, AuditPollDeleted, 0, nil, nil)
 	if err != nil {
-		logger.Error("failed to create vote", zap.Error(err))
-		return PollAccountAvailability{}, err
+		return Poll{}, err
 	}
 
-	vote.AccountID = accountID
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll deletion", zap.Error(err))
+		return Poll{}, err
+	}
 
-	return vote, nil
+	return poll, nil
 }
 
-func GetVote(ctx context.Context, db *sql.DB, accountID int64, pollID string) (PollAccountAvailability, error) {
-	sqlStatement := `SELECT jsonb_pretty(availabilities) AS availabilities
-		FROM poll_account_availability
-		WHERE account_id = $1 AND poll_id = $2;`
+const trashedPollColumns = `id, title, location, COALESCE(workspace_id, 0), jsonb_array_length(options),
+	(SELECT count(*) FROM poll_account_availability WHERE poll_id = polls.id), deleted_at`
 
-	var availabilities string
-	rows, err := db.QueryContext(ctx, sqlStatement, accountID, pollID)
+func scanTrashedPoll(row interface{ Scan(...interface{}) error }) (TrashedPoll, error) {
+	var poll TrashedPoll
+	err := row.Scan(&poll.ID, &poll.Title, &poll.Location, &poll.WorkspaceID, &poll.OptionCount, &poll.Participants, &poll.DeletedAt)
+	return poll, err
+}
+
+// ListTrashedPolls returns the deleted polls of the account, the most recently deleted first.
+func ListTrashedPolls(ctx context.Context, db *sql.DB, accountID int64) ([]TrashedPoll, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+trashedPollColumns+`
+		FROM polls
+		WHERE account_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id;`, accountID)
 	if err != nil {
-		logger.Error("failed to retrieve vote", zap.Error(err))
-		return PollAccountAvailability{}, err
+		logger.Error("failed to list trashed polls", zap.Error(err))
+		return nil, err
 	}
 	defer rows.Close()
 
-	exists := rows.Next()
-	if !exists {
-		logger.Debugf("no vote found for account %d and poll %s\n", accountID, pollID)
-		return PollAccountAvailability{}, nil
+	polls := []TrashedPoll{}
+	for rows.Next() {
+		poll, err := scanTrashedPoll(rows)
+		if err != nil {
+			logger.Error("failed to scan trashed poll", zap.Error(err))
+			return nil, err
+		}
+		polls = append(polls, poll)
 	}
-	err = rows.Scan(&availabilities)
 	if err := rows.Err(); err != nil {
-		logger.Error("failed to read vote fields", zap.Error(err))
-		return PollAccountAvailability{}, err
+		logger.Error("failed to list trashed polls", zap.Error(err))
+		return nil, err
 	}
 
-	optionAvailabilities := []OptionAvailability{}
-	err = json.Unmarshal([]byte(availabilities), &optionAvailabilities)
+	return polls, nil
+}
+
+// GetTrashedPoll returns a deleted poll of the account, with an empty id when it is not in the trash.
+func GetTrashedPoll(ctx context.Context, db *sql.DB, accountID int64, pollID string) (TrashedPoll, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+trashedPollColumns+`
+		FROM polls
+		WHERE account_id = $1 AND id = $2 AND deleted_at IS NOT NULL;`, accountID, pollID)
+	poll, err := scanTrashedPoll(row)
 	if err != nil {
-		logger.Error("failed to unmarshal vote availabilities", zap.Error(err))
-		return PollAccountAvailability{}, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return TrashedPoll{}, nil
+		}
+
+		logger.Error("failed to retrieve trashed poll", zap.Error(err))
+		return TrashedPoll{}, err
 	}
 
-	return PollAccountAvailability{
-		PollID:         pollID,
-		AccountID:      accountID,
-		Availabilities: optionAvailabilities,
-	}, nil
+	return poll, nil
 }
 
-func ListVotes(ctx context.Context, db *sql.DB, pollID string) ([]PollAccountAvailability, error) {
-	sqlStatement := `SELECT account_id, email, jsonb_pretty(availabilities) AS availabilities
-		FROM poll_account_availability INNER JOIN accounts ON poll_account_availability.account_id = accounts.id
-		WHERE poll_id = $1;`
+// RestorePoll takes a poll of the account out of the trash, with its votes.
+func RestorePoll(ctx context.Context, db *sql.DB, accountID int64, pollID string) (Poll, error) {
+	sqlStatement := `
+UPDATE polls
+SET deleted_at = NULL
+WHERE account_id = $1 AND id = $2 AND deleted_at IS NOT NULL
+RETURNING title, description, location, jsonb_pretty(options), COALESCE(final_option_id, ''), COALESCE(workspace_id, 0);`
 
-	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("failed to retrieve votes", zap.Error(err))
-		return nil, err
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Poll{}, err
+	}
+	defer tx.Rollback()
+
+	poll := Poll{ID: pollID, AccountID: accountID}
+	var options string
+	err = tx.QueryRowContext(ctx, sqlStatement, accountID, pollID).
+		Scan(&poll.Title, &poll.Description, &poll.Location, &options, &poll.FinalOptionID, &poll.WorkspaceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no trashed poll found for id %s\n", pollID)
+			return Poll{}, nil
+		}
+
+		logger.Error("failed to restore poll", zap.Error(err))
+		return Poll{}, err
+	}
+
+	err = json.Unmarshal([]byte(options), &poll.Options)
+	if err != nil {
+		logger.Error("failed to unmarshal poll options", zap.Error(err))
+		return Poll{}, err
+	}
+
+	err = insertPollEvent(ctx, tx, pollID, PollRestoredEvent, PollEventPayload{AccountID: accountID, Poll: poll})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = insertPollAudit(ctx, tx, pollID, accountID, AuditPollRestored, 0, nil, nil)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll restore", zap.Error(err))
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+// PurgePoll deletes a poll of the account in the trash for good, the votes and everything else of the poll go with it.
+func PurgePoll(ctx context.Context, db *sql.DB, accountID int64, pollID string) (bool, error) {
+	purged, err := purgePolls(ctx, db, `account_id = $1 AND id = $2 AND deleted_at IS NOT NULL`, accountID, pollID)
+	return purged > 0, err
+}
+
+// PurgeDeletedPolls deletes for good the polls in the trash since before the time.
+func PurgeDeletedPolls(ctx context.Context, db *sql.DB, before time.Time) (int64, error) {
+	return purgePolls(ctx, db, `deleted_at < $1`, before)
+}
+
+// purgePolls deletes the polls matching the condition, along with the Slack messages posted for them, which do not
+// reference the polls since they are kept while the polls are in the trash.
+func purgePolls(ctx context.Context, db *sql.DB, condition string, args ...interface{}) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM slack_messages WHERE poll_id IN (SELECT id FROM polls WHERE `+condition+`);`, args...)
+	if err != nil {
+		logger.Error("failed to delete slack messages", zap.Error(err))
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM polls WHERE `+condition+`;`, args...)
+	if err != nil {
+		logger.Error("failed to purge polls", zap.Error(err))
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to read purged polls", zap.Error(err))
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit transaction", zap.Error(err))
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// FinalizePoll sets the option chosen for the poll, an empty option id reopens the poll.
+// The owner, the editors and the members of the poll workspace can finalize it.
+func FinalizePoll(ctx context.Context, db *sql.DB, accountID int64, pollID string, optionID string) (Poll, error) {
+	sqlStatement := `
+UPDATE polls
+SET final_option_id = NULLIF($3, '')
+WHERE id = $2 AND deleted_at IS NULL AND (account_id = $1 OR EXISTS (
+	SELECT 1 FROM poll_members WHERE poll_id = $2 AND account_id = $1 AND role = 'editor'
+) OR EXISTS (
+	SELECT 1 FROM workspace_members WHERE workspace_id = polls.workspace_id AND account_id = $1
+))
+RETURNING account_id, title, description, location, jsonb_pretty(options), COALESCE(final_option_id, ''), COALESCE(workspace_id, 0);`
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Poll{}, err
+	}
+	defer tx.Rollback()
+
+	var previousOptionID sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT final_option_id FROM polls WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`, pollID).
+		Scan(&previousOptionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll found for id %s\n", pollID)
+			return Poll{}, nil
+		}
+
+		logger.Error("failed to retrieve poll final option", zap.Error(err))
+		return Poll{}, err
+	}
+
+	poll := Poll{ID: pollID}
+	var options string
+	err = tx.QueryRowContext(ctx, sqlStatement, accountID, pollID, optionID).
+		Scan(&poll.AccountID, &poll.Title, &poll.Description, &poll.Location, &options, &poll.FinalOptionID, &poll.WorkspaceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll found for id %s\n", pollID)
+			return Poll{}, nil
+		}
+
+		logger.Error("failed to finalize poll", zap.Error(err))
+		return Poll{}, err
+	}
+
+	err = json.Unmarshal([]byte(options), &poll.Options)
+	if err != nil {
+		logger.Error("failed to unmarshal poll options", zap.Error(err))
+		return Poll{}, err
+	}
+
+	eventType := PollFinalizedEvent
+	if poll.FinalOptionID == "" {
+		eventType = PollReopenedEvent
+	}
+	err = insertPollEvent(ctx, tx, pollID, eventType, PollEventPayload{AccountID: poll.AccountID, Poll: poll})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = insertAudit(ctx, tx, finalizationAudit(accountID, pollID, previousOptionID.String, poll.FinalOptionID))
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll finalization", zap.Error(err))
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+// NewVote saves the answers of the account, the actor is who submitted them: the account itself or an organizer importing them.
+func NewVote(ctx context.Context, db *sql.DB, actorID int64, accountID int64, vote PollAccountAvailability) (PollAccountAvailability, error) {
+	return saveVote(ctx, db, actorID, accountID, vote, nil)
+}
+
+// MergeVote changes the vote of the account with the merge of its current answers, the vote is locked between
+// reading and writing it so concurrent changes to different options are all kept.
+func MergeVote(ctx context.Context, db *sql.DB, accountID int64, pollID string, merge func(availabilities []OptionAvailability) []OptionAvailability) (PollAccountAvailability, error) {
+	return saveVote(ctx, db, accountID, accountID, PollAccountAvailability{PollID: pollID, AccountID: accountID}, merge)
+}
+
+// AutoFillVote saves a provisional vote of the account, marked as auto-filled, only when the account has not voted yet.
+func AutoFillVote(ctx context.Context, db *sql.DB, vote PollAccountAvailability) (bool, error) {
+	marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+	if err != nil {
+		logger.Error("failed to marshal vote availabilities", zap.Error(err))
+		return false, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+INSERT INTO poll_account_availability (account_id, poll_id, availabilities, auto_filled)
+VALUES ($1, $2, $3, true)
+ON CONFLICT (account_id, poll_id) DO NOTHING;`, vote.AccountID, vote.PollID, marshaledAvailabilities)
+	if err != nil {
+		logger.Error("failed to auto-fill vote", zap.Error(err))
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to auto-fill vote", zap.Error(err))
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	vote.AutoFilled = true
+	err = insertPollEvent(ctx, tx, vote.PollID, VoteCastEvent, vote)
+	if err != nil {
+		return false, err
+	}
+
+	err = insertAudit(ctx, tx, voteAudit(vote.AccountID, vote, nil))
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit vote", zap.Error(err))
+		return false, err
+	}
+
+	return true, nil
+}
+
+func saveVote(ctx context.Context, db *sql.DB, actorID int64, accountID int64, vote PollAccountAvailability, merge func(availabilities []OptionAvailability) []OptionAvailability) (PollAccountAvailability, error) {
+	sqlStatement := `
+INSERT INTO poll_account_availability (account_id, poll_id, availabilities, auto_filled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (account_id, poll_id)
+DO UPDATE SET availabilities = EXCLUDED.availabilities, auto_filled = EXCLUDED.auto_filled;`
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	defer tx.Rollback()
+
+	// Merging needs a row to lock, otherwise two first answers of the account would both be merged into an empty vote.
+	created := false
+	if merge != nil {
+		result, err := tx.ExecContext(ctx, `
+INSERT INTO poll_account_availability (account_id, poll_id, availabilities)
+VALUES ($1, $2, '[]')
+ON CONFLICT (account_id, poll_id) DO NOTHING;`, accountID, vote.PollID)
+		if err != nil {
+			logger.Error("failed to create vote", zap.Error(err))
+			return PollAccountAvailability{}, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			logger.Error("failed to create vote", zap.Error(err))
+			return PollAccountAvailability{}, err
+		}
+		created = inserted == 1
+	}
+
+	// The previous answers are kept in the audit trail, the vote itself only holds the latest ones.
+	var previous *auditVote
+	var previousAvailabilities string
+	var previousAutoFilled bool
+	err = tx.QueryRowContext(ctx, `
+SELECT availabilities::TEXT, auto_filled
+FROM poll_account_availability
+WHERE account_id = $1 AND poll_id = $2
+FOR UPDATE;`, accountID, vote.PollID).Scan(&previousAvailabilities, &previousAutoFilled)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("failed to retrieve previous vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	previousVote := auditVote{AutoFilled: previousAutoFilled}
+	if err == nil {
+		err = json.Unmarshal([]byte(previousAvailabilities), &previousVote.Availabilities)
+		if err != nil {
+			logger.Error("failed to unmarshal previous vote availabilities", zap.Error(err))
+			return PollAccountAvailability{}, err
+		}
+		if !created {
+			previous = &previousVote
+		}
+	}
+
+	if merge != nil {
+		vote.Availabilities = merge(previousVote.Availabilities)
+		vote.AutoFilled = false
+	}
+	marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+	if err != nil {
+		logger.Error("failed to marshal vote availabilities", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStatement, accountID, vote.PollID, marshaledAvailabilities, vote.AutoFilled)
+	if err != nil {
+		logger.Error("failed to create vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	vote.AccountID = accountID
+
+	err = insertPollEvent(ctx, tx, vote.PollID, VoteCastEvent, vote)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = insertAudit(ctx, tx, voteAudit(actorID, vote, previous))
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	return vote, nil
+}
+
+func GetVote(ctx context.Context, db *sql.DB, accountID int64, pollID string) (PollAccountAvailability, error) {
+	sqlStatement := `SELECT jsonb_pretty(availabilities) AS availabilities, auto_filled
+		FROM poll_account_availability
+		WHERE account_id = $1 AND poll_id = $2;`
+
+	var availabilities string
+	var autoFilled bool
+	rows, err := db.QueryContext(ctx, sqlStatement, accountID, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+	if !exists {
+		logger.Debugf("no vote found for account %d and poll %s\n", accountID, pollID)
+		return PollAccountAvailability{}, nil
+	}
+	err = rows.Scan(&availabilities, &autoFilled)
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to read vote fields", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	optionAvailabilities := []OptionAvailability{}
+	err = json.Unmarshal([]byte(availabilities), &optionAvailabilities)
+	if err != nil {
+		logger.Error("failed to unmarshal vote availabilities", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	return PollAccountAvailability{
+		PollID:         pollID,
+		AccountID:      accountID,
+		Availabilities: optionAvailabilities,
+		AutoFilled:     autoFilled,
+	}, nil
+}
+
+var ErrOfflineParticipantExists = errors.New("offline participant already exists")
+
+// NewOfflineVote records the answers of a participant without an account, the participant names are unique in a poll.
+func NewOfflineVote(ctx context.Context, db *sql.DB, actorID int64, vote PollAccountAvailability) (PollAccountAvailability, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	defer tx.Rollback()
+
+	vote, err = insertOfflineVote(ctx, tx, actorID, vote)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = insertPollEvent(ctx, tx, vote.PollID, VoteCastEvent, vote)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit offline vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	return vote, nil
+}
+
+func insertOfflineVote(ctx context.Context, tx *sql.Tx, actorID int64, vote PollAccountAvailability) (PollAccountAvailability, error) {
+	marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+	if err != nil {
+		logger.Error("failed to marshal vote availabilities", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO poll_offline_votes (poll_id, name, availabilities, recorded_by)
+SELECT $1, $2, $3, $4
+WHERE NOT EXISTS (SELECT 1 FROM poll_offline_votes WHERE poll_id = $1 AND lower(name) = lower($2))
+RETURNING id;`, vote.PollID, vote.ParticipantName, string(marshaledAvailabilities), actorID).Scan(&vote.OfflineID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PollAccountAvailability{}, ErrOfflineParticipantExists
+		}
+
+		logger.Error("failed to create offline vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	err = insertAudit(ctx, tx, voteAudit(actorID, vote, nil))
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	return vote, nil
+}
+
+// UpdateOfflineVote changes the participant name and the answers of an offline vote, the actor becomes its recorder.
+func UpdateOfflineVote(ctx context.Context, db *sql.DB, actorID int64, vote PollAccountAvailability) (PollAccountAvailability, error) {
+	marshaledAvailabilities, err := json.Marshal(vote.Availabilities)
+	if err != nil {
+		logger.Error("failed to marshal vote availabilities", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	defer tx.Rollback()
+
+	previous := auditVote{}
+	var previousAvailabilities string
+	err = tx.QueryRowContext(ctx, `
+SELECT name, availabilities::TEXT
+FROM poll_offline_votes
+WHERE id = $1 AND poll_id = $2
+FOR UPDATE;`, vote.OfflineID, vote.PollID).Scan(&previous.Participant, &previousAvailabilities)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no offline vote found for id %d\n", vote.OfflineID)
+			return PollAccountAvailability{}, nil
+		}
+
+		logger.Error("failed to retrieve offline vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	err = json.Unmarshal([]byte(previousAvailabilities), &previous.Availabilities)
+	if err != nil {
+		logger.Error("failed to unmarshal offline vote availabilities", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+UPDATE poll_offline_votes
+SET name = $3, availabilities = $4, recorded_by = $5, updated_at = now()
+WHERE id = $1 AND poll_id = $2
+AND NOT EXISTS (SELECT 1 FROM poll_offline_votes WHERE poll_id = $2 AND id <> $1 AND lower(name) = lower($3));`,
+		vote.OfflineID, vote.PollID, vote.ParticipantName, string(marshaledAvailabilities), actorID)
+	if err != nil {
+		logger.Error("failed to update offline vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to retrieve updated offline votes", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+	if updated == 0 {
+		return PollAccountAvailability{}, ErrOfflineParticipantExists
+	}
+
+	err = insertPollEvent(ctx, tx, vote.PollID, VoteCastEvent, vote)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = insertAudit(ctx, tx, voteAudit(actorID, vote, &previous))
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit offline vote", zap.Error(err))
+		return PollAccountAvailability{}, err
+	}
+
+	return vote, nil
+}
+
+// DeleteOfflineVote removes an offline vote, the vote cast event lets the consumers refresh the results.
+func DeleteOfflineVote(ctx context.Context, db *sql.DB, actorID int64, pollID string, offlineID int64) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return false, err
+	}
+	defer tx.Rollback()
+
+	previous := auditVote{}
+	var previousAvailabilities string
+	err = tx.QueryRowContext(ctx, `DELETE FROM poll_offline_votes WHERE id = $1 AND poll_id = $2 RETURNING name, availabilities::TEXT;`,
+		offlineID, pollID).Scan(&previous.Participant, &previousAvailabilities)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		logger.Error("failed to delete offline vote", zap.Error(err))
+		return false, err
+	}
+	err = json.Unmarshal([]byte(previousAvailabilities), &previous.Availabilities)
+	if err != nil {
+		logger.Error("failed to unmarshal offline vote availabilities", zap.Error(err))
+		return false, err
+	}
+
+	err = insertPollEvent(ctx, tx, pollID, VoteCastEvent, PollAccountAvailability{PollID: pollID, OfflineID: offlineID, ParticipantName: previous.Participant})
+	if err != nil {
+		return false, err
+	}
+
+	err = insertAudit(ctx, tx, voteRemovedAudit(actorID, pollID, 0, previous))
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit offline vote deletion", zap.Error(err))
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListVotes returns the votes of the accounts and the offline votes recorded by the organizers.
+func ListVotes(ctx context.Context, db *sql.DB, pollID string) ([]PollAccountAvailability, error) {
+	sqlStatement := `SELECT account_id, email, jsonb_pretty(availabilities) AS availabilities, auto_filled, 0, '', ''
+		FROM poll_account_availability INNER JOIN accounts ON poll_account_availability.account_id = accounts.id
+		WHERE poll_id = $1 AND EXISTS (SELECT 1 FROM polls WHERE id = $1 AND deleted_at IS NULL)
+		UNION ALL
+		SELECT 0, '', jsonb_pretty(availabilities), false, poll_offline_votes.id, name, COALESCE(accounts.email, '')
+		FROM poll_offline_votes LEFT JOIN accounts ON poll_offline_votes.recorded_by = accounts.id
+		WHERE poll_id = $1 AND EXISTS (SELECT 1 FROM polls WHERE id = $1 AND deleted_at IS NULL);`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve votes", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	pollAccountAvailabilities := []PollAccountAvailability{}
+	for rows.Next() {
+		var accountID int64
+		var email string
+		var availabilities string
+		var autoFilled bool
+		var offlineID int64
+		var participantName string
+		var recordedBy string
+		err = rows.Scan(&accountID, &email, &availabilities, &autoFilled, &offlineID, &participantName, &recordedBy)
+		if err := rows.Err(); err != nil {
+			logger.Error("failed to read vote fields", zap.Error(err))
+			continue
+		}
+
+		optionAvailabilities := []OptionAvailability{}
+		err = json.Unmarshal([]byte(availabilities), &optionAvailabilities)
+		if err != nil {
+			logger.Error("failed to unmarshal vote availabilities", zap.Error(err))
+			continue
+		}
+
+		pollAccountAvailabilities = append(pollAccountAvailabilities, PollAccountAvailability{
+			PollID:          pollID,
+			AccountID:       accountID,
+			AccountEmail:    email,
+			Availabilities:  optionAvailabilities,
+			AutoFilled:      autoFilled,
+			OfflineID:       offlineID,
+			ParticipantName: participantName,
+			RecordedBy:      recordedBy,
+		})
+	}
+
+	return pollAccountAvailabilities, nil
+}
+
+func NewSlackMessage(ctx context.Context, db *sql.DB, pollID string, channelID string, ts string) error {
+	sqlStatement := `
+INSERT INTO slack_messages (poll_id, channel_id, ts)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, pollID, channelID, ts)
+	if err != nil {
+		logger.Error("failed to create slack message", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func ListSlackMessages(ctx context.Context, db *sql.DB, pollID string) ([]SlackMessage, error) {
+	sqlStatement := `SELECT channel_id, ts FROM slack_messages WHERE poll_id = $1;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve slack messages", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []SlackMessage{}
+	for rows.Next() {
+		message := SlackMessage{PollID: pollID}
+		err = rows.Scan(&message.ChannelID, &message.TS)
+		if err != nil {
+			logger.Error("failed to read slack message fields", zap.Error(err))
+			continue
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func NewNotificationChannel(ctx context.Context, db *sql.DB, accountID int64, channel NotificationChannel) (NotificationChannel, error) {
+	sqlStatement := `
+INSERT INTO notification_channels (account_id, poll_id, kind, url, vote_threshold)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+RETURNING id;`
+
+	err := db.QueryRowContext(ctx, sqlStatement, accountID, channel.PollID, channel.Kind, channel.URL, channel.VoteThreshold).
+		Scan(&channel.ID)
+	if err != nil {
+		logger.Error("failed to create notification channel", zap.Error(err))
+		return NotificationChannel{}, err
+	}
+
+	channel.AccountID = accountID
+
+	return channel, nil
+}
+
+func scanNotificationChannels(rows *sql.Rows) []NotificationChannel {
+	channels := []NotificationChannel{}
+	for rows.Next() {
+		channel := NotificationChannel{}
+		err := rows.Scan(&channel.ID, &channel.AccountID, &channel.PollID, &channel.Kind, &channel.URL, &channel.VoteThreshold)
+		if err != nil {
+			logger.Error("failed to read notification channel fields", zap.Error(err))
+			continue
+		}
+
+		channels = append(channels, channel)
+	}
+
+	return channels
+}
+
+func ListNotificationChannels(ctx context.Context, db *sql.DB, accountID int64) ([]NotificationChannel, error) {
+	sqlStatement := `SELECT id, account_id, COALESCE(poll_id, ''), kind, url, vote_threshold
+		FROM notification_channels
+		WHERE account_id = $1
+		ORDER BY id;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, accountID)
+	if err != nil {
+		logger.Error("failed to retrieve notification channels", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationChannels(rows), nil
+}
+
+// ListPollNotificationChannels returns the channels of the poll owner for all polls and the channels of any member for this poll.
+func ListPollNotificationChannels(ctx context.Context, db *sql.DB, accountID int64, pollID string) ([]NotificationChannel, error) {
+	sqlStatement := `SELECT id, account_id, COALESCE(poll_id, ''), kind, url, vote_threshold
+		FROM notification_channels
+		WHERE (account_id = $1 AND poll_id IS NULL) OR poll_id = $2
+		ORDER BY id;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, accountID, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve poll notification channels", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationChannels(rows), nil
+}
+
+func DeleteNotificationChannel(ctx context.Context, db *sql.DB, accountID int64, channelID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM notification_channels WHERE account_id = $1 AND id = $2;`, accountID, channelID)
+	if err != nil {
+		logger.Error("failed to delete notification channel", zap.Error(err))
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to read deleted notification channels", zap.Error(err))
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+func IsNotificationDelivered(ctx context.Context, db *sql.DB, channelID int64, eventID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM notification_deliveries WHERE channel_id = $1 AND event_id = $2);`, channelID, eventID).
+		Scan(&exists)
+	if err != nil {
+		logger.Error("failed to retrieve notification delivery", zap.Error(err))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func MarkNotificationDelivered(ctx context.Context, db *sql.DB, channelID int64, eventID int64) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notification_deliveries (channel_id, event_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, channelID, eventID)
+	if err != nil {
+		logger.Error("failed to mark notification delivered", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func IsNotificationThresholdReached(ctx context.Context, db *sql.DB, channelID int64, pollID string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM notification_thresholds WHERE channel_id = $1 AND poll_id = $2);`, channelID, pollID).
+		Scan(&exists)
+	if err != nil {
+		logger.Error("failed to retrieve notification threshold", zap.Error(err))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func MarkNotificationThresholdReached(ctx context.Context, db *sql.DB, channelID int64, pollID string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notification_thresholds (channel_id, poll_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, channelID, pollID)
+	if err != nil {
+		logger.Error("failed to mark notification threshold", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func NewPollInvites(ctx context.Context, db *sql.DB, pollID string, emails []string) ([]PollInvite, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertPollInvites(ctx, tx, pollID, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll invites", zap.Error(err))
+		return nil, err
+	}
+
+	return ListPollInvites(ctx, db, pollID)
+}
+
+func insertPollInvites(ctx context.Context, tx *sql.Tx, pollID string, emails []string) error {
+	sqlStatement := `
+INSERT INTO poll_invites (poll_id, email, token)
+VALUES ($1, $2, $3)
+ON CONFLICT (poll_id, email) DO NOTHING;`
+
+	for _, email := range emails {
+		_, err := tx.ExecContext(ctx, sqlStatement, pollID, email, randomToken(16))
+		if err != nil {
+			logger.Error("failed to create poll invite", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanPollInvites(rows *sql.Rows) []PollInvite {
+	invites := []PollInvite{}
+	for rows.Next() {
+		invite := PollInvite{}
+		err := rows.Scan(&invite.ID, &invite.PollID, &invite.Email, &invite.Token, &invite.CreatedAt)
+		if err != nil {
+			logger.Error("failed to read poll invite fields", zap.Error(err))
+			continue
+		}
+
+		invites = append(invites, invite)
+	}
+
+	return invites
+}
+
+func ListPollInvites(ctx context.Context, db *sql.DB, pollID string) ([]PollInvite, error) {
+	sqlStatement := `SELECT id, poll_id, email, token, created_at
+		FROM poll_invites
+		WHERE poll_id = $1
+		ORDER BY id;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve poll invites", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPollInvites(rows), nil
+}
+
+func GetPollInvite(ctx context.Context, db *sql.DB, inviteID int64) (PollInvite, error) {
+	sqlStatement := `SELECT id, poll_id, email, token, created_at FROM poll_invites WHERE id = $1;`
+
+	invite := PollInvite{}
+	err := db.QueryRowContext(ctx, sqlStatement, inviteID).
+		Scan(&invite.ID, &invite.PollID, &invite.Email, &invite.Token, &invite.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll invite found for id %d\n", inviteID)
+			return PollInvite{}, nil
+		}
+
+		logger.Error("failed to retrieve poll invite", zap.Error(err))
+		return PollInvite{}, err
+	}
+
+	return invite, nil
+}
+
+func GetPollInviteByToken(ctx context.Context, db *sql.DB, token string) (PollInvite, error) {
+	sqlStatement := `SELECT id, poll_id, email, token, created_at FROM poll_invites WHERE token = $1;`
+
+	invite := PollInvite{}
+	err := db.QueryRowContext(ctx, sqlStatement, token).
+		Scan(&invite.ID, &invite.PollID, &invite.Email, &invite.Token, &invite.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll invite found for token\n")
+			return PollInvite{}, nil
+		}
+
+		logger.Error("failed to retrieve poll invite", zap.Error(err))
+		return PollInvite{}, err
+	}
+
+	return invite, nil
+}
+
+func DeletePollInvite(ctx context.Context, db *sql.DB, pollID string, inviteID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM poll_invites WHERE poll_id = $1 AND id = $2;`, pollID, inviteID)
+	if err != nil {
+		logger.Error("failed to delete poll invite", zap.Error(err))
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to read deleted poll invites", zap.Error(err))
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// UseVoteLinkNonce records the nonce of a vote link, returning false when it was already used.
+func UseVoteLinkNonce(ctx context.Context, db *sql.DB, nonce string, inviteID int64, expiresAt time.Time) (bool, error) {
+	sqlStatement := `
+INSERT INTO vote_link_nonces (nonce, invite_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (nonce) DO NOTHING;`
+
+	result, err := db.ExecContext(ctx, sqlStatement, nonce, inviteID, expiresAt)
+	if err != nil {
+		logger.Error("failed to use vote link nonce", zap.Error(err))
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to read used vote link nonces", zap.Error(err))
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+func ReleaseVoteLinkNonce(ctx context.Context, db *sql.DB, nonce string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM vote_link_nonces WHERE nonce = $1;`, nonce)
+	if err != nil {
+		logger.Error("failed to release vote link nonce", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredVoteLinkNonces forgets the nonces of links that can no longer be redeemed.
+func DeleteExpiredVoteLinkNonces(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM vote_link_nonces WHERE expires_at < now();`)
+	if err != nil {
+		logger.Error("failed to delete expired vote link nonces", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func SetPollRSVP(ctx context.Context, db *sql.DB, pollID string, email string, status RSVPStatus) error {
+	sqlStatement := `
+INSERT INTO poll_rsvps (poll_id, email, status)
+VALUES ($1, $2, $3)
+ON CONFLICT (poll_id, email)
+DO UPDATE SET status = EXCLUDED.status, updated_at = now();`
+
+	_, err := db.ExecContext(ctx, sqlStatement, pollID, email, status)
+	if err != nil {
+		logger.Error("failed to set poll rsvp", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func ListPollRSVPs(ctx context.Context, db *sql.DB, pollID string) ([]PollRSVP, error) {
+	sqlStatement := `SELECT email, status, updated_at FROM poll_rsvps WHERE poll_id = $1 ORDER BY email;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, pollID)
+	if err != nil {
+		logger.Error("failed to retrieve poll rsvps", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	rsvps := []PollRSVP{}
+	for rows.Next() {
+		rsvp := PollRSVP{}
+		err = rows.Scan(&rsvp.Email, &rsvp.Status, &rsvp.UpdatedAt)
+		if err != nil {
+			logger.Error("failed to read poll rsvp fields", zap.Error(err))
+			continue
+		}
+
+		rsvps = append(rsvps, rsvp)
+	}
+
+	return rsvps, nil
+}
+
+// ListVotedPolls returns the polls the account answered, with its answers.
+func ListVotedPolls(ctx context.Context, db *sql.DB, accountID int64) ([]VotedPoll, error) {
+	sqlStatement := `SELECT polls.id, polls.account_id, title, description, location, jsonb_pretty(options) AS options,
+			COALESCE(final_option_id, ''), jsonb_pretty(availabilities) AS availabilities
+		FROM poll_account_availability INNER JOIN polls ON poll_account_availability.poll_id = polls.id
+		WHERE poll_account_availability.account_id = $1 AND polls.deleted_at IS NULL;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, accountID)
+	if err != nil {
+		logger.Error("failed to retrieve voted polls", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	votedPolls := []VotedPoll{}
+	for rows.Next() {
+		votedPoll := VotedPoll{}
+		var options string
+		var availabilities string
+		err = rows.Scan(&votedPoll.ID, &votedPoll.AccountID, &votedPoll.Title, &votedPoll.Description, &votedPoll.Location,
+			&options, &votedPoll.FinalOptionID, &availabilities)
+		if err != nil {
+			logger.Error("failed to read voted poll fields", zap.Error(err))
+			continue
+		}
+
+		err = json.Unmarshal([]byte(options), &votedPoll.Options)
+		if err != nil {
+			logger.Error("failed to unmarshal poll options", zap.Error(err))
+			continue
+		}
+		err = json.Unmarshal([]byte(availabilities), &votedPoll.Availabilities)
+		if err != nil {
+			logger.Error("failed to unmarshal vote availabilities", zap.Error(err))
+			continue
+		}
+
+		votedPolls = append(votedPolls, votedPoll)
+	}
+
+	return votedPolls, nil
+}
+
+// SetCalendarFeedToken creates the calendar feed of the account, replacing the token of an existing feed.
+func SetCalendarFeedToken(ctx context.Context, db *sql.DB, accountID int64) (string, error) {
+	sqlStatement := `
+INSERT INTO calendar_feeds (account_id, token)
+VALUES ($1, $2)
+ON CONFLICT (account_id)
+DO UPDATE SET token = EXCLUDED.token, created_at = now();`
+
+	token := randomToken(32)
+	_, err := db.ExecContext(ctx, sqlStatement, accountID, token)
+	if err != nil {
+		logger.Error("failed to set calendar feed token", zap.Error(err))
+		return "", err
+	}
+
+	return token, nil
+}
+
+func GetCalendarFeedToken(ctx context.Context, db *sql.DB, accountID int64) (string, error) {
+	var token string
+	err := db.QueryRowContext(ctx, `SELECT token FROM calendar_feeds WHERE account_id = $1;`, accountID).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		logger.Error("failed to retrieve calendar feed token", zap.Error(err))
+		return "", err
+	}
+
+	return token, nil
+}
+
+func GetCalendarFeedAccount(ctx context.Context, db *sql.DB, token string) (int64, error) {
+	var accountID int64
+	err := db.QueryRowContext(ctx, `SELECT account_id FROM calendar_feeds WHERE token = $1;`, token).Scan(&accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return -1, nil
+		}
+
+		logger.Error("failed to retrieve calendar feed account", zap.Error(err))
+		return -1, err
+	}
+
+	return accountID, nil
+}
+
+func DeleteCalendarFeed(ctx context.Context, db *sql.DB, accountID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE account_id = $1;`, accountID)
+	if err != nil {
+		logger.Error("failed to delete calendar feed", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// SetGoogleCalendarToken stores the encrypted refresh token of an account and the space separated scopes it grants.
+func SetGoogleCalendarToken(ctx context.Context, db *sql.DB, accountID int64, refreshToken []byte, scopes string) error {
+	sqlStatement := `
+INSERT INTO google_calendar_tokens (account_id, refresh_token, scopes)
+VALUES ($1, $2, $3)
+ON CONFLICT (account_id)
+DO UPDATE SET refresh_token = EXCLUDED.refresh_token, scopes = EXCLUDED.scopes, updated_at = now();`
+
+	_, err := db.ExecContext(ctx, sqlStatement, accountID, refreshToken, scopes)
+	if err != nil {
+		logger.Error("failed to set google calendar token", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func GetGoogleCalendarToken(ctx context.Context, db *sql.DB, accountID int64) ([]byte, string, error) {
+	var (
+		refreshToken []byte
+		scopes       string
+	)
+	err := db.QueryRowContext(ctx, `SELECT refresh_token, scopes FROM google_calendar_tokens WHERE account_id = $1;`, accountID).
+		Scan(&refreshToken, &scopes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", nil
+		}
+
+		logger.Error("failed to retrieve google calendar token", zap.Error(err))
+		return nil, "", err
+	}
+
+	return refreshToken, scopes, nil
+}
+
+func DeleteGoogleCalendarToken(ctx context.Context, db *sql.DB, accountID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM google_calendar_tokens WHERE account_id = $1;`, accountID)
+	if err != nil {
+		logger.Error("failed to delete google calendar token", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// SetGoogleCalendarEvent records the google calendar event created for a finalized poll, in the calendar of the account.
+func SetGoogleCalendarEvent(ctx context.Context, db *sql.DB, pollID string, accountID int64, eventID string) error {
+	sqlStatement := `
+INSERT INTO google_calendar_events (poll_id, account_id, event_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (poll_id)
+DO UPDATE SET account_id = EXCLUDED.account_id, event_id = EXCLUDED.event_id;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, pollID, accountID, eventID)
+	if err != nil {
+		logger.Error("failed to set google calendar event", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func GetGoogleCalendarEvent(ctx context.Context, db *sql.DB, pollID string) (int64, string, error) {
+	var (
+		accountID int64
+		eventID   string
+	)
+	err := db.QueryRowContext(ctx, `SELECT account_id, event_id FROM google_calendar_events WHERE poll_id = $1;`, pollID).
+		Scan(&accountID, &eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return -1, "", nil
+		}
+
+		logger.Error("failed to retrieve google calendar event", zap.Error(err))
+		return -1, "", err
+	}
+
+	return accountID, eventID, nil
+}
+
+func DeleteGoogleCalendarEvent(ctx context.Context, db *sql.DB, pollID string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM google_calendar_events WHERE poll_id = $1;`, pollID)
+	if err != nil {
+		logger.Error("failed to delete google calendar event", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// SetCalDAVAccount registers the calendar of the account, forgetting the holds that are not in it, which were
+// made in a previous calendar and cannot be deleted through the new one.
+func SetCalDAVAccount(ctx context.Context, db *sql.DB, accountID int64, account CalDAVAccount, encryptedPassword []byte) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+INSERT INTO caldav_accounts (account_id, url, username, password, holds)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (account_id)
+DO UPDATE SET url = EXCLUDED.url, username = EXCLUDED.username, password = EXCLUDED.password, holds = EXCLUDED.holds;`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, accountID, account.URL, account.Username, encryptedPassword, account.Holds)
+	if err != nil {
+		logger.Error("failed to set caldav account", zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM caldav_holds WHERE account_id = $1 AND left(href, length($2)) <> $2;`, accountID, account.URL)
+	if err != nil {
+		logger.Error("failed to delete caldav holds", zap.Error(err))
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit transaction", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetCalDAVAccount returns the caldav account without the password, which is returned encrypted.
+func GetCalDAVAccount(ctx context.Context, db *sql.DB, accountID int64) (CalDAVAccount, []byte, error) {
+	account := CalDAVAccount{}
+	var encryptedPassword []byte
+	err := db.QueryRowContext(ctx, `SELECT url, username, password, holds FROM caldav_accounts WHERE account_id = $1;`, accountID).
+		Scan(&account.URL, &account.Username, &encryptedPassword, &account.Holds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CalDAVAccount{}, nil, nil
+		}
+
+		logger.Error("failed to retrieve caldav account", zap.Error(err))
+		return CalDAVAccount{}, nil, err
+	}
+
+	return account, encryptedPassword, nil
+}
+
+func DeleteCalDAVAccount(ctx context.Context, db *sql.DB, accountID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM caldav_holds WHERE account_id = $1;`, accountID)
+	if err != nil {
+		logger.Error("failed to delete caldav holds", zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM caldav_accounts WHERE account_id = $1;`, accountID)
+	if err != nil {
+		logger.Error("failed to delete caldav account", zap.Error(err))
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit transaction", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func NewCalDAVHold(ctx context.Context, db *sql.DB, hold CalDAVHold) error {
+	sqlStatement := `
+INSERT INTO caldav_holds (poll_id, account_id, option_id, href)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (poll_id, account_id, option_id)
+DO UPDATE SET href = EXCLUDED.href;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, hold.PollID, hold.AccountID, hold.OptionID, hold.Href)
+	if err != nil {
+		logger.Error("failed to insert caldav hold", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func ListCalDAVHolds(ctx context.Context, db *sql.DB, pollID string) ([]CalDAVHold, error) {
+	return listCalDAVHolds(ctx, db, `SELECT poll_id, account_id, option_id, href FROM caldav_holds WHERE poll_id = $1;`, pollID)
+}
+
+func ListAccountCalDAVHolds(ctx context.Context, db *sql.DB, accountID int64) ([]CalDAVHold, error) {
+	return listCalDAVHolds(ctx, db, `SELECT poll_id, account_id, option_id, href FROM caldav_holds WHERE account_id = $1;`, accountID)
+}
+
+func listCalDAVHolds(ctx context.Context, db *sql.DB, sqlStatement string, args ...interface{}) ([]CalDAVHold, error) {
+	rows, err := db.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		logger.Error("failed to list caldav holds", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []CalDAVHold{}
+	for rows.Next() {
+		hold := CalDAVHold{}
+		if err := rows.Scan(&hold.PollID, &hold.AccountID, &hold.OptionID, &hold.Href); err != nil {
+			logger.Error("failed to read caldav hold fields", zap.Error(err))
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to read caldav holds", zap.Error(err))
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+func DeleteCalDAVHold(ctx context.Context, db *sql.DB, pollID string, accountID int64, optionID string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM caldav_holds WHERE poll_id = $1 AND account_id = $2 AND option_id = $3;`, pollID, accountID, optionID)
+	if err != nil {
+		logger.Error("failed to delete caldav hold", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func SetAvailabilityProfile(ctx context.Context, db *sql.DB, accountID int64, profile AvailabilityProfile) error {
+	sqlStatement := `
+INSERT INTO availability_profiles (account_id, profile)
+VALUES ($1, $2)
+ON CONFLICT (account_id)
+DO UPDATE SET profile = EXCLUDED.profile;`
+
+	marshaledProfile, err := json.Marshal(profile)
+	if err != nil {
+		logger.Error("failed to marshal availability profile", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, sqlStatement, accountID, marshaledProfile)
+	if err != nil {
+		logger.Error("failed to set availability profile", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func GetAvailabilityProfile(ctx context.Context, db *sql.DB, accountID int64) (AvailabilityProfile, bool, error) {
+	var marshaledProfile string
+	err := db.QueryRowContext(ctx, `SELECT profile FROM availability_profiles WHERE account_id = $1;`, accountID).Scan(&marshaledProfile)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AvailabilityProfile{}, false, nil
+		}
+
+		logger.Error("failed to retrieve availability profile", zap.Error(err))
+		return AvailabilityProfile{}, false, err
+	}
+
+	profile := AvailabilityProfile{}
+	err = json.Unmarshal([]byte(marshaledProfile), &profile)
+	if err != nil {
+		logger.Error("failed to unmarshal availability profile", zap.Error(err))
+		return AvailabilityProfile{}, false, err
+	}
+
+	return profile, true, nil
+}
+
+func DeleteAvailabilityProfile(ctx context.Context, db *sql.DB, accountID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM availability_profiles WHERE account_id = $1;`, accountID)
+	if err != nil {
+		logger.Error("failed to delete availability profile", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func SetAccountHolidayLocale(ctx context.Context, db *sql.DB, accountID int64, locale HolidayLocale) error {
+	_, err := db.ExecContext(ctx, `UPDATE accounts SET country = $2, region = $3 WHERE id = $1;`, accountID, locale.Country, locale.Region)
+	if err != nil {
+		logger.Error("failed to update account holiday locale", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func GetAccountHolidayLocale(ctx context.Context, db *sql.DB, accountID int64) (HolidayLocale, error) {
+	locale := HolidayLocale{}
+	err := db.QueryRowContext(ctx, `SELECT country, region FROM accounts WHERE id = $1;`, accountID).Scan(&locale.Country, &locale.Region)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return HolidayLocale{}, nil
+		}
+
+		logger.Error("failed to retrieve account holiday locale", zap.Error(err))
+		return HolidayLocale{}, err
+	}
+
+	return locale, nil
+}
+
+// ListPollHolidayLocales returns the distinct configured countries of the owner, voters and invitees of a poll.
+func ListPollHolidayLocales(ctx context.Context, db *sql.DB, poll Poll) ([]HolidayLocale, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT DISTINCT country, region FROM accounts
+WHERE country <> '' AND (
+	id = $2
+	OR id IN (SELECT account_id FROM poll_account_availability WHERE poll_id = $1)
+	OR email IN (SELECT email FROM poll_invites WHERE poll_id = $1)
+)
+ORDER BY country, region;`, poll.ID, poll.AccountID)
+	if err != nil {
+		logger.Error("failed to list poll holiday locales", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	locales := []HolidayLocale{}
+	for rows.Next() {
+		locale := HolidayLocale{}
+		err := rows.Scan(&locale.Country, &locale.Region)
+		if err != nil {
+			logger.Error("failed to scan poll holiday locale", zap.Error(err))
+			return nil, err
+		}
+		locales = append(locales, locale)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll holiday locales", zap.Error(err))
+		return nil, err
+	}
+
+	return locales, nil
+}
+
+func NewPollComment(ctx context.Context, db *sql.DB, comment PollComment) (PollComment, error) {
+	sqlStatement := `
+INSERT INTO poll_comments (poll_id, account_id, option_id, body)
+VALUES ($1, $2, NULLIF($3, ''), $4)
+RETURNING id, created_at;`
+	err := db.QueryRowContext(ctx, sqlStatement, comment.PollID, comment.AccountID, comment.OptionID, comment.Body).
+		Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		logger.Error("failed to create poll comment", zap.Error(err))
+		return PollComment{}, err
+	}
+
+	return comment, nil
+}
+
+const pollCommentColumns = `poll_comments.id, poll_id, account_id, accounts.email, COALESCE(option_id, ''), body, created_at, edited_at`
+
+func scanPollComment(row interface{ Scan(...interface{}) error }) (PollComment, error) {
+	comment := PollComment{}
+	var editedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.PollID, &comment.AccountID, &comment.AccountEmail, &comment.OptionID, &comment.Body, &comment.CreatedAt, &editedAt)
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+
+	return comment, err
+}
+
+// GetPollComment returns the comment of a poll, with a zero id when it does not exist.
+func GetPollComment(ctx context.Context, db *sql.DB, pollID string, commentID int64) (PollComment, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+pollCommentColumns+`
+		FROM poll_comments INNER JOIN accounts ON poll_comments.account_id = accounts.id
+		WHERE poll_id = $1 AND poll_comments.id = $2;`, pollID, commentID)
+	comment, err := scanPollComment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PollComment{}, nil
+		}
+
+		logger.Error("failed to retrieve poll comment", zap.Error(err))
+		return PollComment{}, err
+	}
+
+	return comment, nil
+}
+
+// ListPollComments returns up to limit comments of a poll in the order they were posted, after the comment with id after.
+func ListPollComments(ctx context.Context, db *sql.DB, pollID string, after int64, limit int) ([]PollComment, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+pollCommentColumns+`
+		FROM poll_comments INNER JOIN accounts ON poll_comments.account_id = accounts.id
+		WHERE poll_id = $1 AND poll_comments.id > $2
+		ORDER BY poll_comments.id
+		LIMIT $3;`, pollID, after, limit)
+	if err != nil {
+		logger.Error("failed to list poll comments", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []PollComment{}
+	for rows.Next() {
+		comment, err := scanPollComment(rows)
+		if err != nil {
+			logger.Error("failed to scan poll comment", zap.Error(err))
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll comments", zap.Error(err))
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func UpdatePollComment(ctx context.Context, db *sql.DB, pollID string, commentID int64, body string) (PollComment, error) {
+	_, err := db.ExecContext(ctx, `UPDATE poll_comments SET body = $3, edited_at = now() WHERE poll_id = $1 AND id = $2;`, pollID, commentID, body)
+	if err != nil {
+		logger.Error("failed to update poll comment", zap.Error(err))
+		return PollComment{}, err
+	}
+
+	return GetPollComment(ctx, db, pollID, commentID)
+}
+
+func DeletePollComment(ctx context.Context, db *sql.DB, pollID string, commentID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM poll_comments WHERE poll_id = $1 AND id = $2;`, pollID, commentID)
+	if err != nil {
+		logger.Error("failed to delete poll comment", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func GetPollCommentsLocked(ctx context.Context, db *sql.DB, pollID string) (bool, error) {
+	var locked bool
+	err := db.QueryRowContext(ctx, `SELECT comments_locked FROM polls WHERE id = $1;`, pollID).Scan(&locked)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("failed to retrieve poll comments lock", zap.Error(err))
+		return false, err
+	}
+
+	return locked, nil
+}
+
+func SetPollCommentsLocked(ctx context.Context, db *sql.DB, actorID int64, pollID string, locked bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setPollCommentsLocked(ctx, tx, actorID, pollID, locked)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll comments lock", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func setPollCommentsLocked(ctx context.Context, tx *sql.Tx, actorID int64, pollID string, locked bool) error {
+	var previous bool
+	err := tx.QueryRowContext(ctx, `SELECT comments_locked FROM polls WHERE id = $1 FOR UPDATE;`, pollID).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll found for id %s\n", pollID)
+			return nil
+		}
+
+		logger.Error("failed to retrieve poll comments lock", zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE polls SET comments_locked = $2 WHERE id = $1;`, pollID, locked)
+	if err != nil {
+		logger.Error("failed to update poll comments lock", zap.Error(err))
+		return err
+	}
+
+	return insertPollAudit(ctx, tx, pollID, actorID, AuditCommentsLockChanged, 0, previous, locked)
+}
+
+// GetPollRole returns the role of the account in the poll, empty when it has none or the poll does not exist.
+// Members of the workspace of the poll manage it as editors.
+func GetPollRole(ctx context.Context, db *sql.DB, pollID string, accountID int64) (PollRole, error) {
+	var role PollRole
+	err := db.QueryRowContext(ctx, `
+SELECT CASE
+	WHEN polls.account_id = $2 THEN 'owner'
+	WHEN workspace_members.account_id IS NOT NULL THEN 'editor'
+	ELSE COALESCE(poll_members.role, '')
+END
+FROM polls
+LEFT JOIN poll_members ON poll_members.poll_id = polls.id AND poll_members.account_id = $2
+LEFT JOIN workspace_members ON workspace_members.workspace_id = polls.workspace_id AND workspace_members.account_id = $2
+WHERE polls.id = $1 AND polls.deleted_at IS NULL;`, pollID, accountID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		logger.Error("failed to retrieve poll role", zap.Error(err))
+		return "", err
+	}
+
+	return role, nil
+}
+
+// ListPollMembers returns the owner followed by the other members of the poll.
+func ListPollMembers(ctx context.Context, db *sql.DB, pollID string) ([]PollMember, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT accounts.id, accounts.email, 'owner', 0 FROM polls INNER JOIN accounts ON polls.account_id = accounts.id WHERE polls.id = $1
+UNION ALL
+SELECT accounts.id, accounts.email, poll_members.role, 1 FROM poll_members INNER JOIN accounts ON poll_members.account_id = accounts.id
+WHERE poll_members.poll_id = $1
+ORDER BY 4, 2;`, pollID)
+	if err != nil {
+		logger.Error("failed to list poll members", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []PollMember{}
+	for rows.Next() {
+		member := PollMember{}
+		var order int
+		err := rows.Scan(&member.AccountID, &member.AccountEmail, &member.Role, &order)
+		if err != nil {
+			logger.Error("failed to scan poll member", zap.Error(err))
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll members", zap.Error(err))
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func SetPollMember(ctx context.Context, db *sql.DB, actorID int64, pollID string, accountID int64, role PollRole) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setPollMember(ctx, tx, actorID, pollID, accountID, role)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll member", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func setPollMember(ctx context.Context, tx *sql.Tx, actorID int64, pollID string, accountID int64, role PollRole) error {
+	if !IsPollMemberRole(role) {
+		return ErrInvalidPollRole
+	}
+
+	sqlStatement := `
+INSERT INTO poll_members (poll_id, account_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (poll_id, account_id) DO UPDATE SET role = excluded.role;`
+
+	var previousRole PollRole
+	err := tx.QueryRowContext(ctx, `SELECT role FROM poll_members WHERE poll_id = $1 AND account_id = $2 FOR UPDATE;`, pollID, accountID).
+		Scan(&previousRole)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("failed to retrieve poll member", zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStatement, pollID, accountID, role)
+	if err != nil {
+		logger.Error("failed to set poll member", zap.Error(err))
+		return err
+	}
+
+	return insertAudit(ctx, tx, memberAudit(actorID, pollID, accountID, previousRole, role))
+}
+
+func DeletePollMember(ctx context.Context, db *sql.DB, actorID int64, pollID string, accountID int64) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var role PollRole
+	err = tx.QueryRowContext(ctx, `DELETE FROM poll_members WHERE poll_id = $1 AND account_id = $2 RETURNING role;`, pollID, accountID).
+		Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		logger.Error("failed to delete poll member", zap.Error(err))
+		return false, err
+	}
+
+	err = insertAudit(ctx, tx, memberAudit(actorID, pollID, accountID, role, ""))
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll member deletion", zap.Error(err))
+		return false, err
+	}
+
+	return true, nil
+}
+
+var ErrPollOwnerChanged = errors.New("poll owner changed")
+
+var ErrInvalidPollRole = errors.New("invalid poll role")
+
+// TransferPoll makes another account the owner of the poll, the previous owner stays on as an editor.
+func TransferPoll(ctx context.Context, db *sql.DB, poll Poll, newOwnerID int64) (Poll, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Poll{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE polls SET account_id = $3 WHERE id = $1 AND account_id = $2;`, poll.ID, poll.AccountID, newOwnerID)
+	if err != nil {
+		logger.Error("failed to transfer poll", zap.Error(err))
+		return Poll{}, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to retrieve transferred polls", zap.Error(err))
+		return Poll{}, err
+	}
+	if updated == 0 {
+		logger.Warn("poll owner changed during transfer", zap.String("poll_id", poll.ID))
+		return Poll{}, ErrPollOwnerChanged
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM poll_members WHERE poll_id = $1 AND account_id = $2;`, poll.ID, newOwnerID)
+	if err != nil {
+		logger.Error("failed to delete poll member", zap.Error(err))
+		return Poll{}, err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO poll_members (poll_id, account_id, role)
+VALUES ($1, $2, 'editor')
+ON CONFLICT (poll_id, account_id) DO UPDATE SET role = excluded.role;`, poll.ID, poll.AccountID)
+	if err != nil {
+		logger.Error("failed to set poll member", zap.Error(err))
+		return Poll{}, err
+	}
+
+	previousOwnerID := poll.AccountID
+	poll.AccountID = newOwnerID
+	err = insertPollEvent(ctx, tx, poll.ID, PollTransferredEvent, PollEventPayload{AccountID: newOwnerID, Poll: poll})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = insertPollAudit(ctx, tx, poll.ID, previousOwnerID, AuditPollTransferred, newOwnerID, previousOwnerID, newOwnerID)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll transfer", zap.Error(err))
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+func NewWorkspace(ctx context.Context, db *sql.DB, accountID int64, workspace Workspace) (Workspace, error) {
+	marshaledSettings, err := json.Marshal(workspace.Settings)
+	if err != nil {
+		logger.Error("failed to marshal workspace settings", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return Workspace{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO workspaces (name, settings) VALUES ($1, $2) RETURNING id;`, workspace.Name, string(marshaledSettings)).
+		Scan(&workspace.ID)
+	if err != nil {
+		logger.Error("failed to create workspace", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO workspace_members (workspace_id, account_id, role) VALUES ($1, $2, 'admin');`, workspace.ID, accountID)
+	if err != nil {
+		logger.Error("failed to create workspace admin", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit workspace creation", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	workspace.Role = WorkspaceAdmin
+	return workspace, nil
+}
+
+// ListWorkspaces returns the workspaces the account is a member of, with its role in each.
+func ListWorkspaces(ctx context.Context, db *sql.DB, accountID int64) ([]Workspace, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT workspaces.id, workspaces.name, workspaces.settings, workspace_members.role
+FROM workspaces INNER JOIN workspace_members ON workspace_members.workspace_id = workspaces.id
+WHERE workspace_members.account_id = $1
+ORDER BY workspaces.name, workspaces.id;`, accountID)
+	if err != nil {
+		logger.Error("failed to list workspaces", zap.Error(err))
+		return nil, err
 	}
 	defer rows.Close()
 
-	pollAccountAvailabilities := []PollAccountAvailability{}
+	workspaces := []Workspace{}
 	for rows.Next() {
-		var accountID int64
-		var email string
-		var availabilities string
-		err = rows.Scan(&accountID, &email, &availabilities)
-		if err := rows.Err(); err != nil {
-			logger.Error("failed to read vote fields", zap.Error(err))
-			continue
+		workspace := Workspace{}
+		var settings string
+		err := rows.Scan(&workspace.ID, &workspace.Name, &settings, &workspace.Role)
+		if err != nil {
+			logger.Error("failed to scan workspace", zap.Error(err))
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(settings), &workspace.Settings); err != nil {
+			logger.Error("failed to unmarshal workspace settings", zap.Error(err))
+			return nil, err
 		}
+		workspaces = append(workspaces, workspace)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list workspaces", zap.Error(err))
+		return nil, err
+	}
 
-		optionAvailabilities := []OptionAvailability{}
-		err = json.Unmarshal([]byte(availabilities), &optionAvailabilities)
+	return workspaces, nil
+}
+
+// GetWorkspace returns the workspace with the role of the account in it, an empty role when it is not a member.
+// The workspace has a zero id when it does not exist.
+func GetWorkspace(ctx context.Context, db *sql.DB, workspaceID int64, accountID int64) (Workspace, error) {
+	workspace := Workspace{}
+	var settings string
+	err := db.QueryRowContext(ctx, `
+SELECT workspaces.id, workspaces.name, workspaces.settings, COALESCE(workspace_members.role, '')
+FROM workspaces LEFT JOIN workspace_members
+	ON workspace_members.workspace_id = workspaces.id AND workspace_members.account_id = $2
+WHERE workspaces.id = $1;`, workspaceID, accountID).Scan(&workspace.ID, &workspace.Name, &settings, &workspace.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Workspace{}, nil
+		}
+
+		logger.Error("failed to retrieve workspace", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	err = json.Unmarshal([]byte(settings), &workspace.Settings)
+	if err != nil {
+		logger.Error("failed to unmarshal workspace settings", zap.Error(err))
+		return Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
+func UpdateWorkspace(ctx context.Context, db *sql.DB, workspace Workspace) error {
+	marshaledSettings, err := json.Marshal(workspace.Settings)
+	if err != nil {
+		logger.Error("failed to marshal workspace settings", zap.Error(err))
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE workspaces SET name = $2, settings = $3 WHERE id = $1;`, workspace.ID, workspace.Name, string(marshaledSettings))
+	if err != nil {
+		logger.Error("failed to update workspace", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWorkspace removes the workspace, its polls go back to being personal polls of their owners.
+func DeleteWorkspace(ctx context.Context, db *sql.DB, workspaceID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM workspaces WHERE id = $1;`, workspaceID)
+	if err != nil {
+		logger.Error("failed to delete workspace", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func ListWorkspaceMembers(ctx context.Context, db *sql.DB, workspaceID int64) ([]WorkspaceMembership, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT accounts.id, accounts.email, workspace_members.role
+FROM workspace_members INNER JOIN accounts ON workspace_members.account_id = accounts.id
+WHERE workspace_members.workspace_id = $1
+ORDER BY accounts.email;`, workspaceID)
+	if err != nil {
+		logger.Error("failed to list workspace members", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []WorkspaceMembership{}
+	for rows.Next() {
+		member := WorkspaceMembership{}
+		err := rows.Scan(&member.AccountID, &member.AccountEmail, &member.Role)
 		if err != nil {
-			logger.Error("failed to unmarshal vote availabilities", zap.Error(err))
-			continue
+			logger.Error("failed to scan workspace member", zap.Error(err))
+			return nil, err
 		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list workspace members", zap.Error(err))
+		return nil, err
+	}
 
-		pollAccountAvailabilities = append(pollAccountAvailabilities, PollAccountAvailability{
-			PollID:         pollID,
-			AccountID:      accountID,
-			AccountEmail:   email,
-			Availabilities: optionAvailabilities,
-		})
+	return members, nil
+}
+
+func SetWorkspaceMember(ctx context.Context, db *sql.DB, workspaceID int64, accountID int64, role WorkspaceRole) error {
+	sqlStatement := `
+INSERT INTO workspace_members (workspace_id, account_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id, account_id) DO UPDATE SET role = excluded.role;`
+	_, err := db.ExecContext(ctx, sqlStatement, workspaceID, accountID, role)
+	if err != nil {
+		logger.Error("failed to set workspace member", zap.Error(err))
+		return err
 	}
 
-	return pollAccountAvailabilities, nil
+	return nil
+}
+
+func DeleteWorkspaceMember(ctx context.Context, db *sql.DB, workspaceID int64, accountID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND account_id = $2;`, workspaceID, accountID)
+	if err != nil {
+		logger.Error("failed to delete workspace member", zap.Error(err))
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to retrieve deleted workspace members", zap.Error(err))
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+func CountWorkspaceAdmins(ctx context.Context, db *sql.DB, workspaceID int64) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM workspace_members WHERE workspace_id = $1 AND role = 'admin';`, workspaceID).Scan(&count)
+	if err != nil {
+		logger.Error("failed to retrieve workspace admins count", zap.Error(err))
+		return -1, err
+	}
+
+	return count, nil
+}
+
+func CountWorkspacePolls(ctx context.Context, db *sql.DB, workspaceID int64) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM polls WHERE workspace_id = $1 AND deleted_at IS NULL;`, workspaceID).Scan(&count)
+	if err != nil {
+		logger.Error("failed to retrieve workspace polls count", zap.Error(err))
+		return -1, err
+	}
+
+	return count, nil
+}
+
+// SetPollWorkspace moves the poll into a workspace, a zero workspace id makes it a personal poll again.
+func SetPollWorkspace(ctx context.Context, db *sql.DB, actorID int64, pollID string, workspaceID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to start transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous int64
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(workspace_id, 0) FROM polls WHERE id = $1 FOR UPDATE;`, pollID).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Debugf("no poll found for id %s\n", pollID)
+			return nil
+		}
+
+		logger.Error("failed to retrieve poll workspace", zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE polls SET workspace_id = NULLIF($2, 0) WHERE id = $1;`, pollID, workspaceID)
+	if err != nil {
+		logger.Error("failed to update poll workspace", zap.Error(err))
+		return err
+	}
+
+	err = insertPollAudit(ctx, tx, pollID, actorID, AuditPollWorkspaceChanged, 0, previous, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit poll workspace", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// IsPollParticipant tells if the account is a member of the poll workspace, a poll member, an invitee or a voter.
+func IsPollParticipant(ctx context.Context, db *sql.DB, poll Poll, accountID int64) (bool, error) {
+	var participant bool
+	err := db.QueryRowContext(ctx, `
+SELECT EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = $1 AND account_id = $3)
+	OR EXISTS (SELECT 1 FROM poll_members WHERE poll_id = $2 AND account_id = $3)
+	OR EXISTS (SELECT 1 FROM poll_account_availability WHERE poll_id = $2 AND account_id = $3)
+	OR EXISTS (
+		SELECT 1 FROM poll_invites INNER JOIN accounts ON poll_invites.email = accounts.email
+		WHERE poll_invites.poll_id = $2 AND accounts.id = $3
+	);`, poll.WorkspaceID, poll.ID, accountID).Scan(&participant)
+	if err != nil {
+		logger.Error("failed to check poll participant", zap.Error(err))
+		return false, err
+	}
+
+	return participant, nil
+}
+
+const pollTemplateColumns = `id, name, title, description, location, duration, answer_scale, invites, created_at`
+
+func scanPollTemplate(row interface{ Scan(...interface{}) error }) (PollTemplate, error) {
+	var template PollTemplate
+	var answerScale string
+	var invites string
+	err := row.Scan(&template.ID, &template.Name, &template.Title, &template.Description, &template.Location, &template.Duration,
+		&answerScale, &invites, &template.CreatedAt)
+	if err != nil {
+		return PollTemplate{}, err
+	}
+
+	err = json.Unmarshal([]byte(answerScale), &template.AnswerScale)
+	if err != nil {
+		return PollTemplate{}, err
+	}
+	err = json.Unmarshal([]byte(invites), &template.Invites)
+	if err != nil {
+		return PollTemplate{}, err
+	}
+
+	return template, nil
+}
+
+func marshalPollTemplate(template PollTemplate) (string, string, error) {
+	answerScale, err := json.Marshal(append([]OptionAnswer{}, template.AnswerScale...))
+	if err != nil {
+		return "", "", err
+	}
+	invites, err := json.Marshal(append([]string{}, template.Invites...))
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(answerScale), string(invites), nil
+}
+
+func NewPollTemplate(ctx context.Context, db *sql.DB, accountID int64, template PollTemplate) (PollTemplate, error) {
+	answerScale, invites, err := marshalPollTemplate(template)
+	if err != nil {
+		logger.Error("failed to marshal poll template", zap.Error(err))
+		return PollTemplate{}, err
+	}
+
+	row := db.QueryRowContext(ctx, `
+INSERT INTO poll_templates (account_id, name, title, description, location, duration, answer_scale, invites)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING `+pollTemplateColumns+`;`,
+		accountID, template.Name, template.Title, template.Description, template.Location, template.Duration, answerScale, invites)
+	template, err = scanPollTemplate(row)
+	if err != nil {
+		logger.Error("failed to create poll template", zap.Error(err))
+		return PollTemplate{}, err
+	}
+
+	return template, nil
+}
+
+func ListPollTemplates(ctx context.Context, db *sql.DB, accountID int64) ([]PollTemplate, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+pollTemplateColumns+` FROM poll_templates WHERE account_id = $1 ORDER BY name, id;`, accountID)
+	if err != nil {
+		logger.Error("failed to list poll templates", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []PollTemplate{}
+	for rows.Next() {
+		template, err := scanPollTemplate(rows)
+		if err != nil {
+			logger.Error("failed to scan poll template", zap.Error(err))
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to list poll templates", zap.Error(err))
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func CountPollTemplates(ctx context.Context, db *sql.DB, accountID int64) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM poll_templates WHERE account_id = $1;`, accountID).Scan(&count)
+	if err != nil {
+		logger.Error("failed to count poll templates", zap.Error(err))
+		return -1, err
+	}
+
+	return count, nil
+}
+
+// GetPollTemplate returns a template of the account, with a zero id when it does not exist.
+func GetPollTemplate(ctx context.Context, db *sql.DB, accountID int64, templateID int64) (PollTemplate, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+pollTemplateColumns+` FROM poll_templates WHERE account_id = $1 AND id = $2;`, accountID, templateID)
+	template, err := scanPollTemplate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PollTemplate{}, nil
+		}
+
+		logger.Error("failed to retrieve poll template", zap.Error(err))
+		return PollTemplate{}, err
+	}
+
+	return template, nil
+}
+
+func UpdatePollTemplate(ctx context.Context, db *sql.DB, accountID int64, template PollTemplate) (PollTemplate, error) {
+	answerScale, invites, err := marshalPollTemplate(template)
+	if err != nil {
+		logger.Error("failed to marshal poll template", zap.Error(err))
+		return PollTemplate{}, err
+	}
+
+	row := db.QueryRowContext(ctx, `
+UPDATE poll_templates
+SET name = $3, title = $4, description = $5, location = $6, duration = $7, answer_scale = $8, invites = $9
+WHERE account_id = $1 AND id = $2
+RETURNING `+pollTemplateColumns+`;`,
+		accountID, template.ID, template.Name, template.Title, template.Description, template.Location, template.Duration, answerScale, invites)
+	template, err = scanPollTemplate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PollTemplate{}, nil
+		}
+
+		logger.Error("failed to update poll template", zap.Error(err))
+		return PollTemplate{}, err
+	}
+
+	return template, nil
+}
+
+func DeletePollTemplate(ctx context.Context, db *sql.DB, accountID int64, templateID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM poll_templates WHERE account_id = $1 AND id = $2;`, accountID, templateID)
+	if err != nil {
+		logger.Error("failed to delete poll template", zap.Error(err))
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to read deleted poll templates", zap.Error(err))
+		return false, err
+	}
+
+	return deleted > 0, nil
 }
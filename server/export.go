@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportFormat = string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportXLSX ExportFormat = "xlsx"
+	ExportJSON ExportFormat = "json"
+)
+
+const (
+	EXPORT_DATE_FORMAT = "2006-01-02 15:04"
+	EXPORT_TIME_FORMAT = "15:04"
+)
+
+// PollExportOption is a column of the export, the label shows the option times in the export time zone.
+type PollExportOption struct {
+	ID    string    `json:"id"`
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// PollExportParticipant is a row of the export, the answers are by option id and missing for the options left unanswered.
+type PollExportParticipant struct {
+	Name       string                  `json:"name"`
+	Email      string                  `json:"email,omitempty"`
+	RecordedBy string                  `json:"recorded_by,omitempty"`
+	AutoFilled bool                    `json:"auto_filled,omitempty"`
+	Answers    map[string]OptionAnswer `json:"answers"`
+}
+
+// PollExportTotal counts the answers of an option.
+type PollExportTotal struct {
+	OptionID string               `json:"option_id"`
+	Counts   map[OptionAnswer]int `json:"counts"`
+}
+
+// PollExport is who answered what in a poll, with one participant per voter and one option per column.
+type PollExport struct {
+	ID            string                  `json:"id"`
+	Title         string                  `json:"title"`
+	FinalOptionID string                  `json:"final_option_id,omitempty"`
+	TimeZone      string                  `json:"time_zone"`
+	Options       []PollExportOption      `json:"options"`
+	Participants  []PollExportParticipant `json:"participants"`
+	Totals        []PollExportTotal       `json:"totals"`
+}
+
+// NewPollExport builds the export of the votes of a poll, showing the option times in the location.
+func NewPollExport(poll Poll, votes []PollAccountAvailability, location *time.Location) PollExport {
+	export := PollExport{
+		ID:            poll.ID,
+		Title:         poll.Title,
+		FinalOptionID: poll.FinalOptionID,
+		TimeZone:      location.String(),
+		Options:       []PollExportOption{},
+		Participants:  []PollExportParticipant{},
+		Totals:        []PollExportTotal{},
+	}
+
+	for _, option := range poll.Options {
+		export.Options = append(export.Options, PollExportOption{
+			ID:    option.ID,
+			Label: exportOptionLabel(option, location),
+			Start: option.Start.In(location),
+			End:   option.End.In(location),
+		})
+	}
+
+	for _, vote := range votes {
+		participant := PollExportParticipant{
+			Name:       vote.Participant(),
+			RecordedBy: vote.RecordedBy,
+			AutoFilled: vote.AutoFilled,
+			Answers:    map[string]OptionAnswer{},
+		}
+		if vote.OfflineID == 0 {
+			participant.Email = vote.AccountEmail
+		}
+		for _, availability := range vote.Availabilities {
+			participant.Answers[availability.OptionID] = availability.Answer
+		}
+		export.Participants = append(export.Participants, participant)
+	}
+
+	for _, result := range CalculateResults(poll, votes).Options {
+		export.Totals = append(export.Totals, PollExportTotal{OptionID: result.Option.ID, Counts: result.Counts})
+	}
+
+	return export
+}
+
+// exportOptionLabel shows the option times, the end only has the time when the option ends on the day it starts.
+func exportOptionLabel(option PollOption, location *time.Location) string {
+	start := option.Start.In(location)
+	end := option.End.In(location)
+
+	endFormat := EXPORT_DATE_FORMAT
+	if start.Year() == end.Year() && start.YearDay() == end.YearDay() {
+		endFormat = EXPORT_TIME_FORMAT
+	}
+
+	return start.Format(EXPORT_DATE_FORMAT) + " - " + end.Format(endFormat)
+}
+
+// Table lays out the export as a spreadsheet: a header with the options, a row per participant and the totals at the bottom.
+func (e PollExport) Table() [][]string {
+	header := []string{fmt.Sprintf("Participant (%s)", e.TimeZone)}
+	for _, option := range e.Options {
+		label := option.Label
+		if option.ID == e.FinalOptionID {
+			label += " (final)"
+		}
+		header = append(header, label)
+	}
+	table := [][]string{header}
+
+	for _, participant := range e.Participants {
+		name := participant.Name
+		if participant.RecordedBy != "" {
+			name = fmt.Sprintf("%s (recorded by %s)", participant.Name, participant.RecordedBy)
+		}
+		if participant.AutoFilled {
+			name += " (auto-filled)"
+		}
+		row := []string{name}
+		for _, option := range e.Options {
+			row = append(row, participant.Answers[option.ID])
+		}
+		table = append(table, row)
+	}
+
+	for _, answer := range AllOptionAnswer {
+		row := []string{"Total " + answer}
+		for _, total := range e.Totals {
+			row = append(row, strconv.Itoa(total.Counts[answer]))
+		}
+		table = append(table, row)
+	}
+
+	return table
+}
+
+// XLSXRows is the Table with the counts of the totals rows as numbers, everything else stays text.
+func (e PollExport) XLSXRows() [][]XLSXCell {
+	rows := [][]XLSXCell{}
+	for idx, values := range e.Table() {
+		row := XLSXTextRow(values)
+		if idx > len(e.Participants) {
+			for column := 1; column < len(row); column++ {
+				row[column].Number = true
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// exportLocation reads the "tz" parameter, the time zone of the exported option times, UTC by default.
+func exportLocation(ctx *gin.Context) (*time.Location, bool) {
+	tz := ctx.Query("tz")
+	if tz == "" {
+		return time.UTC, true
+	}
+
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter tz"})
+		return nil, false
+	}
+
+	return location, true
+}
+
+// exportPoll downloads the votes of the poll as a spreadsheet, the "tz" parameter sets the time zone of the option times.
+func (a *APIServer) exportPoll(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	query := ctx.Request.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	if format != ExportCSV && format != ExportXLSX && format != ExportJSON {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter format, use csv, xlsx or json"})
+		return
+	}
+	location, ok := exportLocation(ctx)
+	if !ok {
+		return
+	}
+
+	votes, err := ListVotes(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	export := NewPollExport(poll, votes, location)
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, poll.ID, format))
+	switch format {
+	case ExportJSON:
+		ctx.JSON(http.StatusOK, gin.H{"data": export})
+	case ExportCSV:
+		body := &bytes.Buffer{}
+		writer := csv.NewWriter(body)
+		if err := writer.WriteAll(export.Table()); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		ctx.Data(http.StatusOK, "text/csv; charset=utf-8", body.Bytes())
+	case ExportXLSX:
+		body := &bytes.Buffer{}
+		if err := WriteXLSX(body, poll.Title, export.XLSXRows()); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		ctx.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", body.Bytes())
+	}
+}
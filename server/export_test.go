@@ -0,0 +1,113 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPollExportTable(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	poll := Poll{ID: "poll", FinalOptionID: "o2", PollBase: PollBase{Title: "Lunch", Options: []PollOption{
+		{ID: "o1", Start: time.Date(2024, 7, 1, 11, 0, 0, 0, time.UTC), End: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)},
+		{ID: "o2", Start: time.Date(2024, 7, 1, 22, 0, 0, 0, time.UTC), End: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)},
+	}}}
+	votes := []PollAccountAvailability{
+		{AccountEmail: "ana@example.com", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}, {OptionID: "o2", Answer: Maybe}}},
+		{OfflineID: 1, ParticipantName: "Grandma", RecordedBy: "ana@example.com", Availabilities: []OptionAvailability{{OptionID: "o2", Answer: Available}}},
+		{AccountEmail: "rui@example.com", AutoFilled: true, Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}}},
+	}
+
+	export := NewPollExport(poll, votes, lisbon)
+	expected := [][]string{
+		{"Participant (Europe/Lisbon)", "2024-07-01 12:00 - 13:00", "2024-07-01 23:00 - 2024-07-02 01:00 (final)"},
+		{"ana@example.com", Available, Maybe},
+		{"Grandma (recorded by ana@example.com)", "", Available},
+		{"rui@example.com (auto-filled)", Available, ""},
+		{"Total available", "1", "1"},
+		{"Total maybe", "0", "1"},
+		{"Total unavailable", "0", "0"},
+	}
+	if table := export.Table(); !reflect.DeepEqual(table, expected) {
+		t.Errorf("Expected %v, but got %v", expected, table)
+	}
+
+	if export.Participants[0].Email != "ana@example.com" || export.Participants[1].Email != "" {
+		t.Errorf("Expected only the account vote to have an email, but got %+v", export.Participants)
+	}
+
+	// Only the counts of the totals are numbers, the rows of the participants stay text.
+	for idx, row := range export.XLSXRows() {
+		for column, cell := range row {
+			if expected := idx > len(votes) && column > 0; cell.Number != expected {
+				t.Errorf("Expected the cell %d,%d to be a number %v, but got %+v", idx, column, expected, cell)
+			}
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	testCases := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+
+	for idx, expected := range testCases {
+		if column := xlsxColumn(idx); column != expected {
+			t.Errorf("Expected column %d to be %s, but got %s", idx, expected, column)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	testCases := map[string]string{
+		"Team lunch":            "Team lunch",
+		"Q1/Q2 [planning]?":     "Q1Q2 planning",
+		" *:? ":                 "Sheet1",
+		strings.Repeat("a", 40): strings.Repeat("a", XLSX_SHEET_NAME_MAX_LENGTH),
+	}
+
+	for name, expected := range testCases {
+		if sheetName := xlsxSheetName(name); sheetName != expected {
+			t.Errorf("Expected sheet name %q for %q, but got %q", expected, name, sheetName)
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	body := &bytes.Buffer{}
+	err := WriteXLSX(body, "Lunch", [][]XLSXCell{XLSXTextRow([]string{"Participant", "a < b", "007"}), {{Value: "Total"}, {Value: "3", Number: true}}})
+	if err != nil {
+		t.Fatalf("Expected the workbook to be written, but got %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body.Bytes()), int64(body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, but got %v", err)
+	}
+
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		content, _ := io.ReadAll(reader)
+		files[file.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected the part %s in the workbook", name)
+		}
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet, `<c r="B1" t="inlineStr"><is><t xml:space="preserve">a &lt; b</t></is></c>`) {
+		t.Errorf("Expected the escaped text cell, but got %s", sheet)
+	}
+	if !strings.Contains(sheet, `<c r="C1" t="inlineStr"><is><t xml:space="preserve">007</t></is></c>`) {
+		t.Errorf("Expected the text cell to keep its leading zeros, but got %s", sheet)
+	}
+	if !strings.Contains(sheet, `<c r="B2"><v>3</v></c>`) {
+		t.Errorf("Expected the number cell, but got %s", sheet)
+	}
+}
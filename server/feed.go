@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func calendarFeedURL(token string) string {
+	return baseURL + "/calendar/" + token + ".ics"
+}
+
+// CalendarFeedICS renders the calendar of an account: finalized polls are confirmed events
+// and the options of open polls answered available or maybe are tentative holds.
+func CalendarFeedICS(accountID int64, ownedPolls []Poll, votedPolls []VotedPoll, now time.Time) string {
+	w := &ICalWriter{}
+	w.Prop("BEGIN", "VCALENDAR")
+	w.Prop("VERSION", "2.0")
+	w.Prop("PRODID", ICAL_PRODID)
+	w.Prop("CALSCALE", "GREGORIAN")
+	w.Prop("METHOD", "PUBLISH")
+	w.Prop("X-WR-CALNAME", "roodle")
+	w.Line("REFRESH-INTERVAL", map[string]string{"VALUE": "DURATION"}, "PT1H")
+	w.Prop("X-PUBLISHED-TTL", "PT1H")
+
+	written := map[string]bool{}
+	for _, poll := range ownedPolls {
+		if option, ok := poll.FinalOption(); ok {
+			writeFeedEvent(w, pollEventUID(poll.ID), poll, option, "CONFIRMED", now)
+			written[poll.ID] = true
+		}
+	}
+
+	for _, votedPoll := range votedPolls {
+		if written[votedPoll.ID] {
+			continue
+		}
+
+		answers := map[string]OptionAnswer{}
+		for _, availability := range votedPoll.Availabilities {
+			answers[availability.OptionID] = availability.Answer
+		}
+
+		if option, ok := votedPoll.FinalOption(); ok {
+			if votedPoll.AccountID == accountID || answers[option.ID] != Unavailable {
+				writeFeedEvent(w, pollEventUID(votedPoll.ID), votedPoll.Poll, option, "CONFIRMED", now)
+			}
+			continue
+		}
+
+		for _, option := range votedPoll.Options {
+			if answer := answers[option.ID]; answer == Available || answer == Maybe {
+				writeFeedEvent(w, votedPoll.ID+"-"+option.ID+"@roodle", votedPoll.Poll, option, "TENTATIVE", now)
+			}
+		}
+	}
+
+	w.Prop("END", "VCALENDAR")
+
+	return w.String()
+}
+
+func writeFeedEvent(w *ICalWriter, uid string, poll Poll, option PollOption, status string, now time.Time) {
+	summary := poll.Title
+	if status == "TENTATIVE" {
+		summary = "(Tentative) " + summary
+	}
+
+	w.Prop("BEGIN", "VEVENT")
+	w.Prop("UID", uid)
+	w.Prop("DTSTAMP", icalTime(now))
+	w.Prop("DTSTART", icalTime(option.Start))
+	w.Prop("DTEND", icalTime(option.End))
+	w.Prop("SUMMARY", icalEscape(summary))
+	if poll.Description != "" {
+		w.Prop("DESCRIPTION", icalEscape(poll.Description))
+	}
+	if poll.Location != "" {
+		w.Prop("LOCATION", icalEscape(poll.Location))
+	}
+	w.Prop("URL", pollURL(poll.ID))
+	w.Prop("STATUS", status)
+	if status == "TENTATIVE" {
+		w.Prop("TRANSP", "TRANSPARENT")
+	} else {
+		w.Prop("TRANSP", "OPAQUE")
+	}
+	w.Prop("END", "VEVENT")
+}
+
+func (a *APIServer) getCalendarFeed(ctx *gin.Context, accountID int64) {
+	token, err := GetCalendarFeedToken(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if token == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "calendar feed not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"url": calendarFeedURL(token)}})
+}
+
+// newCalendarFeed creates the calendar feed url, a new url is generated every time so a leaked url can be replaced.
+func (a *APIServer) newCalendarFeed(ctx *gin.Context, accountID int64) {
+	token, err := SetCalendarFeedToken(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"url": calendarFeedURL(token)}})
+}
+
+func (a *APIServer) deleteCalendarFeed(ctx *gin.Context, accountID int64) {
+	err := DeleteCalendarFeed(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+func (a *APIServer) calendarFeed(ctx *gin.Context) {
+	token := strings.TrimSuffix(ctx.Params.ByName("token"), ".ics")
+	if token == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "calendar feed not found"})
+		return
+	}
+
+	accountID, err := GetCalendarFeedAccount(ctx, a.db, token)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if accountID == -1 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "calendar feed not found"})
+		return
+	}
+
+	ownedPolls, err := ListPolls(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	votedPolls, err := ListVotedPolls(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.Header("Cache-Control", "private, max-age=300")
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(CalendarFeedICS(accountID, ownedPolls, votedPolls, time.Now())))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarFeedICS(t *testing.T) {
+	start := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	options := []PollOption{
+		{ID: "o1", Start: start, End: start.Add(time.Hour)},
+		{ID: "o2", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+		{ID: "o3", Start: start.Add(48 * time.Hour), End: start.Add(49 * time.Hour)},
+	}
+	owned := []Poll{
+		{ID: "finalized", AccountID: 1, FinalOptionID: "o2", PollBase: PollBase{Title: "Planning, Q3; with a title long enough to be folded in the feed", Options: options}},
+		{ID: "open", AccountID: 1, PollBase: PollBase{Title: "Open", Options: options}},
+	}
+	voted := []VotedPoll{
+		{Poll: owned[0], Availabilities: []OptionAvailability{{"o2", Available}}},
+		{Poll: Poll{ID: "other", AccountID: 2, PollBase: PollBase{Title: "Lunch", Options: options}},
+			Availabilities: []OptionAvailability{{"o1", Available}, {"o2", Unavailable}, {"o3", Maybe}}},
+		{Poll: Poll{ID: "declined", AccountID: 2, FinalOptionID: "o1", PollBase: PollBase{Title: "Offsite", Options: options}},
+			Availabilities: []OptionAvailability{{"o1", Unavailable}}},
+	}
+
+	feed := CalendarFeedICS(1, owned, voted, start)
+	for _, line := range strings.Split(feed, "\r\n") {
+		if len(line) > ICAL_LINE_LIMIT {
+			t.Errorf("Expected lines to be folded, but got %q", line)
+		}
+	}
+
+	calendar, err := ParseICal(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("Expected feed to parse, but got %s", err)
+	}
+
+	events := map[string]*ICalComponent{}
+	for _, event := range calendar.Children("VEVENT") {
+		events[event.Value("UID")] = event
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, but got %d", len(events))
+	}
+
+	finalized := events["finalized@roodle"]
+	if finalized == nil || finalized.Value("STATUS") != "CONFIRMED" || finalized.Value("DTSTART") != "20240503T100000Z" {
+		t.Errorf("Unexpected finalized event %+v", finalized)
+	}
+	if summary := icalUnescape(finalized.Value("SUMMARY")); summary != owned[0].Title {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+	for _, uid := range []string{"other-o1@roodle", "other-o3@roodle"} {
+		if event := events[uid]; event == nil || event.Value("STATUS") != "TENTATIVE" {
+			t.Errorf("Expected tentative event %s", uid)
+		}
+	}
+}
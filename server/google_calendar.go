@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const (
+	GOOGLE_FREEBUSY_SCOPE = "https://www.googleapis.com/auth/calendar.freebusy"
+
+	calendarStateKey    = "calendar_state"
+	calendarScopeKey    = "calendar_scope"
+	calendarRedirectKey = "calendar_redirect"
+)
+
+var (
+	// googleAPIURL replaces the Google Calendar API endpoint, e.g. with a local stand-in during development.
+	googleAPIURL string
+
+	// googleCalendarScopes are the permissions that can be requested on top of the login scopes.
+	googleCalendarScopes = map[string]string{
+		"freebusy": GOOGLE_FREEBUSY_SCOPE,
+		"events":   calendar.CalendarEventsScope,
+	}
+
+	ErrGoogleCalendarNotConnected = errors.New("google calendar not connected")
+)
+
+// googleCalendarConfig is the login oauth2 config requesting the given calendar scopes,
+// with a refresh token so the calendar can be used while the user is not around.
+func googleCalendarConfig(scopes []string) *oauth2.Config {
+	calendarConf := *conf
+	calendarConf.RedirectURL = baseURL + "/auth/google/calendar/callback"
+	calendarConf.Scopes = scopes
+	return &calendarConf
+}
+
+type GoogleCalendar struct {
+	db  *sql.DB
+	api *APIServer
+}
+
+func NewGoogleCalendar(db *sql.DB) *GoogleCalendar {
+	return &GoogleCalendar{db: db, api: NewAPIServer(db)}
+}
+
+// Service returns a calendar client for the account, failing with ErrGoogleCalendarNotConnected
+// when the account did not grant the scope or revoked the access.
+func (g *GoogleCalendar) Service(ctx context.Context, accountID int64, scope string) (*calendar.Service, error) {
+	encryptedToken, scopes, err := GetGoogleCalendarToken(ctx, g.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if encryptedToken == nil || !slices.Contains(strings.Fields(scopes), scope) {
+		return nil, ErrGoogleCalendarNotConnected
+	}
+
+	refreshToken, err := decryptSecret(encryptedToken)
+	if err != nil {
+		logger.Error("failed to decrypt google calendar token", zap.Int64("accountID", accountID), zap.Error(err))
+		return nil, err
+	}
+
+	return googleCalendarService(ctx, refreshToken)
+}
+
+// handleError forgets the refresh token when google rejects it, so the user is asked to connect again.
+func (g *GoogleCalendar) handleError(ctx context.Context, accountID int64, err error) error {
+	retrieveError := &oauth2.RetrieveError{}
+	if errors.As(err, &retrieveError) && retrieveError.ErrorCode == "invalid_grant" {
+		logger.Warn("google calendar access revoked", zap.Int64("accountID", accountID))
+		if err := DeleteGoogleCalendarToken(ctx, g.db, accountID); err != nil {
+			return err
+		}
+		return ErrGoogleCalendarNotConnected
+	}
+
+	return err
+}
+
+func googleCalendarService(ctx context.Context, refreshToken string) (*calendar.Service, error) {
+	tokenSource := conf.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken})
+	options := []option.ClientOption{option.WithTokenSource(tokenSource)}
+	if googleAPIURL != "" {
+		options = append(options, option.WithEndpoint(googleAPIURL))
+	}
+
+	service, err := calendar.NewService(ctx, options...)
+	if err != nil {
+		logger.Error("failed to create google calendar service", zap.Error(err))
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// GoogleBusyPeriods queries the busy periods of the primary calendar between from and to.
+func GoogleBusyPeriods(ctx context.Context, service *calendar.Service, from time.Time, to time.Time) ([]BusyPeriod, error) {
+	response, err := service.Freebusy.Query(&calendar.FreeBusyRequest{
+		TimeMin: from.UTC().Format(time.RFC3339),
+		TimeMax: to.UTC().Format(time.RFC3339),
+		Items:   []*calendar.FreeBusyRequestItem{{Id: "primary"}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	primary, ok := response.Calendars["primary"]
+	if !ok {
+		return nil, fmt.Errorf("missing primary calendar in free/busy response")
+	}
+	if len(primary.Errors) > 0 {
+		return nil, fmt.Errorf("failed to query primary calendar: %s", primary.Errors[0].Reason)
+	}
+
+	busy := []BusyPeriod{}
+	for _, period := range primary.Busy {
+		start, err := time.Parse(time.RFC3339, period.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse(time.RFC3339, period.End)
+		if err != nil {
+			return nil, err
+		}
+		busy = append(busy, BusyPeriod{Start: start, End: end})
+	}
+
+	return busy, nil
+}
+
+// connectGoogleCalendar asks for the calendar scope in the scope parameter on top of the ones already granted.
+func (g *GoogleCalendar) connectGoogleCalendar(ctx *gin.Context, accountID int64) {
+	scope, ok := googleCalendarScopes[ctx.DefaultQuery("scope", "freebusy")]
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter scope"})
+		return
+	}
+
+	session := sessions.Default(ctx)
+	if from := ctx.Query("from"); strings.HasPrefix(from, "/") {
+		session.Set(calendarRedirectKey, from)
+	}
+	state := randomToken(16)
+	session.Set(calendarStateKey, state)
+	session.Set(calendarScopeKey, scope)
+	if err := session.Save(); err != nil {
+		logger.Error("failed to save session", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to save session"})
+		return
+	}
+
+	url := googleCalendarConfig([]string{scope}).AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.ApprovalForce,
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"))
+	ctx.Redirect(http.StatusFound, url)
+}
+
+func (g *GoogleCalendar) googleCalendarCallback(ctx *gin.Context, accountID int64) {
+	session := sessions.Default(ctx)
+	state := session.Get(calendarStateKey)
+	requestedScope, _ := session.Get(calendarScopeKey).(string)
+	redirect, ok := session.Get(calendarRedirectKey).(string)
+	if !ok {
+		redirect = "/"
+	}
+	session.Delete(calendarStateKey)
+	session.Delete(calendarScopeKey)
+	session.Delete(calendarRedirectKey)
+	if err := session.Save(); err != nil {
+		logger.Warn("failed to save session", zap.Error(err))
+	}
+
+	if state == nil || state != ctx.Query(stateKey) {
+		logger.Error("invalid calendar session state")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid state"})
+		return
+	}
+	if errorCode := ctx.Query("error"); errorCode != "" {
+		logger.Warn("google calendar consent denied", zap.String("error", errorCode))
+		ctx.Redirect(http.StatusFound, redirect)
+		return
+	}
+
+	token, err := googleCalendarConfig(nil).Exchange(ctx, ctx.Query("code"))
+	if err != nil {
+		logger.Error("failed to exchange code for oauth token", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to exchange code for oauth token"})
+		return
+	}
+
+	encryptedToken, scopes, err := GetGoogleCalendarToken(ctx, g.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	// Google only returns a new refresh token when the user is prompted for consent,
+	// the granted scopes include the previous ones since include_granted_scopes is set.
+	if token.RefreshToken != "" {
+		encryptedToken, err = encryptSecret(token.RefreshToken)
+		if err != nil {
+			logger.Error("failed to encrypt google calendar token", zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+	}
+	if encryptedToken == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing refresh token"})
+		return
+	}
+	if granted, ok := token.Extra("scope").(string); ok {
+		scopes = granted
+	} else if !slices.Contains(strings.Fields(scopes), requestedScope) {
+		scopes = strings.TrimSpace(scopes + " " + requestedScope)
+	}
+
+	err = SetGoogleCalendarToken(ctx, g.db, accountID, encryptedToken, scopes)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, redirect)
+}
+
+func (g *GoogleCalendar) getGoogleCalendar(ctx *gin.Context, accountID int64) {
+	encryptedToken, scopes, err := GetGoogleCalendarToken(ctx, g.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	granted := []string{}
+	for name, scope := range googleCalendarScopes {
+		if encryptedToken != nil && slices.Contains(strings.Fields(scopes), scope) {
+			granted = append(granted, name)
+		}
+	}
+	slices.Sort(granted)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"connected": encryptedToken != nil, "scopes": granted}})
+}
+
+func (g *GoogleCalendar) deleteGoogleCalendar(ctx *gin.Context, accountID int64) {
+	err := DeleteGoogleCalendarToken(ctx, g.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+// googleVote suggests a vote from the free/busy of the primary google calendar, like importVoteICS.
+func (g *GoogleCalendar) googleVote(ctx *gin.Context, accountID int64) {
+	poll, ok := g.api.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	busy := []BusyPeriod{}
+	if len(poll.Options) > 0 {
+		service, err := g.Service(ctx, accountID, GOOGLE_FREEBUSY_SCOPE)
+		if err == nil {
+			from, to := optionsWindow(poll.Options)
+			busy, err = GoogleBusyPeriods(ctx, service, from, to)
+			err = g.handleError(ctx, accountID, err)
+		}
+		if errors.Is(err, ErrGoogleCalendarNotConnected) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to query google calendar free/busy", zap.Int64("accountID", accountID), zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "failed to query google calendar"})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"vote": PollAccountAvailability{
+			PollID:         poll.ID,
+			AccountID:      accountID,
+			Availabilities: SuggestAvailabilities(poll.Options, busy),
+		},
+		"busy": busy,
+	}})
+}
+
+// GoogleCalendarEvent is the event of a finalized poll in the organizer calendar, attended by the voters available on the final option.
+func GoogleCalendarEvent(poll Poll, organizerEmail string, votes []PollAccountAvailability) *calendar.Event {
+	option, _ := poll.FinalOption()
+
+	attendees := []*calendar.EventAttendee{}
+	for _, vote := range votes {
+		if vote.AccountEmail == "" || vote.AutoFilled || strings.EqualFold(vote.AccountEmail, organizerEmail) {
+			continue
+		}
+		for _, availability := range vote.Availabilities {
+			if availability.OptionID == option.ID && availability.Answer == Available {
+				attendees = append(attendees, &calendar.EventAttendee{Email: vote.AccountEmail})
+			}
+		}
+	}
+
+	return &calendar.Event{
+		Summary:     poll.Title,
+		Description: strings.TrimSpace(poll.Description + "\n\n" + pollURL(poll.ID)),
+		Location:    poll.Location,
+		Start:       &calendar.EventDateTime{DateTime: option.Start.UTC().Format(time.RFC3339)},
+		End:         &calendar.EventDateTime{DateTime: option.End.UTC().Format(time.RFC3339)},
+		Attendees:   attendees,
+		Source:      &calendar.EventSource{Title: "roodle", Url: pollURL(poll.ID)},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{"roodle_poll_id": poll.ID},
+		},
+	}
+}
+
+// GoogleCalendarConsumer keeps an event in the organizer google calendar in sync with the final option of a poll.
+// Every event syncs the current state of the poll, so retried or reordered deliveries converge.
+type GoogleCalendarConsumer struct {
+	calendar *GoogleCalendar
+}
+
+func NewGoogleCalendarConsumer(db *sql.DB) *GoogleCalendarConsumer {
+	return &GoogleCalendarConsumer{calendar: NewGoogleCalendar(db)}
+}
+
+func (c *GoogleCalendarConsumer) Name() string {
+	return "google-calendar"
+}
+
+func (c *GoogleCalendarConsumer) Consume(ctx context.Context, event PollEvent) error {
+	switch event.Type {
+	case PollFinalizedEvent, PollReopenedEvent, PollDeletedEvent, PollRestoredEvent, PollTransferredEvent, VoteCastEvent:
+	default:
+		return nil
+	}
+
+	db := c.calendar.db
+	poll, err := GetPoll(ctx, db, event.PollID)
+	if err != nil {
+		return err
+	}
+	eventAccountID, eventID, err := GetGoogleCalendarEvent(ctx, db, event.PollID)
+	if err != nil {
+		return err
+	}
+
+	if reflect.ValueOf(poll).IsZero() || poll.FinalOptionID == "" {
+		if eventID == "" {
+			return nil
+		}
+		return c.deleteEvent(ctx, event.PollID, eventAccountID, eventID)
+	}
+
+	// Votes only change the attendees of an existing event and transfers only move it.
+	if eventID == "" && (event.Type == VoteCastEvent || event.Type == PollTransferredEvent) {
+		return nil
+	}
+	if event.Type == VoteCastEvent && eventAccountID == poll.AccountID {
+		return c.syncAttendees(ctx, poll, eventID)
+	}
+
+	return c.saveEvent(ctx, poll, eventAccountID, eventID)
+}
+
+// syncAttendees changes the attendees of the event after a vote. Nothing is saved when the vote did not change who
+// attends, and only the guests without a google calendar are emailed, so they do not get an update for every vote.
+func (c *GoogleCalendarConsumer) syncAttendees(ctx context.Context, poll Poll, eventID string) error {
+	db := c.calendar.db
+
+	service, err := c.calendar.Service(ctx, poll.AccountID, calendar.CalendarEventsScope)
+	if errors.Is(err, ErrGoogleCalendarNotConnected) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	organizer, err := GetAccountByID(ctx, db, poll.AccountID)
+	if err != nil {
+		return err
+	}
+	votes, err := ListVotes(ctx, db, poll.ID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := service.Events.Get("primary", eventID).Context(ctx).Do()
+	// Events deleted by the organizer are created again.
+	if isGoogleNotFound(err) {
+		return c.saveEvent(ctx, poll, poll.AccountID, eventID)
+	}
+	if err == nil {
+		attendees, changed := mergeGoogleAttendees(existing.Attendees, GoogleCalendarEvent(poll, organizer.Email, votes).Attendees)
+		if !changed {
+			return nil
+		}
+		patch := &calendar.Event{Attendees: attendees, ForceSendFields: []string{"Attendees"}}
+		_, err = service.Events.Patch("primary", eventID, patch).SendUpdates("externalOnly").Context(ctx).Do()
+	}
+	if err = c.calendar.handleError(ctx, poll.AccountID, err); err != nil {
+		if errors.Is(err, ErrGoogleCalendarNotConnected) {
+			return nil
+		}
+		logger.Error("failed to sync google calendar event attendees", zap.String("pollID", poll.ID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// mergeGoogleAttendees keeps the attendees of the event that still attend, with their responses, and adds the new ones.
+// The organizer, listed by google as an attendee, is always kept.
+func mergeGoogleAttendees(existing []*calendar.EventAttendee, attending []*calendar.EventAttendee) ([]*calendar.EventAttendee, bool) {
+	attendingEmails := map[string]bool{}
+	for _, attendee := range attending {
+		attendingEmails[strings.ToLower(attendee.Email)] = true
+	}
+
+	merged := []*calendar.EventAttendee{}
+	kept := map[string]bool{}
+	changed := false
+	for _, attendee := range existing {
+		email := strings.ToLower(attendee.Email)
+		switch {
+		case attendee.Organizer || attendee.Self:
+			merged = append(merged, attendee)
+		case attendingEmails[email]:
+			merged = append(merged, attendee)
+			kept[email] = true
+		default:
+			changed = true
+		}
+	}
+	for _, attendee := range attending {
+		if !kept[strings.ToLower(attendee.Email)] {
+			merged = append(merged, attendee)
+			changed = true
+		}
+	}
+
+	return merged, changed
+}
+
+func (c *GoogleCalendarConsumer) saveEvent(ctx context.Context, poll Poll, eventAccountID int64, eventID string) error {
+	db := c.calendar.db
+
+	// The event is recreated in the calendar of the new organizer when the poll changes hands.
+	if eventID != "" && eventAccountID != poll.AccountID {
+		if err := c.deleteEvent(ctx, poll.ID, eventAccountID, eventID); err != nil {
+			return err
+		}
+		eventID = ""
+	}
+
+	service, err := c.calendar.Service(ctx, poll.AccountID, calendar.CalendarEventsScope)
+	if errors.Is(err, ErrGoogleCalendarNotConnected) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	organizer, err := GetAccountByID(ctx, db, poll.AccountID)
+	if err != nil {
+		return err
+	}
+	votes, err := ListVotes(ctx, db, poll.ID)
+	if err != nil {
+		return err
+	}
+	calendarEvent := GoogleCalendarEvent(poll, organizer.Email, votes)
+
+	var saved *calendar.Event
+	if eventID != "" {
+		saved, err = service.Events.Update("primary", eventID, calendarEvent).SendUpdates("all").Context(ctx).Do()
+		// Events deleted by the organizer are created again.
+		if isGoogleNotFound(err) {
+			eventID = ""
+		}
+	}
+	if eventID == "" {
+		saved, err = service.Events.Insert("primary", calendarEvent).SendUpdates("all").Context(ctx).Do()
+	}
+	if err = c.calendar.handleError(ctx, poll.AccountID, err); err != nil {
+		if errors.Is(err, ErrGoogleCalendarNotConnected) {
+			return nil
+		}
+		logger.Error("failed to save google calendar event", zap.String("pollID", poll.ID), zap.Error(err))
+		return err
+	}
+
+	return SetGoogleCalendarEvent(ctx, db, poll.ID, poll.AccountID, saved.Id)
+}
+
+func (c *GoogleCalendarConsumer) deleteEvent(ctx context.Context, pollID string, accountID int64, eventID string) error {
+	service, err := c.calendar.Service(ctx, accountID, calendar.CalendarEventsScope)
+	if err == nil {
+		err = service.Events.Delete("primary", eventID).SendUpdates("all").Context(ctx).Do()
+		if isGoogleNotFound(err) {
+			err = nil
+		}
+		err = c.calendar.handleError(ctx, accountID, err)
+	}
+	// Without access the event can not be removed anymore, only forgotten.
+	if err != nil && !errors.Is(err, ErrGoogleCalendarNotConnected) {
+		logger.Error("failed to delete google calendar event", zap.String("pollID", pollID), zap.Error(err))
+		return err
+	}
+
+	return DeleteGoogleCalendarEvent(ctx, c.calendar.db, pollID)
+}
+
+func isGoogleNotFound(err error) bool {
+	apiError := &googleapi.Error{}
+	return errors.As(err, &apiError) && (apiError.Code == http.StatusNotFound || apiError.Code == http.StatusGone)
+}
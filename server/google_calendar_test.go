@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+)
+
+// newGoogleStandIn serves the oauth2 token and calendar free/busy endpoints used by the integration.
+func newGoogleStandIn(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "valid-refresh-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/calendar/v3/freeBusy", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		request := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["timeMin"] != "2024-05-06T08:00:00Z" || request["timeMax"] != "2024-05-08T09:00:00Z" {
+			t.Errorf("Unexpected free/busy range %v - %v", request["timeMin"], request["timeMax"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"calendars":{"primary":{"busy":[{"start":"2024-05-06T08:30:00Z","end":"2024-05-06T10:00:00Z"}]}}}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGoogleBusyPeriods(t *testing.T) {
+	server := newGoogleStandIn(t)
+	defer server.Close()
+
+	defer func(previous *oauth2.Config) { conf = previous }(conf)
+	conf = &oauth2.Config{ClientID: "client", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"}}
+	googleAPIURL = server.URL + "/calendar/v3/"
+	defer func() { googleAPIURL = "" }()
+
+	start := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	options := []PollOption{
+		{ID: "o1", Start: start, End: start.Add(time.Hour)},
+		{ID: "o2", Start: start.Add(48 * time.Hour), End: start.Add(49 * time.Hour)},
+	}
+	from, to := optionsWindow(options)
+
+	service, err := googleCalendarService(context.Background(), "valid-refresh-token")
+	if err != nil {
+		t.Fatalf("Expected service, but got %s", err)
+	}
+	busy, err := GoogleBusyPeriods(context.Background(), service, from, to)
+	if err != nil {
+		t.Fatalf("Expected busy periods, but got %s", err)
+	}
+
+	expected := []OptionAvailability{{"o1", Unavailable}, {"o2", Available}}
+	if availabilities := SuggestAvailabilities(options, busy); !reflect.DeepEqual(availabilities, expected) {
+		t.Errorf("Expected %v, but got %v", expected, availabilities)
+	}
+
+	service, err = googleCalendarService(context.Background(), "revoked-refresh-token")
+	if err != nil {
+		t.Fatalf("Expected service, but got %s", err)
+	}
+	_, err = GoogleBusyPeriods(context.Background(), service, from, to)
+	retrieveError := &oauth2.RetrieveError{}
+	if !errors.As(err, &retrieveError) || retrieveError.ErrorCode != "invalid_grant" {
+		t.Errorf("Expected invalid_grant error, but got %v", err)
+	}
+}
+
+func TestGoogleCalendarEvent(t *testing.T) {
+	start := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	poll := Poll{ID: "poll", AccountID: 1, FinalOptionID: "o2", PollBase: PollBase{
+		Title:    "Planning",
+		Location: "Room 1",
+		Options: []PollOption{
+			{ID: "o1", Start: start, End: start.Add(time.Hour)},
+			{ID: "o2", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+		},
+	}}
+	votes := []PollAccountAvailability{
+		{AccountID: 1, AccountEmail: "owner@example.com", Availabilities: []OptionAvailability{{"o2", Available}}},
+		{AccountID: 2, AccountEmail: "jane@example.com", Availabilities: []OptionAvailability{{"o1", Unavailable}, {"o2", Available}}},
+		{AccountID: 3, AccountEmail: "john@example.com", Availabilities: []OptionAvailability{{"o1", Available}, {"o2", Maybe}}},
+		{AccountID: 4, AccountEmail: "rui@example.com", AutoFilled: true, Availabilities: []OptionAvailability{{"o2", Available}}},
+	}
+
+	event := GoogleCalendarEvent(poll, "owner@example.com", votes)
+	if event.Start.DateTime != "2024-05-07T08:00:00Z" || event.End.DateTime != "2024-05-07T09:00:00Z" {
+		t.Errorf("Unexpected event time %s - %s", event.Start.DateTime, event.End.DateTime)
+	}
+	if event.Summary != "Planning" || event.Location != "Room 1" || event.ExtendedProperties.Private["roodle_poll_id"] != "poll" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if len(event.Attendees) != 1 || event.Attendees[0].Email != "jane@example.com" {
+		t.Errorf("Expected jane@example.com to be the only attendee, but got %v", event.Attendees)
+	}
+}
+
+func TestMergeGoogleAttendees(t *testing.T) {
+	organizer := &calendar.EventAttendee{Email: "owner@example.com", Organizer: true, ResponseStatus: "accepted"}
+	jane := &calendar.EventAttendee{Email: "Jane@example.com", ResponseStatus: "accepted"}
+	john := &calendar.EventAttendee{Email: "john@example.com", ResponseStatus: "needsAction"}
+
+	testCases := []struct {
+		existing  []*calendar.EventAttendee
+		attending []*calendar.EventAttendee
+		expected  []string
+		changed   bool
+	}{
+		{[]*calendar.EventAttendee{organizer, jane}, []*calendar.EventAttendee{{Email: "jane@example.com"}}, []string{"owner@example.com", "Jane@example.com"}, false},
+		{[]*calendar.EventAttendee{organizer, jane}, []*calendar.EventAttendee{{Email: "jane@example.com"}, john}, []string{"owner@example.com", "Jane@example.com", "john@example.com"}, true},
+		{[]*calendar.EventAttendee{organizer, jane, john}, []*calendar.EventAttendee{john}, []string{"owner@example.com", "john@example.com"}, true},
+		{[]*calendar.EventAttendee{organizer}, []*calendar.EventAttendee{}, []string{"owner@example.com"}, false},
+	}
+
+	for _, testCase := range testCases {
+		merged, changed := mergeGoogleAttendees(testCase.existing, testCase.attending)
+		emails := []string{}
+		for _, attendee := range merged {
+			emails = append(emails, attendee.Email)
+		}
+		if !reflect.DeepEqual(emails, testCase.expected) || changed != testCase.changed {
+			t.Errorf("Expected %v (changed %v), but got %v (changed %v)", testCase.expected, testCase.changed, emails, changed)
+		}
+	}
+
+	// Responses of the attendees who keep attending are not reset.
+	merged, _ := mergeGoogleAttendees([]*calendar.EventAttendee{jane}, []*calendar.EventAttendee{{Email: "jane@example.com"}})
+	if merged[0].ResponseStatus != "accepted" {
+		t.Errorf("Expected the response to be kept, but got %q", merged[0].ResponseStatus)
+	}
+}
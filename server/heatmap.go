@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/mail"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+type HeatmapFormat = string
+
+const (
+	HeatmapSVG HeatmapFormat = "svg"
+	HeatmapPNG HeatmapFormat = "png"
+)
+
+const (
+	HEATMAP_PADDING          = 8
+	HEATMAP_NAME_WIDTH       = 132
+	HEATMAP_CELL_WIDTH       = 72
+	HEATMAP_ROW_HEIGHT       = 16
+	HEATMAP_HEADER_HEIGHT    = 28
+	HEATMAP_MAX_PARTICIPANTS = 50
+	HEATMAP_MAX_OPTIONS      = 24
+	// HEATMAP_PNG_SCALE draws the PNG at twice the SVG size, so the bitmap font stays readable on high density screens.
+	HEATMAP_PNG_SCALE = 2
+)
+
+var (
+	heatmapAnswerColors = map[OptionAnswer]color.RGBA{
+		Available:   {0x8f, 0xd1, 0x9e, 0xff},
+		Maybe:       {0xff, 0xe0, 0x8a, 0xff},
+		Unavailable: {0xf2, 0xa7, 0xa7, 0xff},
+	}
+	heatmapBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	heatmapUnanswered = color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	heatmapHeader     = color.RGBA{0xe4, 0xe4, 0xe4, 0xff}
+	heatmapFinal      = color.RGBA{0xbf, 0xd9, 0xff, 0xff}
+	heatmapText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	heatmapMuted      = color.RGBA{0x88, 0x88, 0x88, 0xff}
+)
+
+type heatmapRect struct {
+	X, Y, Width, Height int
+	Color               color.RGBA
+}
+
+// heatmapLabel is a line of text, Y is the top of the glyphs.
+type heatmapLabel struct {
+	X, Y  int
+	Bold  bool
+	Text  string
+	Color color.RGBA
+}
+
+// Heatmap is the layout of the availability matrix of a poll, with a row per participant and a column per option.
+// The SVG and the PNG are drawn from the same layout, with the text sized for the bitmap font.
+type Heatmap struct {
+	Width  int
+	Height int
+	Rects  []heatmapRect
+	Labels []heatmapLabel
+}
+
+func (h *Heatmap) rect(x int, y int, width int, height int, c color.RGBA) {
+	h.Rects = append(h.Rects, heatmapRect{X: x, Y: y, Width: width, Height: height, Color: c})
+}
+
+// label adds the text, truncated to the width.
+func (h *Heatmap) label(x int, y int, width int, bold bool, text string, c color.RGBA) {
+	text = BitmapText(text)
+	if maxLength := width / BITMAP_FONT_ADVANCE; len(text) > maxLength {
+		text = strings.TrimSpace(text[:max(maxLength-2, 0)]) + ".."
+	}
+	h.Labels = append(h.Labels, heatmapLabel{X: x, Y: y, Bold: bold, Text: text, Color: c})
+}
+
+// heatmapParticipantName keeps the emails out of the image, showing the display name or the part before the "@".
+func heatmapParticipantName(participant PollExportParticipant) string {
+	name := participant.Name
+	if address, err := mail.ParseAddress(name); err == nil {
+		if address.Name != "" {
+			return address.Name
+		}
+		name = address.Address
+	}
+	if at := strings.Index(name, "@"); at > 0 {
+		return name[:at]
+	}
+
+	return name
+}
+
+// NewHeatmap lays out the answers of the participants, the totals below them and a legend of the answer colors.
+// Polls with too many options or participants only show the first ones, with a note on how many were left out.
+func NewHeatmap(export PollExport) Heatmap {
+	options := export.Options[:min(len(export.Options), HEATMAP_MAX_OPTIONS)]
+	participants := export.Participants[:min(len(export.Participants), HEATMAP_MAX_PARTICIPANTS)]
+	optionX := func(idx int) int {
+		return HEATMAP_PADDING + HEATMAP_NAME_WIDTH + idx*HEATMAP_CELL_WIDTH
+	}
+	textOffset := (HEATMAP_ROW_HEIGHT - BITMAP_FONT_HEIGHT) / 2
+
+	h := Heatmap{Width: max(optionX(len(options))+HEATMAP_PADDING, 2*HEATMAP_PADDING+HEATMAP_NAME_WIDTH+2*HEATMAP_CELL_WIDTH)}
+	h.rect(0, 0, h.Width, 0, heatmapBackground)
+
+	y := HEATMAP_PADDING
+	h.label(HEATMAP_PADDING, y, h.Width-2*HEATMAP_PADDING, true, export.Title, heatmapText)
+	y += BITMAP_FONT_HEIGHT + HEATMAP_PADDING
+
+	participantsLabel := fmt.Sprintf("%d participants", len(export.Participants))
+	if len(export.Participants) == 1 {
+		participantsLabel = "1 participant"
+	}
+	h.label(HEATMAP_PADDING, y+HEATMAP_HEADER_HEIGHT-BITMAP_FONT_HEIGHT-6, HEATMAP_NAME_WIDTH-4, false, participantsLabel, heatmapMuted)
+	for idx, option := range options {
+		background := heatmapHeader
+		if option.ID == export.FinalOptionID {
+			background = heatmapFinal
+		}
+		h.rect(optionX(idx), y, HEATMAP_CELL_WIDTH-1, HEATMAP_HEADER_HEIGHT-1, background)
+		h.label(optionX(idx)+3, y+5, HEATMAP_CELL_WIDTH-6, true, option.Start.Format("Mon 02 Jan"), heatmapText)
+		h.label(optionX(idx)+3, y+16, HEATMAP_CELL_WIDTH-6, false, option.Start.Format("15:04")+"-"+option.End.Format("15:04"), heatmapText)
+	}
+	y += HEATMAP_HEADER_HEIGHT
+
+	for _, participant := range participants {
+		// Auto-filled votes are marked before the name, so the mark is kept when long names are truncated.
+		if participant.AutoFilled {
+			h.label(HEATMAP_PADDING, y+textOffset, HEATMAP_NAME_WIDTH-4, false, "(auto) "+heatmapParticipantName(participant), heatmapMuted)
+		} else {
+			h.label(HEATMAP_PADDING, y+textOffset, HEATMAP_NAME_WIDTH-4, false, heatmapParticipantName(participant), heatmapText)
+		}
+		for idx, option := range options {
+			background := heatmapUnanswered
+			if answer, ok := participant.Answers[option.ID]; ok {
+				background = heatmapAnswerColors[answer]
+			}
+			h.rect(optionX(idx), y, HEATMAP_CELL_WIDTH-1, HEATMAP_ROW_HEIGHT-1, background)
+		}
+		y += HEATMAP_ROW_HEIGHT
+	}
+	if hidden := len(export.Participants) - len(participants); hidden > 0 {
+		h.label(HEATMAP_PADDING, y+textOffset, HEATMAP_NAME_WIDTH-4, false, fmt.Sprintf("+ %d more", hidden), heatmapMuted)
+		y += HEATMAP_ROW_HEIGHT
+	}
+
+	for _, answer := range []OptionAnswer{Available, Maybe} {
+		h.label(HEATMAP_PADDING, y+textOffset, HEATMAP_NAME_WIDTH-4, true, "Total "+pollPDFAnswerLabels[answer], heatmapText)
+		for idx := range options {
+			h.label(optionX(idx)+3, y+textOffset, HEATMAP_CELL_WIDTH-6, true, strconv.Itoa(export.Totals[idx].Counts[answer]), heatmapText)
+		}
+		y += HEATMAP_ROW_HEIGHT
+	}
+
+	y += HEATMAP_PADDING
+	x := HEATMAP_PADDING
+	for _, answer := range AllOptionAnswer {
+		h.rect(x, y, BITMAP_FONT_HEIGHT+1, BITMAP_FONT_HEIGHT+1, heatmapAnswerColors[answer])
+		label := pollPDFAnswerLabels[answer]
+		h.label(x+BITMAP_FONT_HEIGHT+5, y+1, len(label)*BITMAP_FONT_ADVANCE, false, label, heatmapText)
+		x += BITMAP_FONT_HEIGHT + 5 + (len(label)+2)*BITMAP_FONT_ADVANCE
+	}
+	if hidden := len(export.Options) - len(options); hidden > 0 {
+		h.label(x, y+1, h.Width-HEATMAP_PADDING-x, false, fmt.Sprintf("+ %d more options", hidden), heatmapMuted)
+	}
+	y += BITMAP_FONT_HEIGHT + 1 + HEATMAP_PADDING
+
+	h.Height = y
+	h.Rects[0].Height = h.Height
+
+	return h
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// SVG draws the heatmap with a monospace font, which has about the advance of the bitmap font at this size.
+func (h Heatmap) SVG() []byte {
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="10">`,
+		h.Width, h.Height, h.Width, h.Height)
+	out.WriteString("\n")
+	for _, rect := range h.Rects {
+		fmt.Fprintf(out, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, rect.X, rect.Y, rect.Width, rect.Height, svgColor(rect.Color))
+		out.WriteString("\n")
+	}
+	for _, label := range h.Labels {
+		weight := ""
+		if label.Bold {
+			weight = ` font-weight="bold"`
+		}
+		fmt.Fprintf(out, `<text x="%d" y="%d" fill="%s"%s>%s</text>`, label.X, label.Y+BITMAP_FONT_HEIGHT, svgColor(label.Color), weight, xmlEscape(label.Text))
+		out.WriteString("\n")
+	}
+	out.WriteString("</svg>\n")
+
+	return out.Bytes()
+}
+
+// PNG rasterizes the heatmap at HEATMAP_PNG_SCALE with the bitmap font.
+func (h Heatmap) PNG() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, h.Width*HEATMAP_PNG_SCALE, h.Height*HEATMAP_PNG_SCALE))
+	for _, rect := range h.Rects {
+		bounds := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height)
+		fillRect(img, image.Rectangle{Min: bounds.Min.Mul(HEATMAP_PNG_SCALE), Max: bounds.Max.Mul(HEATMAP_PNG_SCALE)}, rect.Color)
+	}
+	for _, label := range h.Labels {
+		DrawBitmapText(img, label.X*HEATMAP_PNG_SCALE, label.Y*HEATMAP_PNG_SCALE, HEATMAP_PNG_SCALE, label.Bold, label.Text, label.Color)
+	}
+
+	out := &bytes.Buffer{}
+	if err := png.Encode(out, img); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// writeHeatmap renders the votes of the poll, the "tz" parameter sets the time zone of the option times.
+func (a *APIServer) writeHeatmap(ctx *gin.Context, poll Poll, format HeatmapFormat, cacheControl string) {
+	if format != HeatmapSVG && format != HeatmapPNG {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter format, use svg or png"})
+		return
+	}
+	location, ok := exportLocation(ctx)
+	if !ok {
+		return
+	}
+
+	votes, err := ListVotes(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	heatmap := NewHeatmap(NewPollExport(poll, votes, location))
+
+	ctx.Header("Cache-Control", cacheControl)
+	switch format {
+	case HeatmapSVG:
+		ctx.Data(http.StatusOK, "image/svg+xml", heatmap.SVG())
+	case HeatmapPNG:
+		body, err := heatmap.PNG()
+		if err != nil {
+			logger.Error("failed to encode heatmap", zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		ctx.Data(http.StatusOK, "image/png", body)
+	}
+}
+
+// getPollHeatmap renders the answers of a poll the account can view as an image.
+func (a *APIServer) getPollHeatmap(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	format := ctx.Query("format")
+	if format == "" {
+		format = HeatmapSVG
+	}
+	a.writeHeatmap(ctx, poll, format, "private, max-age=60")
+}
+
+// pollHeatmapImage serves "/heatmap/<poll id>.svg" and ".png" without a session, so chat unfurls, emails and wiki
+// pages can embed the live results. Only the polls visible to anyone with the link are served.
+func (a *APIServer) pollHeatmapImage(ctx *gin.Context) {
+	file := ctx.Params.ByName("file")
+	extension := path.Ext(file)
+	pollID := strings.TrimSuffix(file, extension)
+	if pollID == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return
+	}
+
+	poll, err := GetPoll(ctx, a.db, pollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return
+	}
+
+	// Without an account the workspace role is empty, so only the polls shared by link are visible.
+	visible, err := a.canViewPoll(ctx, poll, 0)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !visible {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return
+	}
+
+	a.writeHeatmap(ctx, poll, strings.TrimPrefix(extension, "."), "public, max-age=60")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBitmapText(t *testing.T) {
+	testCases := map[string]string{
+		"Team lunch":   "Team lunch",
+		"João Gonçal":  "Joao Goncal",
+		"Zürich\tBern": "Zurich Bern",
+		"Lunch 🍕":      "Lunch ?",
+	}
+
+	for text, expected := range testCases {
+		if folded := BitmapText(text); folded != expected {
+			t.Errorf("Expected %q for %q, but got %q", expected, text, folded)
+		}
+	}
+}
+
+func TestHeatmapParticipantName(t *testing.T) {
+	testCases := map[string]string{
+		"ana@example.com":       "ana",
+		"Ana <ana@example.com>": "Ana",
+		"Grandma":               "Grandma",
+	}
+
+	for name, expected := range testCases {
+		if shown := heatmapParticipantName(PollExportParticipant{Name: name}); shown != expected {
+			t.Errorf("Expected %q for %q, but got %q", expected, name, shown)
+		}
+	}
+}
+
+func heatmapTestExport(options int, participants int) PollExport {
+	poll := Poll{ID: "poll", FinalOptionID: "o0", PollBase: PollBase{Title: "Team <lunch>"}}
+	for idx := 0; idx < options; idx++ {
+		start := time.Date(2024, 7, 1+idx, 12, 0, 0, 0, time.UTC)
+		poll.Options = append(poll.Options, PollOption{ID: fmt.Sprintf("o%d", idx), Start: start, End: start.Add(time.Hour)})
+	}
+	votes := []PollAccountAvailability{}
+	for idx := 0; idx < participants; idx++ {
+		votes = append(votes, PollAccountAvailability{
+			AccountEmail:   fmt.Sprintf("participant%d@example.com", idx),
+			Availabilities: []OptionAvailability{{OptionID: "o0", Answer: Available}, {OptionID: "o1", Answer: Maybe}},
+		})
+	}
+
+	return NewPollExport(poll, votes, time.UTC)
+}
+
+func TestNewHeatmap(t *testing.T) {
+	heatmap := NewHeatmap(heatmapTestExport(3, 2))
+
+	expectedWidth := 2*HEATMAP_PADDING + HEATMAP_NAME_WIDTH + 3*HEATMAP_CELL_WIDTH
+	if heatmap.Width != expectedWidth {
+		t.Errorf("Expected width %d, but got %d", expectedWidth, heatmap.Width)
+	}
+	if heatmap.Rects[0].Width != heatmap.Width || heatmap.Rects[0].Height != heatmap.Height {
+		t.Errorf("Expected the background to cover the image, but got %v", heatmap.Rects[0])
+	}
+
+	labels := []string{}
+	for _, label := range heatmap.Labels {
+		labels = append(labels, label.Text)
+	}
+	for _, expected := range []string{"Team <lunch>", "2 participants", "Mon 01 Jul", "12:00-13:00", "participant1", "Total Yes"} {
+		found := false
+		for _, label := range labels {
+			found = found || label == expected
+		}
+		if !found {
+			t.Errorf("Expected the label %q, but got %q", expected, labels)
+		}
+	}
+	for _, label := range labels {
+		if strings.Contains(label, "@") {
+			t.Errorf("Expected no emails in the heatmap, but got %q", label)
+		}
+	}
+}
+
+func TestNewHeatmapLimits(t *testing.T) {
+	heatmap := NewHeatmap(heatmapTestExport(HEATMAP_MAX_OPTIONS+2, HEATMAP_MAX_PARTICIPANTS+3))
+
+	expectedWidth := 2*HEATMAP_PADDING + HEATMAP_NAME_WIDTH + HEATMAP_MAX_OPTIONS*HEATMAP_CELL_WIDTH
+	if heatmap.Width != expectedWidth {
+		t.Errorf("Expected width %d, but got %d", expectedWidth, heatmap.Width)
+	}
+	notes := 0
+	for _, label := range heatmap.Labels {
+		if label.Text == "+ 3 more" || label.Text == "+ 2 more options" {
+			notes++
+		}
+	}
+	if notes != 2 {
+		t.Errorf("Expected notes for the participants and options left out, but got %d", notes)
+	}
+}
+
+func TestHeatmapImages(t *testing.T) {
+	heatmap := NewHeatmap(heatmapTestExport(2, 1))
+
+	svg := heatmap.SVG()
+	if !bytes.HasPrefix(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg"`)) || !bytes.Contains(svg, []byte("Team &lt;lunch&gt;")) {
+		t.Errorf("Expected an SVG with the escaped title, but got %s", svg)
+	}
+
+	encoded, err := heatmap.PNG()
+	if err != nil {
+		t.Fatalf("Expected the PNG to be encoded, but got %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("Expected a valid PNG, but got %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != heatmap.Width*HEATMAP_PNG_SCALE || bounds.Dy() != heatmap.Height*HEATMAP_PNG_SCALE {
+		t.Errorf("Expected a %dx%d image, but got %v", heatmap.Width*HEATMAP_PNG_SCALE, heatmap.Height*HEATMAP_PNG_SCALE, bounds)
+	}
+
+	// The first participant answered the first option, its cell is right below the header.
+	x := (HEATMAP_PADDING + HEATMAP_NAME_WIDTH + 2) * HEATMAP_PNG_SCALE
+	y := (2*HEATMAP_PADDING + BITMAP_FONT_HEIGHT + HEATMAP_HEADER_HEIGHT + 1) * HEATMAP_PNG_SCALE
+	r, g, b, _ := img.At(x, y).RGBA()
+	expected := heatmapAnswerColors[Available]
+	if uint8(r>>8) != expected.R || uint8(g>>8) != expected.G || uint8(b>>8) != expected.B {
+		t.Errorf("Expected the available color at %d,%d, but got %v", x, y, img.At(x, y))
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	HOLIDAY_DATE_FORMAT          = "2006-01-02"
+	OPTION_GENERATOR_MAX_DAYS    = 366
+	OPTION_GENERATOR_MAX_OPTIONS = 100
+)
+
+//go:embed holidays/*.json
+var holidayDatasets embed.FS
+
+// holidayCalendars are the embedded holiday datasets indexed by ISO 3166-1 country code.
+var holidayCalendars = map[string]HolidayCalendar{}
+
+func init() {
+	calendars, err := loadHolidayCalendars()
+	if err != nil {
+		logger.Fatal("failed to load holiday datasets", zap.Error(err))
+	}
+	holidayCalendars = calendars
+}
+
+// HolidayRule describes how to compute the date of a holiday in a given year, using exactly one of:
+// a fixed "date" (MM-DD), an "easter" offset in days, the "nth" "weekday" of a "month" (negative counts from the end)
+// or the last "weekday" of a "month" before "before_day".
+type HolidayRule struct {
+	Name      string `json:"name"`
+	Date      string `json:"date,omitempty"`
+	Easter    *int   `json:"easter,omitempty"`
+	Month     int    `json:"month,omitempty"`
+	Weekday   string `json:"weekday,omitempty"`
+	Nth       int    `json:"nth,omitempty"`
+	BeforeDay int    `json:"before_day,omitempty"`
+	// Observed moves holidays falling on a weekend: "nearest" to the Friday or Monday, "monday" to the next free weekday.
+	Observed string `json:"observed,omitempty"`
+	// Regions limits the holiday to some ISO 3166-2 subdivisions, it applies to the whole country when empty.
+	Regions []string `json:"regions,omitempty"`
+	Since   int      `json:"since,omitempty"`
+}
+
+type HolidayCalendar struct {
+	Country  string            `json:"country"`
+	Name     string            `json:"name"`
+	TimeZone string            `json:"time_zone"`
+	Regions  map[string]string `json:"regions"`
+	Rules    []HolidayRule     `json:"holidays,omitempty"`
+}
+
+type Holiday struct {
+	Date    string `json:"date"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+func loadHolidayCalendars() (map[string]HolidayCalendar, error) {
+	files, err := holidayDatasets.ReadDir("holidays")
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := map[string]HolidayCalendar{}
+	for _, file := range files {
+		content, err := holidayDatasets.ReadFile(path.Join("holidays", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		calendar := HolidayCalendar{}
+		if err := json.Unmarshal(content, &calendar); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		if err := calendar.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		calendars[calendar.Country] = calendar
+	}
+
+	return calendars, nil
+}
+
+func (c HolidayCalendar) validate() error {
+	if _, err := time.LoadLocation(c.TimeZone); err != nil || c.TimeZone == "" {
+		return fmt.Errorf("invalid time zone %s", c.TimeZone)
+	}
+
+	for _, rule := range c.Rules {
+		if _, ok := rule.date(2000); !ok {
+			return fmt.Errorf("invalid holiday %s", rule.Name)
+		}
+		if rule.Observed != "" && rule.Observed != "nearest" && rule.Observed != "monday" {
+			return fmt.Errorf("invalid observed %s for holiday %s", rule.Observed, rule.Name)
+		}
+		for _, region := range rule.Regions {
+			if _, ok := c.Regions[region]; !ok {
+				return fmt.Errorf("unknown region %s for holiday %s", region, rule.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// easterSunday computes the date of the western Easter Sunday with the anonymous Gregorian algorithm.
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// date returns the calendar date of the holiday in a year, before moving it off weekends, as midnight UTC.
+func (r HolidayRule) date(year int) (time.Time, bool) {
+	switch {
+	case r.Date != "":
+		date, err := time.Parse(HOLIDAY_DATE_FORMAT, fmt.Sprintf("%04d-%s", year, r.Date))
+		if err != nil {
+			return time.Time{}, false
+		}
+		return date, true
+	case r.Easter != nil:
+		return easterSunday(year).AddDate(0, 0, *r.Easter), true
+	case r.Month >= 1 && r.Month <= 12 && r.Weekday != "":
+		weekday, ok := profileWeekdays[r.Weekday]
+		if !ok {
+			return time.Time{}, false
+		}
+
+		if r.BeforeDay > 0 {
+			date := time.Date(year, time.Month(r.Month), r.BeforeDay-1, 0, 0, 0, 0, time.UTC)
+			for date.Weekday() != weekday {
+				date = date.AddDate(0, 0, -1)
+			}
+			return date, true
+		}
+
+		if r.Nth > 0 && r.Nth <= 5 {
+			date := time.Date(year, time.Month(r.Month), 1, 0, 0, 0, 0, time.UTC)
+			for date.Weekday() != weekday {
+				date = date.AddDate(0, 0, 1)
+			}
+			date = date.AddDate(0, 0, 7*(r.Nth-1))
+			return date, date.Month() == time.Month(r.Month)
+		}
+
+		if r.Nth < 0 && r.Nth >= -5 {
+			date := time.Date(year, time.Month(r.Month)+1, 0, 0, 0, 0, 0, time.UTC)
+			for date.Weekday() != weekday {
+				date = date.AddDate(0, 0, -1)
+			}
+			date = date.AddDate(0, 0, 7*(r.Nth+1))
+			return date, date.Month() == time.Month(r.Month)
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func isWeekend(date time.Time) bool {
+	return date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+}
+
+// observedHolidays computes the days off of a region in a year, which may fall in the previous or next year once
+// moved off weekends. Holidays moved to "monday" skip days already taken by another holiday, like the UK substitutes.
+func (c HolidayCalendar) observedHolidays(region string, year int) []Holiday {
+	type dated struct {
+		rule HolidayRule
+		date time.Time
+	}
+
+	rules := []dated{}
+	taken := map[time.Time]bool{}
+	for _, rule := range c.Rules {
+		if rule.Since > year || (len(rule.Regions) > 0 && !slices.Contains(rule.Regions, region)) {
+			continue
+		}
+		date, ok := rule.date(year)
+		if !ok {
+			continue
+		}
+		rules = append(rules, dated{rule, date})
+		if rule.Observed == "" || !isWeekend(date) {
+			taken[date] = true
+		}
+	}
+
+	holidays := []Holiday{}
+	for _, r := range rules {
+		date := r.date
+		if isWeekend(date) {
+			switch r.rule.Observed {
+			case "nearest":
+				if date.Weekday() == time.Saturday {
+					date = date.AddDate(0, 0, -1)
+				} else {
+					date = date.AddDate(0, 0, 1)
+				}
+			case "monday":
+				for isWeekend(date) || taken[date] {
+					date = date.AddDate(0, 0, 1)
+				}
+				taken[date] = true
+			}
+		}
+
+		holiday := Holiday{Date: date.Format(HOLIDAY_DATE_FORMAT), Name: r.rule.Name, Country: c.Country}
+		if len(r.rule.Regions) > 0 {
+			holiday.Region = region
+		}
+		holidays = append(holidays, holiday)
+	}
+
+	return holidays
+}
+
+// Holidays returns the days off of a region in a year sorted by date, national holidays included.
+func (c HolidayCalendar) Holidays(region string, year int) []Holiday {
+	holidays := []Holiday{}
+	for y := year - 1; y <= year+1; y++ {
+		for _, holiday := range c.observedHolidays(region, y) {
+			if strings.HasPrefix(holiday.Date, strconv.Itoa(year)+"-") {
+				holidays = append(holidays, holiday)
+			}
+		}
+	}
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+
+	return holidays
+}
+
+// holidaysByDate indexes the holidays of a locale between two years by date.
+func holidaysByDate(locale HolidayLocale, fromYear int, toYear int) map[string][]Holiday {
+	calendar, ok := holidayCalendars[locale.Country]
+	if !ok {
+		return map[string][]Holiday{}
+	}
+
+	holidays := map[string][]Holiday{}
+	for year := fromYear; year <= toYear; year++ {
+		for _, holiday := range calendar.Holidays(locale.Region, year) {
+			holidays[holiday.Date] = append(holidays[holiday.Date], holiday)
+		}
+	}
+
+	return holidays
+}
+
+// ValidateHolidayLocale checks the country has a dataset and the region is one of its subdivisions.
+func ValidateHolidayLocale(locale HolidayLocale) error {
+	calendar, ok := holidayCalendars[locale.Country]
+	if !ok {
+		return fmt.Errorf("unsupported country %s", locale.Country)
+	}
+	if _, ok := calendar.Regions[locale.Region]; locale.Region != "" && !ok {
+		return fmt.Errorf("unsupported region %s for country %s", locale.Region, locale.Country)
+	}
+
+	return nil
+}
+
+// FlagHolidayOptions returns, for each option touching a public holiday of any of the locales, the holidays it
+// falls on. Options are converted to the local dates of each country using the time zone of its dataset.
+func FlagHolidayOptions(options []PollOption, locales []HolidayLocale) map[string][]Holiday {
+	flagged := map[string][]Holiday{}
+	if len(options) == 0 {
+		return flagged
+	}
+
+	from, to := optionsWindow(options)
+	for _, locale := range locales {
+		calendar, ok := holidayCalendars[locale.Country]
+		if !ok {
+			continue
+		}
+		location, err := time.LoadLocation(calendar.TimeZone)
+		if err != nil {
+			continue
+		}
+		holidays := holidaysByDate(locale, from.In(location).Year(), to.In(location).Year())
+
+		for _, option := range options {
+			for _, date := range optionDates(option, location) {
+				flagged[option.ID] = append(flagged[option.ID], holidays[date]...)
+			}
+		}
+	}
+
+	for optionID, holidays := range flagged {
+		if len(holidays) == 0 {
+			delete(flagged, optionID)
+		}
+	}
+
+	return flagged
+}
+
+// optionDates returns the local dates an option spans, an option ending at midnight does not touch the next day.
+func optionDates(option PollOption, location *time.Location) []string {
+	start := option.Start.In(location)
+	end := option.End.In(location)
+
+	dates := []string{}
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location); day.Before(end) || len(dates) == 0; day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format(HOLIDAY_DATE_FORMAT))
+	}
+
+	return dates
+}
+
+type GenerateOptionsRequest struct {
+	// From and To are the inclusive range of dates (2006-01-02) to generate options in.
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	TimeZone string   `json:"time_zone"`
+	Days     []string `json:"days"`
+	// Start and End are the local times (15:04) of each option, an End before Start finishes on the next day.
+	Start        string          `json:"start"`
+	End          string          `json:"end"`
+	SkipHolidays bool            `json:"skip_holidays"`
+	Locales      []HolidayLocale `json:"locales"`
+	// WorkspaceID provides the default time zone of the workspace when TimeZone is empty.
+	WorkspaceID int64 `json:"workspace_id"`
+}
+
+func (r GenerateOptionsRequest) Validate() error {
+	if _, err := time.LoadLocation(r.TimeZone); err != nil || r.TimeZone == "" {
+		return fmt.Errorf("invalid time zone %s", r.TimeZone)
+	}
+
+	from, err := time.Parse(HOLIDAY_DATE_FORMAT, r.From)
+	if err != nil {
+		return fmt.Errorf("invalid from %s", r.From)
+	}
+	to, err := time.Parse(HOLIDAY_DATE_FORMAT, r.To)
+	if err != nil {
+		return fmt.Errorf("invalid to %s", r.To)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("to %s must not be before from %s", r.To, r.From)
+	}
+	if to.Sub(from) >= OPTION_GENERATOR_MAX_DAYS*24*time.Hour {
+		return fmt.Errorf("range too long, the maximum is %d days", OPTION_GENERATOR_MAX_DAYS)
+	}
+
+	if len(r.Days) == 0 {
+		return fmt.Errorf("missing days")
+	}
+	for _, day := range r.Days {
+		if _, ok := profileWeekdays[day]; !ok {
+			return fmt.Errorf("invalid day %s", day)
+		}
+	}
+
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("end %s must be different from start %s", r.End, r.Start)
+	}
+
+	for _, locale := range r.Locales {
+		if err := ValidateHolidayLocale(locale); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateOptions creates an option on each selected day of the range, leaving out the ones falling on a public
+// holiday of the locales when SkipHolidays is set. The request must be valid.
+func GenerateOptions(r GenerateOptionsRequest) ([]PollOption, []Holiday, error) {
+	location, _ := time.LoadLocation(r.TimeZone)
+	from, _ := time.Parse(HOLIDAY_DATE_FORMAT, r.From)
+	to, _ := time.Parse(HOLIDAY_DATE_FORMAT, r.To)
+	start, _ := parseClock(r.Start)
+	end, _ := parseClock(r.End)
+	if end < start {
+		end += 24 * 60
+	}
+
+	options := []PollOption{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !slices.ContainsFunc(r.Days, func(d string) bool { return profileWeekdays[d] == day.Weekday() }) {
+			continue
+		}
+		options = append(options, PollOption{
+			ID:    day.Format(HOLIDAY_DATE_FORMAT),
+			Start: time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, location).UTC(),
+			End:   time.Date(day.Year(), day.Month(), day.Day(), end/60, end%60, 0, 0, location).UTC(),
+		})
+	}
+
+	skipped := []Holiday{}
+	if r.SkipHolidays {
+		flagged := FlagHolidayOptions(options, r.Locales)
+		options = slices.DeleteFunc(options, func(option PollOption) bool {
+			skipped = append(skipped, flagged[option.ID]...)
+			return len(flagged[option.ID]) > 0
+		})
+	}
+
+	if len(options) > OPTION_GENERATOR_MAX_OPTIONS {
+		return nil, nil, fmt.Errorf("too many options, the maximum is %d", OPTION_GENERATOR_MAX_OPTIONS)
+	}
+
+	// Options get their ids when the poll is created.
+	for i := range options {
+		options[i].ID = ""
+	}
+
+	return options, skipped, nil
+}
+
+func (a *APIServer) listHolidayCalendars(ctx *gin.Context) {
+	calendars := []HolidayCalendar{}
+	for _, calendar := range holidayCalendars {
+		calendar.Rules = nil
+		calendars = append(calendars, calendar)
+	}
+	sort.Slice(calendars, func(i, j int) bool { return calendars[i].Country < calendars[j].Country })
+
+	ctx.JSON(http.StatusOK, gin.H{"data": calendars})
+}
+
+func (a *APIServer) listHolidays(ctx *gin.Context) {
+	locale := HolidayLocale{Country: strings.ToUpper(ctx.Params.ByName("country")), Region: ctx.Query("region")}
+	if err := ValidateHolidayLocale(locale); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	year := time.Now().Year()
+	if value := ctx.Query("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1900 || parsed > 2200 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter year"})
+			return
+		}
+		year = parsed
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": holidayCalendars[locale.Country].Holidays(locale.Region, year)})
+}
+
+func (a *APIServer) getHolidayLocale(ctx *gin.Context, accountID int64) {
+	locale, err := GetAccountHolidayLocale(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": locale})
+}
+
+// setHolidayLocale configures the country of the account, an empty country stops flagging its holidays.
+func (a *APIServer) setHolidayLocale(ctx *gin.Context, accountID int64) {
+	locale := HolidayLocale{}
+	err := readBody(ctx, &locale)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if locale.Country != "" {
+		if err := ValidateHolidayLocale(locale); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		locale.Region = ""
+	}
+
+	err = SetAccountHolidayLocale(ctx, a.db, accountID, locale)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": locale})
+}
+
+// generateOptions suggests poll options, skipping holidays of the account's country unless locales are given.
+func (a *APIServer) generateOptions(ctx *gin.Context, accountID int64) {
+	request := GenerateOptionsRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if request.TimeZone == "" && request.WorkspaceID != 0 {
+		workspace, err := GetWorkspace(ctx, a.db, request.WorkspaceID, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if workspace.Role != "" {
+			request.TimeZone = workspace.Settings.TimeZone
+		}
+	}
+
+	if request.Locales == nil {
+		locale, err := GetAccountHolidayLocale(ctx, a.db, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if locale.Country != "" {
+			request.Locales = []HolidayLocale{locale}
+		}
+	}
+
+	if err := request.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, skipped, err := GenerateOptions(request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"options": options,
+		"skipped": skipped,
+	}})
+}
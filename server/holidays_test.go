@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	testCases := map[int]string{
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	}
+
+	for year, expected := range testCases {
+		if date := easterSunday(year).Format(HOLIDAY_DATE_FORMAT); date != expected {
+			t.Errorf("Expected Easter %d on %s, but got %s", year, expected, date)
+		}
+	}
+}
+
+func TestHolidays(t *testing.T) {
+	holidayOn := func(locale HolidayLocale, date string) string {
+		year, _ := time.Parse(HOLIDAY_DATE_FORMAT, date)
+		for _, holiday := range holidayCalendars[locale.Country].Holidays(locale.Region, year.Year()) {
+			if holiday.Date == date {
+				return holiday.Name
+			}
+		}
+		return ""
+	}
+
+	testCases := []struct {
+		locale   HolidayLocale
+		date     string
+		expected string
+	}{
+		{HolidayLocale{Country: "PT"}, "2024-05-30", "Corpo de Deus"},
+		{HolidayLocale{Country: "PT"}, "2024-06-13", ""},
+		{HolidayLocale{Country: "PT", Region: "PT-11"}, "2024-06-13", "Santo António"},
+		{HolidayLocale{Country: "DE", Region: "DE-SN"}, "2024-11-20", "Buß- und Bettag"},
+		{HolidayLocale{Country: "DE", Region: "DE-BY"}, "2024-11-20", ""},
+		{HolidayLocale{Country: "US"}, "2024-11-28", "Thanksgiving Day"},
+		{HolidayLocale{Country: "US"}, "2024-05-27", "Memorial Day"},
+		// Independence Day on a Sunday is observed on Monday.
+		{HolidayLocale{Country: "US"}, "2021-07-05", "Independence Day"},
+		// New Year's Day 2022 on a Saturday is observed in the previous year.
+		{HolidayLocale{Country: "US"}, "2021-12-31", "New Year's Day"},
+		// Christmas on a Sunday yields the Tuesday as Boxing Day already takes the Monday.
+		{HolidayLocale{Country: "GB", Region: "GB-ENG"}, "2022-12-26", "Boxing Day"},
+		{HolidayLocale{Country: "GB", Region: "GB-ENG"}, "2022-12-27", "Christmas Day"},
+		// Christmas on a Saturday and Boxing Day on a Sunday move to Monday and Tuesday.
+		{HolidayLocale{Country: "GB", Region: "GB-SCT"}, "2021-12-27", "Christmas Day"},
+		{HolidayLocale{Country: "GB", Region: "GB-SCT"}, "2021-12-28", "Boxing Day"},
+		{HolidayLocale{Country: "GB", Region: "GB-SCT"}, "2024-08-05", "Summer bank holiday"},
+		{HolidayLocale{Country: "GB", Region: "GB-ENG"}, "2024-08-26", "Summer bank holiday"},
+	}
+
+	for _, testCase := range testCases {
+		if name := holidayOn(testCase.locale, testCase.date); name != testCase.expected {
+			t.Errorf("Expected %q on %s in %+v, but got %q", testCase.expected, testCase.date, testCase.locale, name)
+		}
+	}
+}
+
+func TestFlagHolidayOptions(t *testing.T) {
+	option := func(id string, start string, duration time.Duration) PollOption {
+		s, _ := time.Parse(time.RFC3339, start)
+		return PollOption{ID: id, Start: s, End: s.Add(duration)}
+	}
+	options := []PollOption{
+		// 25 April in Lisbon.
+		option("liberdade", "2024-04-25T09:00:00Z", time.Hour),
+		// 23:30 on 24 April in UTC but already 25 April in Lisbon (summer time).
+		option("late", "2024-04-24T23:30:00Z", time.Hour),
+		// Ends at midnight in Lisbon before Labour Day, but is already on Labour Day in Berlin.
+		option("eve", "2024-04-30T22:00:00Z", time.Hour),
+		option("labour", "2024-05-01T09:00:00Z", time.Hour),
+		option("regular", "2024-05-02T09:00:00Z", time.Hour),
+	}
+
+	flagged := FlagHolidayOptions(options, []HolidayLocale{{Country: "PT"}, {Country: "DE", Region: "DE-BE"}})
+	expected := map[string][]Holiday{
+		"liberdade": {{Date: "2024-04-25", Name: "Dia da Liberdade", Country: "PT"}},
+		"late":      {{Date: "2024-04-25", Name: "Dia da Liberdade", Country: "PT"}},
+		"eve":       {{Date: "2024-05-01", Name: "Tag der Arbeit", Country: "DE"}},
+		"labour": {
+			{Date: "2024-05-01", Name: "Dia do Trabalhador", Country: "PT"},
+			{Date: "2024-05-01", Name: "Tag der Arbeit", Country: "DE"},
+		},
+	}
+	if !reflect.DeepEqual(flagged, expected) {
+		t.Errorf("Expected %v, but got %v", expected, flagged)
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	request := GenerateOptionsRequest{
+		From:         "2024-04-22",
+		To:           "2024-05-03",
+		TimeZone:     "Europe/Lisbon",
+		Days:         []string{"tue", "thu"},
+		Start:        "10:00",
+		End:          "11:30",
+		SkipHolidays: true,
+		Locales:      []HolidayLocale{{Country: "PT"}},
+	}
+	if err := request.Validate(); err != nil {
+		t.Fatalf("Expected request to be valid, but got %s", err)
+	}
+
+	options, skipped, err := GenerateOptions(request)
+	if err != nil {
+		t.Fatalf("Expected options, but got %s", err)
+	}
+
+	starts := []string{}
+	for _, option := range options {
+		starts = append(starts, option.Start.Format(time.RFC3339))
+		if option.End.Sub(option.Start) != 90*time.Minute {
+			t.Errorf("Expected options to last 90 minutes, but got %s", option.End.Sub(option.Start))
+		}
+	}
+	expected := []string{"2024-04-23T09:00:00Z", "2024-04-30T09:00:00Z", "2024-05-02T09:00:00Z"}
+	if !reflect.DeepEqual(starts, expected) {
+		t.Errorf("Expected %v, but got %v", expected, starts)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "Dia da Liberdade" {
+		t.Errorf("Expected Dia da Liberdade to be skipped, but got %v", skipped)
+	}
+
+	invalid := []GenerateOptionsRequest{
+		{From: "2024-05-03", To: "2024-04-22", TimeZone: "UTC", Days: []string{"mon"}, Start: "10:00", End: "11:00"},
+		{From: "2024-01-01", To: "2025-06-01", TimeZone: "UTC", Days: []string{"mon"}, Start: "10:00", End: "11:00"},
+		{From: "2024-04-22", To: "2024-05-03", TimeZone: "UTC", Days: []string{"mon"}, Start: "10:00", End: "10:00"},
+		{From: "2024-04-22", To: "2024-05-03", TimeZone: "UTC", Days: []string{"mon"}, Start: "10:00", End: "11:00", Locales: []HolidayLocale{{Country: "XX"}}},
+		{From: "2024-04-22", To: "2024-05-03", TimeZone: "UTC", Days: []string{"mon"}, Start: "10:00", End: "11:00", Locales: []HolidayLocale{{Country: "PT", Region: "DE-BY"}}},
+	}
+	for _, request := range invalid {
+		if err := request.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", request)
+		}
+	}
+}
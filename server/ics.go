@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ICAL_PRODID     = "-//roodle//roodle//EN"
+	ICAL_LINE_LIMIT = 75
+	ICAL_UTC_FORMAT = "20060102T150405Z"
+)
+
+type ICalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type ICalComponent struct {
+	Name       string
+	Properties []ICalProperty
+	Components []*ICalComponent
+}
+
+func (c *ICalComponent) Prop(name string) (ICalProperty, bool) {
+	for _, property := range c.Properties {
+		if property.Name == name {
+			return property, true
+		}
+	}
+
+	return ICalProperty{}, false
+}
+
+func (c *ICalComponent) Value(name string) string {
+	property, _ := c.Prop(name)
+	return property.Value
+}
+
+func (c *ICalComponent) Props(name string) []ICalProperty {
+	properties := []ICalProperty{}
+	for _, property := range c.Properties {
+		if property.Name == name {
+			properties = append(properties, property)
+		}
+	}
+
+	return properties
+}
+
+func (c *ICalComponent) Children(name string) []*ICalComponent {
+	components := []*ICalComponent{}
+	for _, component := range c.Components {
+		if component.Name == name {
+			components = append(components, component)
+		}
+	}
+
+	return components
+}
+
+// ParseICal parses an iCalendar (RFC 5545) stream and returns its outermost component, usually a VCALENDAR.
+func ParseICal(r io.Reader) (*ICalComponent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *ICalComponent
+	stack := []*ICalComponent{}
+	for _, line := range lines {
+		property, err := parseICalLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch property.Name {
+		case "BEGIN":
+			component := &ICalComponent{Name: strings.ToUpper(property.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			} else if root == nil {
+				root = component
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return nil, fmt.Errorf("unexpected END:%s", property.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("property %s outside of a component", property.Name)
+			}
+			component := stack[len(stack)-1]
+			component.Properties = append(component.Properties, property)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no calendar found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+
+	return root, nil
+}
+
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lines := []string{}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseICalLine(line string) (ICalProperty, error) {
+	property := ICalProperty{Params: map[string]string{}}
+
+	// The value starts at the first colon that is not inside a quoted parameter value.
+	inQuotes := false
+	valueStart := -1
+	for idx, char := range line {
+		if char == '"' {
+			inQuotes = !inQuotes
+		} else if char == ':' && !inQuotes {
+			valueStart = idx
+			break
+		}
+	}
+	if valueStart == -1 {
+		return ICalProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	property.Value = line[valueStart+1:]
+
+	parts := splitICalParams(line[:valueStart])
+	property.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+		property.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return property, nil
+}
+
+func splitICalParams(value string) []string {
+	parts := []string{}
+	inQuotes := false
+	start := 0
+	for idx, char := range value {
+		if char == '"' {
+			inQuotes = !inQuotes
+		} else if char == ';' && !inQuotes {
+			parts = append(parts, value[start:idx])
+			start = idx + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+func icalUnescape(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+func icalEscape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format(ICAL_UTC_FORMAT)
+}
+
+// pollEventUID is the UID of the calendar event of a poll, replies are matched back to the poll through it.
+func pollEventUID(pollID string) string {
+	return pollID + "@roodle"
+}
+
+func pollIDFromEventUID(uid string) (string, bool) {
+	pollID, found := strings.CutSuffix(uid, "@roodle")
+	return pollID, found && pollID != ""
+}
+
+// ICalWriter writes iCalendar content lines, folding them at 75 octets.
+type ICalWriter struct {
+	builder strings.Builder
+}
+
+func (w *ICalWriter) Line(name string, params map[string]string, value string) {
+	line := name
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		param := params[key]
+		if strings.ContainsAny(param, ":;,") {
+			param = `"` + param + `"`
+		}
+		line += ";" + key + "=" + param
+	}
+	line += ":" + value
+
+	for len(line) > ICAL_LINE_LIMIT {
+		cut := ICAL_LINE_LIMIT
+		// Do not split multi-byte characters.
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.builder.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	w.builder.WriteString(line + "\r\n")
+}
+
+func (w *ICalWriter) Prop(name string, value string) {
+	w.Line(name, nil, value)
+}
+
+func (w *ICalWriter) String() string {
+	return w.builder.String()
+}
+
+// FinalizedPollICS is the calendar invite of the option chosen for a poll. The replies are sent to the reply address,
+// the reply address of an invite records them as RSVPs, otherwise they go to the organizer.
+func FinalizedPollICS(poll Poll, organizer Account, replyAddress string, attendees []string, now time.Time) (string, bool) {
+	option, ok := poll.FinalOption()
+	if !ok {
+		return "", false
+	}
+
+	organizerAddress := replyAddress
+	if organizerAddress == "" {
+		organizerAddress = organizer.Email
+	}
+
+	w := &ICalWriter{}
+	w.Prop("BEGIN", "VCALENDAR")
+	w.Prop("VERSION", "2.0")
+	w.Prop("PRODID", ICAL_PRODID)
+	w.Prop("METHOD", "REQUEST")
+	w.Prop("BEGIN", "VEVENT")
+	w.Prop("UID", pollEventUID(poll.ID))
+	w.Prop("DTSTAMP", icalTime(now))
+	w.Prop("DTSTART", icalTime(option.Start))
+	w.Prop("DTEND", icalTime(option.End))
+	w.Prop("SUMMARY", icalEscape(poll.Title))
+	if poll.Description != "" {
+		w.Prop("DESCRIPTION", icalEscape(poll.Description))
+	}
+	if poll.Location != "" {
+		w.Prop("LOCATION", icalEscape(poll.Location))
+	}
+	w.Prop("URL", pollURL(poll.ID))
+	w.Prop("STATUS", "CONFIRMED")
+	w.Line("ORGANIZER", map[string]string{"CN": organizer.Name}, "mailto:"+organizerAddress)
+	for _, attendee := range attendees {
+		w.Line("ATTENDEE", map[string]string{"PARTSTAT": "NEEDS-ACTION", "RSVP": "TRUE", "ROLE": "REQ-PARTICIPANT"}, "mailto:"+attendee)
+	}
+	w.Prop("END", "VEVENT")
+	w.Prop("END", "VCALENDAR")
+
+	return w.String(), true
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+type ImportFormat = string
+
+const (
+	// ImportDoodle is the CSV export of Doodle: the title, then rows with the months, the days and optionally the times of the options.
+	ImportDoodle ImportFormat = "doodle"
+	// ImportFramadate is the CSV export of Framadate: rows with the dates and the times of the options, without the title.
+	ImportFramadate ImportFormat = "framadate"
+)
+
+const (
+	IMPORT_MAX_SIZE               = 5 << 20
+	IMPORT_DEFAULT_TITLE          = "Imported poll"
+	IMPORT_DEFAULT_OPTION_MINUTES = 60
+)
+
+var (
+	ErrImportTooLarge = errors.New("import file is too large")
+
+	importTimeRangeSeparator = regexp.MustCompile(`\s*[–—-]\s*`)
+	importClockLayouts       = []string{"15:04", "3:04pm", "3:04 pm", "3pm", "3 pm", "15"}
+	importDateLayouts        = []string{"2006-01-02", "Monday 2 January 2006", "Mon 2 January 2006", "Monday, January 2, 2006", "January 2, 2006", "02/01/2006"}
+	// importAnswers maps the answers of the exports, Doodle marks "if need be" answers with parentheses, Framadate translates them.
+	importAnswers = map[string]OptionAnswer{
+		"ok": Available, "yes": Available, "oui": Available, "(ok)": Maybe, "ifneedbe": Maybe, "if need be": Maybe, "si nécessaire": Maybe,
+		"": Unavailable, "no": Unavailable, "non": Unavailable,
+	}
+)
+
+// ImportedVote holds the answers of a participant of an imported poll, in the order of the poll options.
+type ImportedVote struct {
+	Name    string
+	Answers []OptionAnswer
+}
+
+type ImportedPoll struct {
+	PollBase
+	Votes []ImportedVote
+}
+
+// ImportSummary tells how the participants of an imported poll were matched to accounts, guests are offline votes.
+type ImportSummary struct {
+	Poll   Poll `json:"poll"`
+	Voters int  `json:"voters"`
+	Guests int  `json:"guests"`
+}
+
+// ParsePollImport reads a poll export, the option times without a time zone are in the location.
+func ParsePollImport(format ImportFormat, data []byte, location *time.Location) (ImportedPoll, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return ImportedPoll{}, fmt.Errorf("invalid csv: %w", err)
+	}
+	for idx := range records {
+		for col := range records[idx] {
+			records[idx][col] = strings.TrimSpace(records[idx][col])
+		}
+	}
+
+	var imported ImportedPoll
+	switch format {
+	case ImportDoodle:
+		imported, err = parseDoodleImport(records, location)
+	case ImportFramadate:
+		imported, err = parseFramadateImport(records, location)
+	default:
+		return ImportedPoll{}, fmt.Errorf("invalid format %s, use %s or %s", format, ImportDoodle, ImportFramadate)
+	}
+	if err != nil {
+		return ImportedPoll{}, err
+	}
+	if len(imported.Options) == 0 {
+		return ImportedPoll{}, fmt.Errorf("no options found")
+	}
+
+	seen := map[string]int{}
+	for idx, vote := range imported.Votes {
+		key := strings.ToLower(vote.Name)
+		seen[key]++
+		if seen[key] > 1 {
+			imported.Votes[idx].Name = fmt.Sprintf("%s (%d)", vote.Name, seen[key])
+		}
+	}
+
+	return imported, nil
+}
+
+func parseDoodleImport(records [][]string, location *time.Location) (ImportedPoll, error) {
+	imported := ImportedPoll{}
+
+	headers := [][]string{}
+	participants := [][]string{}
+	columns := []int{}
+	for _, record := range records {
+		if len(record) == 0 || isBlankRecord(record) {
+			continue
+		}
+		name := record[0]
+		switch {
+		case name == "" && len(headers) < 3:
+			headers = append(headers, record)
+		case len(headers) == 0:
+			// Lines before the options hold the title and the link of the poll.
+			if imported.Title == "" {
+				imported.Title = strings.TrimSuffix(strings.TrimPrefix(name, `Poll "`), `"`)
+			}
+		case strings.EqualFold(name, "count"):
+		default:
+			participants = append(participants, record)
+		}
+	}
+	if len(headers) < 2 {
+		return ImportedPoll{}, fmt.Errorf("missing the month and day rows")
+	}
+
+	months, days := headers[0], headers[1]
+	times := []string{}
+	if len(headers) > 2 {
+		times = headers[2]
+	}
+
+	var month time.Time
+	for col := 1; col < len(days); col++ {
+		if value := cell(months, col); value != "" {
+			parsed, err := time.Parse("January 2006", value)
+			if err != nil {
+				return ImportedPoll{}, fmt.Errorf("invalid month %q", value)
+			}
+			month = parsed
+		}
+		if month.IsZero() {
+			return ImportedPoll{}, fmt.Errorf("missing month for column %d", col+1)
+		}
+
+		fields := strings.Fields(days[col])
+		if len(fields) == 0 {
+			continue
+		}
+		day, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return ImportedPoll{}, fmt.Errorf("invalid day %q", days[col])
+		}
+
+		option, err := importOption(time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, location), cell(times, col))
+		if err != nil {
+			return ImportedPoll{}, err
+		}
+		imported.Options = append(imported.Options, option)
+		columns = append(columns, col)
+	}
+
+	imported.Votes = importVoteRows(participants, columns)
+	return imported, nil
+}
+
+func parseFramadateImport(records [][]string, location *time.Location) (ImportedPoll, error) {
+	imported := ImportedPoll{}
+
+	nonBlank := [][]string{}
+	for _, record := range records {
+		if !isBlankRecord(record) {
+			nonBlank = append(nonBlank, record)
+		}
+	}
+	if len(nonBlank) < 2 || nonBlank[0][0] != "" || nonBlank[1][0] != "" {
+		return ImportedPoll{}, fmt.Errorf("missing the date and time rows")
+	}
+
+	dates, moments := nonBlank[0], nonBlank[1]
+	columns := []int{}
+	for col := 1; col < len(dates); col++ {
+		if dates[col] == "" {
+			continue
+		}
+		date, err := parseImportDate(dates[col], location)
+		if err != nil {
+			return ImportedPoll{}, err
+		}
+
+		option, err := importOption(date, cell(moments, col))
+		if err != nil {
+			return ImportedPoll{}, err
+		}
+		imported.Options = append(imported.Options, option)
+		columns = append(columns, col)
+	}
+
+	participants := [][]string{}
+	for _, record := range nonBlank[2:] {
+		if record[0] != "" {
+			participants = append(participants, record)
+		}
+	}
+
+	imported.Votes = importVoteRows(participants, columns)
+	return imported, nil
+}
+
+// importVoteRows reads the participant rows, the name first and then the answers in the columns of the options.
+// Unknown answers are left unanswered.
+func importVoteRows(records [][]string, columns []int) []ImportedVote {
+	votes := []ImportedVote{}
+	for _, record := range records {
+		vote := ImportedVote{Name: record[0]}
+		for _, col := range columns {
+			vote.Answers = append(vote.Answers, importAnswers[strings.ToLower(cell(record, col))])
+		}
+		votes = append(votes, vote)
+	}
+
+	return votes
+}
+
+// importOption creates the option on the date, the moment is a time range, a single time or empty for the whole day.
+// Ranges ending before they start end on the next day.
+func importOption(date time.Time, moment string) (PollOption, error) {
+	if moment == "" {
+		return PollOption{Start: date, End: date.AddDate(0, 0, 1)}, nil
+	}
+
+	parts := importTimeRangeSeparator.Split(moment, 2)
+	start, err := parseImportClock(date, parts[0])
+	if err != nil {
+		return PollOption{}, err
+	}
+	if len(parts) == 1 {
+		return PollOption{Start: start, End: start.Add(IMPORT_DEFAULT_OPTION_MINUTES * time.Minute)}, nil
+	}
+
+	end, err := parseImportClock(date, parts[1])
+	if err != nil {
+		return PollOption{}, err
+	}
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return PollOption{Start: start, End: end}, nil
+}
+
+// parseImportClock reads times like "13:30", "1:30 PM", "1pm" or "13h30" on the date.
+func parseImportClock(date time.Time, value string) (time.Time, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if strings.HasSuffix(normalized, "h") {
+		normalized += "00"
+	}
+	normalized = strings.Replace(normalized, "h", ":", 1)
+
+	for _, layout := range importClockLayouts {
+		clock, err := time.Parse(layout, normalized)
+		if err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location()), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func parseImportDate(value string, location *time.Location) (time.Time, error) {
+	normalized := strings.Join(strings.Fields(value), " ")
+	for _, layout := range importDateLayouts {
+		date, err := time.ParseInLocation(layout, normalized, location)
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func cell(record []string, col int) string {
+	if col < len(record) {
+		return record[col]
+	}
+
+	return ""
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if value != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// importParticipantEmail returns the email of participants named by an address, like "ana@example.com" or "Ana <ana@example.com>".
+func importParticipantEmail(name string) string {
+	address, err := mail.ParseAddress(name)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(address.Address)
+}
+
+// ImportPoll creates the imported poll for the account, the participants are recorded as offline votes by the account.
+// Participants are only matched to the accounts by email when matchAccounts is set, as the votes then speak for
+// those accounts: only the operators running migrations can vouch for the export. The poll and its votes are
+// created together, or not at all.
+func ImportPoll(ctx context.Context, db *sql.DB, accountID int64, imported ImportedPoll, matchAccounts bool) (ImportSummary, error) {
+	voterIDs := make([]int64, len(imported.Votes))
+	if matchAccounts {
+		for idx, vote := range imported.Votes {
+			email := importParticipantEmail(vote.Name)
+			if email == "" {
+				continue
+			}
+			voterID, err := GetAccount(ctx, db, email)
+			if err != nil {
+				return ImportSummary{}, err
+			}
+			if voterID != -1 {
+				voterIDs[idx] = voterID
+			}
+		}
+	}
+
+	poll := Poll{PollBase: imported.PollBase}
+	for idx := range poll.Options {
+		poll.Options[idx].ID = randomAlphanumeric(12)
+	}
+	votes, summary := importedVotes(poll, imported.Votes, voterIDs)
+
+	poll, err := NewImportedPoll(ctx, db, accountID, poll, votes)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	summary.Poll = poll
+
+	return summary, nil
+}
+
+// importedVotes answers the options of the poll with the imported votes, the votes of the voter accounts are theirs
+// and the others are offline votes. The same account in several rows keeps the first answers, the next rows become guests.
+func importedVotes(poll Poll, imported []ImportedVote, voterIDs []int64) ([]PollAccountAvailability, ImportSummary) {
+	votes := []PollAccountAvailability{}
+	summary := ImportSummary{}
+	voters := map[int64]bool{}
+	for idx, vote := range imported {
+		availabilities := []OptionAvailability{}
+		for option, answer := range vote.Answers {
+			if answer != "" {
+				availabilities = append(availabilities, OptionAvailability{OptionID: poll.Options[option].ID, Answer: answer})
+			}
+		}
+
+		if voterID := voterIDs[idx]; voterID != 0 && !voters[voterID] {
+			votes = append(votes, PollAccountAvailability{AccountID: voterID, Availabilities: availabilities})
+			voters[voterID] = true
+			summary.Voters++
+			continue
+		}
+
+		votes = append(votes, PollAccountAvailability{ParticipantName: vote.Name, Availabilities: availabilities})
+		summary.Guests++
+	}
+
+	return votes, summary
+}
+
+// importPoll creates a poll from a Doodle or Framadate export uploaded as the multipart file field.
+// The "format" parameter picks the export, "timezone" the time zone of the option times and "title" overrides the title.
+// Anyone can upload an export, so every participant becomes a guest named as in the export, emails included.
+func (a *APIServer) importPoll(ctx *gin.Context, accountID int64) {
+	location := time.UTC
+	if timezone := ctx.Query("timezone"); timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter timezone"})
+			return
+		}
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing import file"})
+		return
+	}
+	if file.Size > IMPORT_MAX_SIZE {
+		ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrImportTooLarge.Error()})
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid import file"})
+		return
+	}
+	defer reader.Close()
+	data, err := readLimited(reader, IMPORT_MAX_SIZE)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid import file"})
+		return
+	}
+
+	imported, err := ParsePollImport(ctx.Query("format"), data, location)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	imported.Title = importTitle(ctx.Query("title"), imported.Title)
+
+	if !a.checkPollQuota(ctx, accountID, 0) {
+		return
+	}
+
+	summary, err := ImportPoll(ctx, a.db, accountID, imported, false)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+func importTitle(title string, parsed string) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	if parsed != "" {
+		return parsed
+	}
+
+	return IMPORT_DEFAULT_TITLE
+}
+
+// RunImportCommand imports the export files given as arguments for the owner account, for migrations run from the command line.
+// The participants named by the email of an account vote as that account:
+//
+//	server import -format doodle -owner ana@example.com -timezone Europe/Lisbon lunch.csv dinner.csv
+func RunImportCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", ImportDoodle, "export format, doodle or framadate")
+	owner := flags.String("owner", "", "email of the account owning the imported polls")
+	timezone := flags.String("timezone", "UTC", "time zone of the option times")
+	title := flags.String("title", "", "title of the imported polls, instead of the one in the export")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *owner == "" || flags.NArg() == 0 {
+		return errors.New("usage: import -owner email [-format doodle|framadate] [-timezone zone] [-title title] file...")
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %s", *timezone)
+	}
+
+	db, err := NewDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ownerID, err := GetAccount(ctx, db, strings.ToLower(*owner))
+	if err != nil {
+		return err
+	}
+	if ownerID == -1 {
+		return fmt.Errorf("no account found for %s", *owner)
+	}
+
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		imported, err := ParsePollImport(*format, data, location)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		imported.Title = importTitle(*title, imported.Title)
+
+		summary, err := ImportPoll(ctx, db, ownerID, imported, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		logger.Info("imported poll", zap.String("file", path), zap.String("pollID", summary.Poll.ID),
+			zap.Int("voters", summary.Voters), zap.Int("guests", summary.Guests))
+	}
+
+	return nil
+}
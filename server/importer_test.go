@@ -0,0 +1,135 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDoodleImport(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	data := "\xef\xbb\xbf" + `"Poll ""Team lunch""",
+"https://doodle.com/poll/abc",
+,
+,"July 2024",,"August 2024"
+,"Mon 1","Tue 2","Thu 1"
+,"12:00 PM – 1:00 PM","11:30 PM – 12:30 AM","9:00 AM"
+"Ana <ana@example.com>","OK","(OK)",""
+"Grandma","","OK","OK"
+"grandma","OK",,
+"Count","2","1","1"
+`
+
+	imported, err := ParsePollImport(ImportDoodle, []byte(data), lisbon)
+	if err != nil {
+		t.Fatalf("Expected the export to be parsed, but got %v", err)
+	}
+
+	if imported.Title != "Team lunch" {
+		t.Errorf("Expected the title Team lunch, but got %q", imported.Title)
+	}
+	expectedOptions := []PollOption{
+		{Start: time.Date(2024, 7, 1, 12, 0, 0, 0, lisbon), End: time.Date(2024, 7, 1, 13, 0, 0, 0, lisbon)},
+		{Start: time.Date(2024, 7, 2, 23, 30, 0, 0, lisbon), End: time.Date(2024, 7, 3, 0, 30, 0, 0, lisbon)},
+		{Start: time.Date(2024, 8, 1, 9, 0, 0, 0, lisbon), End: time.Date(2024, 8, 1, 10, 0, 0, 0, lisbon)},
+	}
+	if !reflect.DeepEqual(imported.Options, expectedOptions) {
+		t.Errorf("Expected options %v, but got %v", expectedOptions, imported.Options)
+	}
+	expectedVotes := []ImportedVote{
+		{Name: "Ana <ana@example.com>", Answers: []OptionAnswer{Available, Maybe, Unavailable}},
+		{Name: "Grandma", Answers: []OptionAnswer{Unavailable, Available, Available}},
+		{Name: "grandma (2)", Answers: []OptionAnswer{Available, Unavailable, Unavailable}},
+	}
+	if !reflect.DeepEqual(imported.Votes, expectedVotes) {
+		t.Errorf("Expected votes %v, but got %v", expectedVotes, imported.Votes)
+	}
+}
+
+func TestParseFramadateImport(t *testing.T) {
+	data := `,"Monday  1 July 2024","Monday  1 July 2024","2024-07-02",
+,"10h-12h","14:00","",
+"ana@example.com","Yes","Ifneedbe","No",
+"Bruno","Non","Oui","Si nécessaire",
+`
+
+	imported, err := ParsePollImport(ImportFramadate, []byte(data), time.UTC)
+	if err != nil {
+		t.Fatalf("Expected the export to be parsed, but got %v", err)
+	}
+
+	expectedOptions := []PollOption{
+		{Start: time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC), End: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 7, 1, 14, 0, 0, 0, time.UTC), End: time.Date(2024, 7, 1, 15, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(imported.Options, expectedOptions) {
+		t.Errorf("Expected options %v, but got %v", expectedOptions, imported.Options)
+	}
+	expectedVotes := []ImportedVote{
+		{Name: "ana@example.com", Answers: []OptionAnswer{Available, Maybe, Unavailable}},
+		{Name: "Bruno", Answers: []OptionAnswer{Unavailable, Available, Maybe}},
+	}
+	if !reflect.DeepEqual(imported.Votes, expectedVotes) {
+		t.Errorf("Expected votes %v, but got %v", expectedVotes, imported.Votes)
+	}
+}
+
+func TestParsePollImportInvalid(t *testing.T) {
+	testCases := []struct {
+		format ImportFormat
+		data   string
+	}{
+		{"when2meet", ",\"July 2024\"\n,\"Mon 1\"\n"},
+		{ImportDoodle, "\"Poll\"\n\"Ana\",\"OK\"\n"},
+		{ImportDoodle, ",\"Juillet 2024\"\n,\"Mon 1\"\n"},
+		{ImportDoodle, ",\"July 2024\"\n,\"Mon 1\"\n,\"noon\"\n"},
+		{ImportFramadate, ",\"1er juillet 2024\"\n,\"\"\n"},
+		{ImportFramadate, ",\n,\n"},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := ParsePollImport(testCase.format, []byte(testCase.data), time.UTC); err == nil {
+			t.Errorf("Expected the %s export %q to be invalid", testCase.format, testCase.data)
+		}
+	}
+}
+
+func TestImportParticipantEmail(t *testing.T) {
+	testCases := map[string]string{
+		"Ana@Example.com":       "ana@example.com",
+		"Ana <ana@example.com>": "ana@example.com",
+		"Grandma":               "",
+		"ana at example":        "",
+	}
+
+	for name, expected := range testCases {
+		if email := importParticipantEmail(name); email != expected {
+			t.Errorf("Expected %q for %q, but got %q", expected, name, email)
+		}
+	}
+}
+
+func TestImportedVotes(t *testing.T) {
+	poll := Poll{PollBase: PollBase{Options: []PollOption{{ID: "o1"}, {ID: "o2"}}}}
+	imported := []ImportedVote{
+		{Name: "ana@example.com", Answers: []OptionAnswer{Available, ""}},
+		{Name: "Grandma", Answers: []OptionAnswer{Maybe, Unavailable}},
+		{Name: "Ana <ana@example.com>", Answers: []OptionAnswer{Unavailable, Available}},
+		{Name: "rui@example.com", Answers: []OptionAnswer{"", Maybe}},
+	}
+
+	votes, summary := importedVotes(poll, imported, []int64{7, 0, 7, 0})
+	expected := []PollAccountAvailability{
+		{AccountID: 7, Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}}},
+		{ParticipantName: "Grandma", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Maybe}, {OptionID: "o2", Answer: Unavailable}}},
+		{ParticipantName: "Ana <ana@example.com>", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Unavailable}, {OptionID: "o2", Answer: Available}}},
+		{ParticipantName: "rui@example.com", Availabilities: []OptionAvailability{{OptionID: "o2", Answer: Maybe}}},
+	}
+	if !reflect.DeepEqual(votes, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, votes)
+	}
+	if summary.Voters != 1 || summary.Guests != 3 {
+		t.Errorf("Expected 1 voter and 3 guests, but got %+v", summary)
+	}
+}
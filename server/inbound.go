@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	INBOUND_MAX_MESSAGE_SIZE = 10 << 20
+)
+
+var (
+	inboundEmailDomain string
+
+	ErrUnknownRecipient = errors.New("unknown recipient")
+)
+
+// inviteReplyAddress is the reply-to address of the emails sent to an invitee,
+// replies to it are recorded as votes of the invitee.
+func inviteReplyAddress(invite PollInvite) string {
+	if inboundEmailDomain == "" {
+		return ""
+	}
+
+	return "reply+" + invite.Token + "@" + inboundEmailDomain
+}
+
+func inviteTokenFromAddress(address string) (string, bool) {
+	localPart, domain, found := strings.Cut(address, "@")
+	if !found || !strings.EqualFold(domain, inboundEmailDomain) {
+		return "", false
+	}
+
+	token, found := strings.CutPrefix(localPart, "reply+")
+	return token, found && token != ""
+}
+
+type InboundMessage struct {
+	From     string
+	To       []string
+	Subject  string
+	Text     string
+	Calendar []byte
+}
+
+// ParseInboundMessage reads a RFC 5322 message, extracting its first text/plain and text/calendar parts.
+func ParseInboundMessage(raw []byte) (InboundMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return InboundMessage{}, err
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return InboundMessage{}, fmt.Errorf("invalid from address: %w", err)
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	message := InboundMessage{From: strings.ToLower(from.Address), Subject: subject}
+	for _, header := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		addresses, err := msg.Header.AddressList(header)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			message.To = append(message.To, strings.ToLower(address.Address))
+		}
+	}
+
+	err = readMessagePart(&message, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return InboundMessage{}, err
+	}
+
+	return message, nil
+}
+
+func readMessagePart(message *InboundMessage, contentType string, encoding string, body io.Reader) error {
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = readMessagePart(message, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	switch mediaType {
+	case "text/plain":
+		if message.Text != "" {
+			return nil
+		}
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		message.Text = string(content)
+	case "text/calendar", "application/ics":
+		if message.Calendar != nil {
+			return nil
+		}
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		message.Calendar = content
+	}
+
+	return nil
+}
+
+var quoteHeaderRegexp = regexp.MustCompile(`(?i)^(on .+ wrote:|-+ ?original message ?-+|from: .+)$`)
+
+// replyContent drops the quoted original message and the signature from a reply.
+func replyContent(text string) string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "--" || quoteHeaderRegexp.MatchString(trimmed) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		lines = append(lines, trimmed)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var replyAnswerKeywords = map[string]OptionAnswer{
+	"yes":         Available,
+	"y":           Available,
+	"available":   Available,
+	"maybe":       Maybe,
+	"m":           Maybe,
+	"ifneedbe":    Maybe,
+	"no":          Unavailable,
+	"n":           Unavailable,
+	"unavailable": Unavailable,
+}
+
+// ParseReplyAnswers parses answers such as "yes 1,3 maybe 2 no 4-6",
+// where the numbers are the 1-based positions of the options in the poll.
+func ParseReplyAnswers(text string, optionsCount int) (map[int]OptionAnswer, error) {
+	answers := map[int]OptionAnswer{}
+	fields := strings.FieldsFunc(strings.ToLower(replyContent(text)), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == ';' || r == ':'
+	})
+
+	var current OptionAnswer
+	for _, field := range fields {
+		if answer, ok := replyAnswerKeywords[strings.Trim(field, ".!")]; ok {
+			current = answer
+			continue
+		}
+
+		positions, err := parseOptionPositions(field, optionsCount)
+		if err != nil || current == "" {
+			if len(answers) > 0 {
+				// Anything after the answers, like a greeting, is ignored.
+				break
+			}
+			continue
+		}
+
+		for _, position := range positions {
+			answers[position] = current
+		}
+	}
+
+	if len(answers) == 0 {
+		return nil, errors.New("no answers found")
+	}
+
+	return answers, nil
+}
+
+func parseOptionPositions(value string, optionsCount int) ([]int, error) {
+	positions := []int{}
+	for _, part := range strings.Split(strings.Trim(value, ",."), ",") {
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if start < 1 || end > optionsCount || start > end {
+			return nil, fmt.Errorf("invalid option %s", part)
+		}
+		for position := start; position <= end; position++ {
+			positions = append(positions, position)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil, errors.New("no options")
+	}
+
+	return positions, nil
+}
+
+var itipPartstats = map[string]RSVPStatus{
+	"ACCEPTED":  RSVPAccepted,
+	"DECLINED":  RSVPDeclined,
+	"TENTATIVE": RSVPTentative,
+}
+
+type InboundMailHandler struct {
+	db *sql.DB
+}
+
+func NewInboundMailHandler(db *sql.DB) *InboundMailHandler {
+	return &InboundMailHandler{db: db}
+}
+
+// HandleMessage records the votes or RSVPs of a reply. The envelope recipients are used
+// when available, otherwise the recipients are read from the message headers.
+func (h *InboundMailHandler) HandleMessage(ctx context.Context, recipients []string, raw []byte) error {
+	message, err := ParseInboundMessage(raw)
+	if err != nil {
+		logger.Warn("failed to parse inbound email", zap.Error(err))
+		return err
+	}
+	if len(recipients) == 0 {
+		recipients = message.To
+	}
+
+	if message.Calendar != nil {
+		handled, err := h.handleITIPReply(ctx, recipients, message)
+		if err != nil || handled {
+			return err
+		}
+	}
+
+	for _, recipient := range recipients {
+		token, ok := inviteTokenFromAddress(strings.ToLower(recipient))
+		if !ok {
+			continue
+		}
+
+		return h.handleVoteReply(ctx, token, message)
+	}
+
+	return ErrUnknownRecipient
+}
+
+func (h *InboundMailHandler) handleVoteReply(ctx context.Context, token string, message InboundMessage) error {
+	invite, err := GetPollInviteByToken(ctx, h.db, token)
+	if err != nil {
+		return err
+	}
+	if invite.ID == 0 {
+		return ErrUnknownRecipient
+	}
+	if message.From != invite.Email {
+		logger.Info("vote reply sent from another address than the invite",
+			zap.Int64("inviteID", invite.ID), zap.String("from", message.From))
+	}
+
+	poll, err := GetPoll(ctx, h.db, invite.PollID)
+	if err != nil {
+		return err
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		return ErrUnknownRecipient
+	}
+
+	answers, err := ParseReplyAnswers(message.Text, len(poll.Options))
+	if err != nil {
+		logger.Info("no answers found in vote reply", zap.Int64("inviteID", invite.ID))
+		return err
+	}
+
+	availabilities := []OptionAvailability{}
+	for position, answer := range answers {
+		availabilities = append(availabilities, OptionAvailability{OptionID: poll.Options[position-1].ID, Answer: answer})
+	}
+
+	accountID, err := GetOrCreateAccount(ctx, h.db, Account{
+		Email:    invite.Email,
+		Name:     invite.Email,
+		Username: strings.Split(invite.Email, "@")[0],
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = RecordOptionAnswers(ctx, h.db, accountID, poll.ID, availabilities)
+	return err
+}
+
+// handleITIPReply records the RSVP of iTIP REPLY messages to the invite of a finalized poll. The reply has to be sent
+// to the reply address of an invite, like vote replies, and only the attendee of that invite is recorded: the sender,
+// the UID and the attendees of the message can be set by anyone.
+func (h *InboundMailHandler) handleITIPReply(ctx context.Context, recipients []string, message InboundMessage) (bool, error) {
+	calendar, err := ParseICal(bytes.NewReader(message.Calendar))
+	if err != nil {
+		logger.Warn("failed to parse inbound calendar", zap.Error(err))
+		return false, nil
+	}
+	if !strings.EqualFold(calendar.Value("METHOD"), "REPLY") {
+		return false, nil
+	}
+
+	invite := PollInvite{}
+	for _, recipient := range recipients {
+		token, ok := inviteTokenFromAddress(strings.ToLower(recipient))
+		if !ok {
+			continue
+		}
+		invite, err = GetPollInviteByToken(ctx, h.db, token)
+		if err != nil {
+			return false, err
+		}
+		if invite.ID != 0 {
+			break
+		}
+	}
+	if invite.ID == 0 {
+		logger.Info("calendar reply not sent to an invite reply address", zap.String("from", message.From))
+		return false, nil
+	}
+
+	poll, err := GetPoll(ctx, h.db, invite.PollID)
+	if err != nil {
+		return false, err
+	}
+	if reflect.ValueOf(poll).IsZero() || poll.FinalOptionID == "" {
+		return false, nil
+	}
+
+	status, ok := itipReplyStatus(calendar, poll.ID, invite.Email)
+	if !ok {
+		logger.Info("calendar reply without the attendee of the invite", zap.Int64("inviteID", invite.ID))
+		return false, nil
+	}
+
+	err = SetPollRSVP(ctx, h.db, poll.ID, invite.Email, status)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// itipReplyStatus finds the participation status of the attendee in the events of the poll.
+func itipReplyStatus(calendar *ICalComponent, pollID string, email string) (RSVPStatus, bool) {
+	for _, event := range calendar.Children("VEVENT") {
+		if eventPollID, ok := pollIDFromEventUID(event.Value("UID")); !ok || eventPollID != pollID {
+			continue
+		}
+
+		for _, attendee := range event.Props("ATTENDEE") {
+			address, found := strings.CutPrefix(strings.ToLower(attendee.Value), "mailto:")
+			if !found || address != strings.ToLower(email) {
+				continue
+			}
+
+			status, ok := itipPartstats[strings.ToUpper(attendee.Params["PARTSTAT"])]
+			if ok {
+				return status, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// inboundEmail receives raw RFC 5322 messages forwarded by a mail provider webhook.
+func (h *InboundMailHandler) inboundEmail(secret []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		raw, err := io.ReadAll(io.LimitReader(ctx.Request.Body, INBOUND_MAX_MESSAGE_SIZE))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+
+		recipients := ctx.QueryArray("recipient")
+		err = h.HandleMessage(ctx, recipients, raw)
+		if err != nil {
+			if errors.Is(err, ErrUnknownRecipient) {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown recipient"})
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"data": "ok"})
+	}
+}
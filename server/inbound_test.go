@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReplyAnswers(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected map[int]OptionAnswer
+	}{
+		{"yes 1,3 maybe 2", map[int]OptionAnswer{1: Available, 3: Available, 2: Maybe}},
+		{"Hi! Yes 1-3, no 4\n\nThanks,\nJane", map[int]OptionAnswer{1: Available, 2: Available, 3: Available, 4: Unavailable}},
+		{"maybe 2\n\nOn Tue, 2 May 2024 Roodle wrote:\n> yes 1", map[int]OptionAnswer{2: Maybe}},
+		{"no 4\n--\nyes 1", map[int]OptionAnswer{4: Unavailable}},
+	}
+
+	for _, testCase := range testCases {
+		answers, err := ParseReplyAnswers(testCase.text, 4)
+		if err != nil {
+			t.Errorf("Expected answers for %q, but got %s", testCase.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(answers, testCase.expected) {
+			t.Errorf("Expected %v for %q, but got %v", testCase.expected, testCase.text, answers)
+		}
+	}
+
+	for _, text := range []string{"", "thanks!", "yes 5", "> yes 1"} {
+		if _, err := ParseReplyAnswers(text, 4); err == nil {
+			t.Errorf("Expected no answers for %q", text)
+		}
+	}
+}
+
+const testReply = "From: Jane Doe <Jane@Example.com>\r\n" +
+	"To: reply+abc123@reply.roodle.test\r\n" +
+	"Subject: =?UTF-8?Q?Re:_Team_lunch?=\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"yes 1 maybe =\r\n2\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/calendar; method=REPLY\r\n" +
+	"\r\n" +
+	"BEGIN:VCALENDAR\r\n" +
+	"METHOD:REPLY\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:abc@roodle\r\n" +
+	"ATTENDEE;PARTSTAT=ACCEPTED;CN=\"Doe, Jane\":mailto:jane@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n" +
+	"--b1--\r\n"
+
+func TestParseInboundMessage(t *testing.T) {
+	inboundEmailDomain = "reply.roodle.test"
+
+	message, err := ParseInboundMessage([]byte(testReply))
+	if err != nil {
+		t.Fatalf("Expected message to parse, but got %s", err)
+	}
+
+	if message.From != "jane@example.com" {
+		t.Errorf("Unexpected from %s", message.From)
+	}
+	if message.Subject != "Re: Team lunch" {
+		t.Errorf("Unexpected subject %s", message.Subject)
+	}
+	if strings.TrimSpace(message.Text) != "yes 1 maybe 2" {
+		t.Errorf("Unexpected text %q", message.Text)
+	}
+	if token, ok := inviteTokenFromAddress(message.To[0]); !ok || token != "abc123" {
+		t.Errorf("Unexpected invite token %s", token)
+	}
+
+	calendar, err := ParseICal(strings.NewReader(string(message.Calendar)))
+	if err != nil {
+		t.Fatalf("Expected calendar to parse, but got %s", err)
+	}
+	attendee, _ := calendar.Children("VEVENT")[0].Prop("ATTENDEE")
+	if attendee.Params["PARTSTAT"] != "ACCEPTED" || attendee.Params["CN"] != "Doe, Jane" || attendee.Value != "mailto:jane@example.com" {
+		t.Errorf("Unexpected attendee %+v", attendee)
+	}
+}
+
+func TestSMTPServer(t *testing.T) {
+	received := make(chan []string, 1)
+	server := NewSMTPServer("", "reply.roodle.test", func(ctx context.Context, recipients []string, raw []byte) error {
+		if !strings.Contains(string(raw), "yes 1") {
+			t.Errorf("Unexpected message %q", raw)
+		}
+		received <- recipients
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx, listener)
+
+	err = smtp.SendMail(listener.Addr().String(), nil, "jane@example.com",
+		[]string{"reply+abc123@reply.roodle.test"}, []byte("Subject: Re\r\n\r\nyes 1\r\n"))
+	if err != nil {
+		t.Fatalf("Expected email to be accepted, but got %s", err)
+	}
+	if recipients := <-received; len(recipients) != 1 || recipients[0] != "reply+abc123@reply.roodle.test" {
+		t.Errorf("Unexpected recipients %v", recipients)
+	}
+
+	err = smtp.SendMail(listener.Addr().String(), nil, "jane@example.com",
+		[]string{"someone@example.com"}, []byte("Subject: Re\r\n\r\nyes 1\r\n"))
+	if err == nil {
+		t.Errorf("Expected relaying to another domain to be rejected")
+	}
+}
+
+func TestITIPReplyStatus(t *testing.T) {
+	calendar, err := ParseICal(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		"METHOD:REPLY\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:other@roodle\r\n" +
+		"ATTENDEE;PARTSTAT=DECLINED:mailto:jane@example.com\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:abc@roodle\r\n" +
+		"ATTENDEE;PARTSTAT=DECLINED:mailto:mallory@example.com\r\n" +
+		"ATTENDEE;PARTSTAT=ACCEPTED:MAILTO:Jane@Example.com\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatalf("Expected calendar to parse, but got %s", err)
+	}
+
+	if status, ok := itipReplyStatus(calendar, "abc", "jane@example.com"); !ok || status != RSVPAccepted {
+		t.Errorf("Expected the invitee to have accepted, but got %q", status)
+	}
+	if status, ok := itipReplyStatus(calendar, "abc", "john@example.com"); ok {
+		t.Errorf("Expected no status for an attendee of another invite, but got %q", status)
+	}
+	if status, ok := itipReplyStatus(calendar, "def", "jane@example.com"); ok {
+		t.Errorf("Expected no status for another poll, but got %q", status)
+	}
+}
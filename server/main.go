@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"net/http"
 	"os"
@@ -17,6 +18,7 @@ import (
 	"github.com/rtfpessoa/roodle/server/api"
 	"github.com/rtfpessoa/roodle/server/logger"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 	gintrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/gin-gonic/gin"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/profiler"
@@ -31,11 +33,12 @@ const (
 var (
 	scopes       = []string{"https://www.googleapis.com/auth/userinfo.email"}
 	cookieSecret []byte
+	baseURL      string
 	redirectURL  string
 )
 
 func init() {
-	baseURL := os.Getenv("BASE_URL")
+	baseURL = os.Getenv("BASE_URL")
 	if baseURL == "" {
 		baseURL = "https://roodle.onrender.com"
 	}
@@ -43,6 +46,13 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := RunImportCommand(context.Background(), os.Args[2:]); err != nil {
+			logger.Fatal("Failed to import polls", zap.Error(err))
+		}
+		return
+	}
+
 	tracer.Start()
 	defer tracer.Stop()
 
@@ -88,6 +98,27 @@ func StartServer() error {
 	}
 	cookieSecret = []byte(cookieSecretStr)
 
+	voteLinkSecret = cookieSecret
+	if secret := os.Getenv("VOTE_LINK_SECRET"); secret != "" {
+		voteLinkSecret = []byte(secret)
+	}
+	if ttl := os.Getenv("VOTE_LINK_TTL"); ttl != "" {
+		voteLinkTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return errors.New("invalid VOTE_LINK_TTL environment variable")
+		}
+	}
+	if retention := os.Getenv("POLL_RETENTION"); retention != "" {
+		pollRetention, err = time.ParseDuration(retention)
+		if err != nil || pollRetention <= 0 {
+			return errors.New("invalid POLL_RETENTION environment variable")
+		}
+	}
+	calendarAllowedNetworks, err = ParseCalendarAllowedNetworks(os.Getenv("CALENDAR_ALLOWED_NETWORKS"))
+	if err != nil {
+		return errors.New("invalid CALENDAR_ALLOWED_NETWORKS environment variable. Provide comma separated CIDRs or addresses")
+	}
+
 	credFile := os.Getenv("OAUTH2_GOOGLE_CREDENTIALS_FILE")
 	if credFile == "" {
 		credContents := os.Getenv("OAUTH2_GOOGLE_CREDENTIALS_CONTENTS")
@@ -106,6 +137,18 @@ func StartServer() error {
 		}
 	}
 
+	if oauthURL := os.Getenv("GOOGLE_OAUTH_URL"); oauthURL != "" {
+		conf.Endpoint = oauth2.Endpoint{AuthURL: oauthURL + "/auth", TokenURL: oauthURL + "/token"}
+	}
+	googleAPIURL = os.Getenv("GOOGLE_API_URL")
+
+	if key := os.Getenv("SECRET_ENCRYPTION_KEY"); key != "" {
+		secretEncryptionKey, err = base64.StdEncoding.DecodeString(key)
+		if err != nil || len(secretEncryptionKey) != 32 {
+			return errors.New("invalid SECRET_ENCRYPTION_KEY environment variable. Provide 32 base64 encoded bytes")
+		}
+	}
+
 	db, err := NewDB(ctx)
 	if err != nil {
 		return err
@@ -114,6 +157,27 @@ func StartServer() error {
 
 	apiServer := NewAPIServer(db)
 
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+	go NewOutboxRelay(db, EventConsumers(db)...).Run(backgroundCtx)
+	go runPeriodically(backgroundCtx, time.Hour, func(ctx context.Context) {
+		DeleteExpiredVoteLinkNonces(ctx, db)
+	})
+	go runPeriodically(backgroundCtx, POLL_PURGE_INTERVAL, func(ctx context.Context) {
+		purgeDeletedPolls(ctx, db)
+	})
+
+	inboundEmailDomain = strings.ToLower(os.Getenv("INBOUND_EMAIL_DOMAIN"))
+	inboundMailHandler := NewInboundMailHandler(db)
+	if smtpAddr := os.Getenv("INBOUND_SMTP_ADDR"); smtpAddr != "" && inboundEmailDomain != "" {
+		smtpServer := NewSMTPServer(smtpAddr, inboundEmailDomain, inboundMailHandler.HandleMessage)
+		go func() {
+			if err := smtpServer.ListenAndServe(backgroundCtx); err != nil {
+				logger.Error("failed to start smtp server", zap.Error(err))
+			}
+		}()
+	}
+
 	router := gin.New()
 	router.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
 		logger.Error("recovery from panic", zap.Any("error", err))
@@ -135,6 +199,13 @@ func StartServer() error {
 	router.GET("/health", health(db))
 	router.GET("/login", LoginHandler)
 	router.GET("/logout", LogoutHandler)
+	router.GET("/vote/link", apiServer.confirmVoteLink)
+	router.POST("/vote/link", apiServer.voteLink)
+	router.GET("/calendar/:token", apiServer.calendarFeed)
+	router.GET("/heatmap/:file", apiServer.pollHeatmapImage)
+	if secret := os.Getenv("INBOUND_EMAIL_SECRET"); secret != "" && inboundEmailDomain != "" {
+		router.POST("/inbound/email", inboundMailHandler.inboundEmail([]byte(secret)))
+	}
 
 	authRouter := router.Group("/auth")
 	router.Use(gintrace.Middleware(""))
@@ -142,15 +213,99 @@ func StartServer() error {
 	authRouter.Use(AuthMiddleware(db))
 	authRouter.GET("/google/callback", apiServer.googleCallback)
 
+	googleCalendar := NewGoogleCalendar(db)
+	if len(secretEncryptionKey) > 0 {
+		authRouter.GET("/google/calendar", WithAccountID(googleCalendar.connectGoogleCalendar))
+		authRouter.GET("/google/calendar/callback", WithAccountID(googleCalendar.googleCalendarCallback))
+	}
+
 	apiV1Router := router.Group("/api")
 	apiV1Router.Use(Auth())
 	apiV1Router.Use(AuthMiddleware(db))
 	apiV1Router.GET("/v1/poll", WithAccountID(apiServer.listPolls))
+	apiV1Router.GET("/v1/poll-search", WithAccountID(apiServer.searchPolls))
+	apiV1Router.POST("/v1/poll-import", WithAccountID(apiServer.importPoll))
+	apiV1Router.GET("/v1/poll-trash", WithAccountID(apiServer.listTrashedPolls))
+	apiV1Router.POST("/v1/poll-trash/:id/restore", WithAccountID(apiServer.restorePoll))
+	apiV1Router.DELETE("/v1/poll-trash/:id", WithAccountID(apiServer.purgePoll))
+	apiV1Router.GET("/v1/poll-template", WithAccountID(apiServer.listPollTemplates))
+	apiV1Router.POST("/v1/poll-template", WithAccountID(apiServer.newPollTemplate))
+	apiV1Router.PUT("/v1/poll-template/:id", WithAccountID(apiServer.updatePollTemplate))
+	apiV1Router.DELETE("/v1/poll-template/:id", WithAccountID(apiServer.deletePollTemplate))
+	apiV1Router.POST("/v1/poll-template/:id/poll", WithAccountID(apiServer.newPollFromTemplate))
 	apiV1Router.POST("/v1/poll", WithAccountID(apiServer.newPoll))
-	apiV1Router.GET("/v1/poll/:id", apiServer.getPoll)
+	apiV1Router.GET("/v1/poll/:id", WithAccountID(apiServer.getPoll))
 	apiV1Router.DELETE("/v1/poll/:id", WithAccountID(apiServer.deletePoll))
+	apiV1Router.POST("/v1/poll/:id/finalize", WithAccountID(apiServer.finalizePoll))
+	apiV1Router.GET("/v1/poll/:id/ics", WithAccountID(apiServer.getPollICS))
+	apiV1Router.GET("/v1/poll/:id/export", WithAccountID(apiServer.exportPoll))
+	apiV1Router.GET("/v1/poll/:id/pdf", WithAccountID(apiServer.getPollPDF))
+	apiV1Router.GET("/v1/poll/:id/heatmap", WithAccountID(apiServer.getPollHeatmap))
 	apiV1Router.POST("/v1/poll/:id/vote", WithAccountID(apiServer.newVote))
 	apiV1Router.GET("/v1/poll/:id/vote", WithAccountID(apiServer.getVote))
+	apiV1Router.POST("/v1/poll/:id/vote/ics", WithAccountID(apiServer.importVoteICS))
+	apiV1Router.GET("/v1/poll/:id/vote/profile", WithAccountID(apiServer.profileVote))
+	apiV1Router.POST("/v1/poll/:id/vote/profile", WithAccountID(apiServer.autoFillVote))
+	apiV1Router.POST("/v1/poll/:id/offline-vote", WithAccountID(apiServer.newOfflineVote))
+	apiV1Router.PUT("/v1/poll/:id/offline-vote/:voteID", WithAccountID(apiServer.updateOfflineVote))
+	apiV1Router.DELETE("/v1/poll/:id/offline-vote/:voteID", WithAccountID(apiServer.deleteOfflineVote))
+	apiV1Router.GET("/v1/poll/:id/invite", WithAccountID(apiServer.listPollInvites))
+	apiV1Router.POST("/v1/poll/:id/invite", WithAccountID(apiServer.newPollInvites))
+	apiV1Router.DELETE("/v1/poll/:id/invite/:inviteID", WithAccountID(apiServer.deletePollInvite))
+	apiV1Router.GET("/v1/poll/:id/member", WithAccountID(apiServer.listPollMembers))
+	apiV1Router.PUT("/v1/poll/:id/member", WithAccountID(apiServer.setPollMember))
+	apiV1Router.DELETE("/v1/poll/:id/member/:accountID", WithAccountID(apiServer.deletePollMember))
+	apiV1Router.POST("/v1/poll/:id/transfer", WithAccountID(apiServer.transferPoll))
+	apiV1Router.POST("/v1/poll/:id/clone", WithAccountID(apiServer.clonePoll))
+	apiV1Router.GET("/v1/poll/:id/comment", WithAccountID(apiServer.listPollComments))
+	apiV1Router.POST("/v1/poll/:id/comment", WithAccountID(apiServer.newPollComment))
+	apiV1Router.PUT("/v1/poll/:id/comment/:commentID", WithAccountID(apiServer.editPollComment))
+	apiV1Router.DELETE("/v1/poll/:id/comment/:commentID", WithAccountID(apiServer.deletePollComment))
+	apiV1Router.PUT("/v1/poll/:id/comment-lock", WithAccountID(apiServer.lockPollComments))
+	apiV1Router.PUT("/v1/poll/:id/workspace", WithAccountID(apiServer.setPollWorkspace))
+	apiV1Router.GET("/v1/poll/:id/audit", WithAccountID(apiServer.listPollAudit))
+	apiV1Router.GET("/v1/workspace", WithAccountID(apiServer.listWorkspaces))
+	apiV1Router.POST("/v1/workspace", WithAccountID(apiServer.newWorkspace))
+	apiV1Router.GET("/v1/workspace/:id", WithAccountID(apiServer.getWorkspaceDetails))
+	apiV1Router.PUT("/v1/workspace/:id", WithAccountID(apiServer.updateWorkspace))
+	apiV1Router.DELETE("/v1/workspace/:id", WithAccountID(apiServer.deleteWorkspace))
+	apiV1Router.PUT("/v1/workspace/:id/member", WithAccountID(apiServer.setWorkspaceMember))
+	apiV1Router.DELETE("/v1/workspace/:id/member/:accountID", WithAccountID(apiServer.deleteWorkspaceMember))
+	apiV1Router.GET("/v1/workspace/:id/poll", WithAccountID(apiServer.listWorkspacePolls))
+	apiV1Router.GET("/v1/calendar-feed", WithAccountID(apiServer.getCalendarFeed))
+	apiV1Router.POST("/v1/calendar-feed", WithAccountID(apiServer.newCalendarFeed))
+	apiV1Router.DELETE("/v1/calendar-feed", WithAccountID(apiServer.deleteCalendarFeed))
+	apiV1Router.GET("/v1/availability-profile", WithAccountID(apiServer.getAvailabilityProfile))
+	apiV1Router.PUT("/v1/availability-profile", WithAccountID(apiServer.setAvailabilityProfile))
+	apiV1Router.DELETE("/v1/availability-profile", WithAccountID(apiServer.deleteAvailabilityProfile))
+	apiV1Router.GET("/v1/holiday-locale", WithAccountID(apiServer.getHolidayLocale))
+	apiV1Router.PUT("/v1/holiday-locale", WithAccountID(apiServer.setHolidayLocale))
+	apiV1Router.GET("/v1/holidays", apiServer.listHolidayCalendars)
+	apiV1Router.GET("/v1/holidays/:country", apiServer.listHolidays)
+	apiV1Router.POST("/v1/option-generator", WithAccountID(apiServer.generateOptions))
+	apiV1Router.GET("/v1/notification-channel", WithAccountID(apiServer.listNotificationChannels))
+	apiV1Router.POST("/v1/notification-channel", WithAccountID(apiServer.newNotificationChannel))
+	apiV1Router.DELETE("/v1/notification-channel/:id", WithAccountID(apiServer.deleteNotificationChannel))
+	if len(secretEncryptionKey) > 0 {
+		apiV1Router.GET("/v1/google-calendar", WithAccountID(googleCalendar.getGoogleCalendar))
+		apiV1Router.DELETE("/v1/google-calendar", WithAccountID(googleCalendar.deleteGoogleCalendar))
+		apiV1Router.GET("/v1/poll/:id/vote/google", WithAccountID(googleCalendar.googleVote))
+
+		caldav := NewCalDAV(db)
+		apiV1Router.GET("/v1/caldav", WithAccountID(caldav.getCalDAVAccount))
+		apiV1Router.PUT("/v1/caldav", WithAccountID(caldav.setCalDAVAccount))
+		apiV1Router.DELETE("/v1/caldav", WithAccountID(caldav.deleteCalDAVAccount))
+		apiV1Router.GET("/v1/poll/:id/vote/caldav", WithAccountID(caldav.caldavVote))
+	}
+
+	slackSigningSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	if slackSigningSecret != "" {
+		slackHandler := NewSlackHandler(db, newSlackClient())
+		slackRouter := router.Group("/slack")
+		slackRouter.Use(VerifySlackRequest([]byte(slackSigningSecret)))
+		slackRouter.POST("/commands", slackHandler.command)
+		slackRouter.POST("/interactions", slackHandler.interaction)
+	}
 
 	api.RegisterHandlersWithOptions(router, apiServer, api.GinServerOptions{
 		BaseURL:     "/api/v1",
@@ -175,13 +330,14 @@ func StartServer() error {
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscanll.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall. SIGKILL but can"t be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Info("shutting down server...")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
@@ -199,6 +355,30 @@ func StartServer() error {
 	return nil
 }
 
+// EventConsumers returns the consumers the outbox relay publishes poll events to.
+func EventConsumers(db *sql.DB) []EventConsumer {
+	consumers := []EventConsumer{NewNotificationConsumer(db)}
+
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	if webhookURL != "" {
+		consumers = append(consumers, NewWebhookConsumer(webhookURL, []byte(os.Getenv("WEBHOOK_SECRET"))))
+	}
+
+	if os.Getenv("SLACK_SIGNING_SECRET") != "" {
+		consumers = append(consumers, NewSlackConsumer(db, newSlackClient()))
+	}
+
+	if len(secretEncryptionKey) > 0 {
+		consumers = append(consumers, NewGoogleCalendarConsumer(db), NewCalDAVConsumer(db))
+	}
+
+	return consumers
+}
+
+func newSlackClient() *SlackClient {
+	return NewSlackClient(os.Getenv("SLACK_API_URL"), os.Getenv("SLACK_BOT_TOKEN"))
+}
+
 func index(c *gin.Context) {
 	c.Status(http.StatusOK)
 	c.HTML(http.StatusOK, "index.html", nil)
@@ -247,19 +427,11 @@ func AuthMiddleware(db *sql.DB) func(ctx *gin.Context) {
 			Username: strings.Split(res.Email, "@")[0],
 		}
 
-		accountID, err := GetAccount(ctx, db, account.Email)
+		accountID, err := GetOrCreateAccount(ctx, db, account)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve account"})
 			return
 		}
-		if accountID == -1 {
-			account, err := NewAccount(ctx, db, account)
-			if err != nil {
-				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create account"})
-				return
-			}
-			accountID = account.ID
-		}
 
 		ctx.Set(ACCOUNT_ID_KEY, accountID)
 
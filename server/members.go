@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PollMemberRequest struct {
+	Email string   `json:"email"`
+	Role  PollRole `json:"role"`
+}
+
+type TransferPollRequest struct {
+	Email string `json:"email"`
+}
+
+// getMemberAccount finds the account with the email of the request, members must have signed in before.
+func (a *APIServer) getMemberAccount(ctx *gin.Context, email string) (int64, bool) {
+	if !strings.Contains(email, "@") {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+		return -1, false
+	}
+
+	memberID, err := GetAccount(ctx, a.db, email)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return -1, false
+	}
+	if memberID == -1 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return -1, false
+	}
+
+	return memberID, true
+}
+
+func (a *APIServer) listPollMembers(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollViewer)
+	if !ok {
+		return
+	}
+
+	members, err := ListPollMembers(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// setPollMember adds an editor or a viewer of the results to the poll, or changes the role of an existing member.
+func (a *APIServer) setPollMember(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollOwner)
+	if !ok {
+		return
+	}
+
+	request := PollMemberRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+	if !IsPollMemberRole(request.Role) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid role, use the transfer to change the owner"})
+		return
+	}
+
+	memberID, ok := a.getMemberAccount(ctx, request.Email)
+	if !ok {
+		return
+	}
+	if memberID == poll.AccountID {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the owner cannot be a member"})
+		return
+	}
+
+	err = SetPollMember(ctx, a.db, accountID, poll.ID, memberID, request.Role)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": PollMember{AccountID: memberID, AccountEmail: request.Email, Role: request.Role}})
+}
+
+// deletePollMember removes a member from the poll, either by the owner or by the member leaving it.
+func (a *APIServer) deletePollMember(ctx *gin.Context, accountID int64) {
+	memberID, err := strconv.ParseInt(ctx.Params.ByName("accountID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter accountID"})
+		return
+	}
+
+	minimum := PollOwner
+	if memberID == accountID {
+		minimum = PollViewer
+	}
+	poll, ok := a.getManagedPoll(ctx, accountID, minimum)
+	if !ok {
+		return
+	}
+
+	deleted, err := DeletePollMember(ctx, a.db, accountID, poll.ID, memberID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+// transferPoll hands the poll over to another account, for when the owner leaves the team.
+func (a *APIServer) transferPoll(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollOwner)
+	if !ok {
+		return
+	}
+
+	request := TransferPollRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	newOwnerID, ok := a.getMemberAccount(ctx, strings.TrimSpace(request.Email))
+	if !ok {
+		return
+	}
+	if newOwnerID == poll.AccountID {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the account already owns the poll"})
+		return
+	}
+
+	poll, err = TransferPoll(ctx, a.db, poll, newOwnerID)
+	if err != nil {
+		if errors.Is(err, ErrPollOwnerChanged) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "the poll owner changed, try again"})
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": poll})
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestHasPollRole(t *testing.T) {
+	testCases := []struct {
+		role     PollRole
+		minimum  PollRole
+		expected bool
+	}{
+		{PollOwner, PollOwner, true},
+		{PollOwner, PollViewer, true},
+		{PollEditor, PollEditor, true},
+		{PollEditor, PollOwner, false},
+		{PollViewer, PollViewer, true},
+		{PollViewer, PollEditor, false},
+		{"", PollViewer, false},
+		{"admin", PollViewer, false},
+	}
+
+	for _, testCase := range testCases {
+		if allowed := HasPollRole(testCase.role, testCase.minimum); allowed != testCase.expected {
+			t.Errorf("Expected %q with minimum %q to be %v, but got %v", testCase.role, testCase.minimum, testCase.expected, allowed)
+		}
+	}
+}
+
+func TestIsPollMemberRole(t *testing.T) {
+	testCases := map[PollRole]bool{PollOwner: false, PollEditor: true, PollViewer: true, "": false, "admin": false}
+
+	for role, expected := range testCases {
+		if allowed := IsPollMemberRole(role); allowed != expected {
+			t.Errorf("Expected %q to be %v, but got %v", role, expected, allowed)
+		}
+	}
+}
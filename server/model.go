@@ -24,6 +24,21 @@ type PollAccountAvailability struct {
 	AccountID      int64                `json:"account_id"`
 	AccountEmail   string               `json:"account_email"`
 	Availabilities []OptionAvailability `json:"availabilities"`
+	// AutoFilled votes were submitted from the availability profile and not confirmed by the account yet.
+	AutoFilled bool `json:"auto_filled,omitempty"`
+	// Offline votes have no account, an organizer recorded them for the named participant. RecordedBy is the organizer email.
+	OfflineID       int64  `json:"offline_id,omitempty"`
+	ParticipantName string `json:"participant_name,omitempty"`
+	RecordedBy      string `json:"recorded_by,omitempty"`
+}
+
+// Participant returns the email of the voter, or the name of the participant of an offline vote.
+func (v PollAccountAvailability) Participant() string {
+	if v.OfflineID != 0 {
+		return v.ParticipantName
+	}
+
+	return v.AccountEmail
 }
 
 type PollOption struct {
@@ -41,8 +56,71 @@ type PollBase struct {
 
 type Poll struct {
 	PollBase
-	ID        string `json:"id"`
-	AccountID int64  `json:"-"`
+	ID            string `json:"id"`
+	AccountID     int64  `json:"-"`
+	FinalOptionID string `json:"final_option_id,omitempty"`
+	WorkspaceID   int64  `json:"workspace_id,omitempty"`
+	// AnswerScale restricts the answers of the poll, within the answer scale of its workspace.
+	AnswerScale []OptionAnswer `json:"answer_scale,omitempty"`
+}
+
+// FinalOption returns the option chosen by the poll owner, if the poll was finalized.
+func (p Poll) FinalOption() (PollOption, bool) {
+	for _, option := range p.Options {
+		if p.FinalOptionID != "" && option.ID == p.FinalOptionID {
+			return option, true
+		}
+	}
+
+	return PollOption{}, false
+}
+
+// VotedPoll is a poll with the answers of a single account.
+type VotedPoll struct {
+	Poll
+	Availabilities []OptionAvailability `json:"availabilities"`
+}
+
+// PollSummary is the lightweight shape of a poll in listings, without the options.
+type PollSummary struct {
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	Location      string     `json:"location"`
+	Role          string     `json:"role"`
+	FinalOptionID string     `json:"final_option_id,omitempty"`
+	WorkspaceID   int64      `json:"workspace_id,omitempty"`
+	OptionCount   int        `json:"option_count"`
+	StartsAt      *time.Time `json:"starts_at,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	Participants  int        `json:"participants"`
+	CreatedAt     time.Time  `json:"created_at"`
+	// SortTitle is the title as the database sorts it, for the cursor of the next page.
+	SortTitle string `json:"-"`
+}
+
+// TrashedPoll is a deleted poll kept with its votes until it is purged.
+type TrashedPoll struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Location     string    `json:"location"`
+	WorkspaceID  int64     `json:"workspace_id,omitempty"`
+	OptionCount  int       `json:"option_count"`
+	Participants int       `json:"participants"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	PurgeAt      time.Time `json:"purge_at"`
+}
+
+// PollSearchResult is a poll matching a search, the highlights mark the matched terms in HTML escaped text.
+type PollSearchResult struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Description    string  `json:"-"`
+	Location       string  `json:"location"`
+	FinalOptionID  string  `json:"final_option_id,omitempty"`
+	OwnerEmail     string  `json:"owner_email"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
 }
 
 type Account struct {
@@ -51,3 +129,187 @@ type Account struct {
 	Username string `json:"username"`
 	Name     string `json:"name"`
 }
+
+type PollRole = string
+
+const (
+	// PollOwner is the account in polls.account_id, it is not stored as a poll member.
+	PollOwner  PollRole = "owner"
+	PollEditor PollRole = "editor"
+	PollViewer PollRole = "viewer"
+)
+
+var (
+	// pollRoleRanks orders the roles, each role can do everything the lower ranked ones can.
+	pollRoleRanks = map[PollRole]int{PollViewer: 1, PollEditor: 2, PollOwner: 3}
+)
+
+// HasPollRole tells if a role grants at least the permissions of the minimum role.
+func HasPollRole(role PollRole, minimum PollRole) bool {
+	return pollRoleRanks[role] > 0 && pollRoleRanks[role] >= pollRoleRanks[minimum]
+}
+
+// IsPollMemberRole tells if a role can be given to a poll member, the owner only changes with a transfer.
+func IsPollMemberRole(role PollRole) bool {
+	return role == PollEditor || role == PollViewer
+}
+
+type PollMember struct {
+	AccountID    int64    `json:"account_id"`
+	AccountEmail string   `json:"account_email"`
+	Role         PollRole `json:"role"`
+}
+
+type WorkspaceRole = string
+
+const (
+	WorkspaceAdmin  WorkspaceRole = "admin"
+	WorkspaceMember WorkspaceRole = "member"
+)
+
+type PollVisibility = string
+
+const (
+	// VisibleWithLink polls can be seen by any signed-in account holding the link.
+	VisibleWithLink PollVisibility = "link"
+	// VisibleInWorkspace polls can only be seen by workspace members and the poll members, invitees and voters.
+	VisibleInWorkspace PollVisibility = "workspace"
+)
+
+// WorkspaceSettings are the defaults and limits the workspace admins apply to the polls of the workspace.
+type WorkspaceSettings struct {
+	Visibility          PollVisibility `json:"visibility"`
+	TimeZone            string         `json:"time_zone,omitempty"`
+	AnswerScale         []OptionAnswer `json:"answer_scale,omitempty"`
+	AllowedEmailDomains []string       `json:"allowed_email_domains,omitempty"`
+	MaxPolls            int64          `json:"max_polls"`
+}
+
+type Workspace struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Settings WorkspaceSettings `json:"settings"`
+	// Role is the role of the requesting account in the workspace.
+	Role WorkspaceRole `json:"role,omitempty"`
+}
+
+type WorkspaceMembership struct {
+	AccountID    int64         `json:"account_id"`
+	AccountEmail string        `json:"account_email"`
+	Role         WorkspaceRole `json:"role"`
+}
+
+type PollComment struct {
+	ID           int64      `json:"id"`
+	PollID       string     `json:"poll_id"`
+	AccountID    int64      `json:"account_id"`
+	AccountEmail string     `json:"account_email"`
+	OptionID     string     `json:"option_id,omitempty"`
+	Body         string     `json:"body"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EditedAt     *time.Time `json:"edited_at,omitempty"`
+}
+
+// PollTemplate is a saved starting point for recurring polls, the options are created from a start time and the duration.
+type PollTemplate struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	// Duration is the length of the options in minutes.
+	Duration    int            `json:"duration"`
+	AnswerScale []OptionAnswer `json:"answer_scale"`
+	Invites     []string       `json:"invites"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// HolidayLocale is the country, and optionally the region, whose public holidays apply to an account.
+type HolidayLocale struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+type SlackMessage struct {
+	PollID    string `json:"poll_id"`
+	ChannelID string `json:"channel_id"`
+	TS        string `json:"ts"`
+}
+
+type ChatKind = string
+
+const (
+	Mattermost ChatKind = "mattermost"
+	Discord    ChatKind = "discord"
+	Teams      ChatKind = "teams"
+)
+
+var (
+	AllChatKind []ChatKind = []ChatKind{Mattermost, Discord, Teams}
+)
+
+// NotificationChannel is a chat incoming webhook that receives the events of the account polls,
+// or of a single poll when PollID is set.
+type NotificationChannel struct {
+	ID            int64    `json:"id"`
+	AccountID     int64    `json:"-"`
+	PollID        string   `json:"poll_id,omitempty"`
+	Kind          ChatKind `json:"kind"`
+	URL           string   `json:"url"`
+	VoteThreshold int      `json:"vote_threshold"`
+}
+
+// PollInvite is an email invited to answer a poll, the token identifies the invitee without a login.
+type PollInvite struct {
+	ID        int64     `json:"id"`
+	PollID    string    `json:"poll_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RSVPStatus = string
+
+const (
+	RSVPAccepted  RSVPStatus = "accepted"
+	RSVPTentative RSVPStatus = "tentative"
+	RSVPDeclined  RSVPStatus = "declined"
+)
+
+// PollRSVP is the answer of an attendee to the calendar invite of a finalized poll.
+type PollRSVP struct {
+	Email     string     `json:"email"`
+	Status    RSVPStatus `json:"status"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CalDAVAccount is the calendar collection of an account on a CalDAV server, the password is only accepted on registration.
+type CalDAVAccount struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	Holds    bool   `json:"holds"`
+}
+
+// CalDAVHold is a tentative event created in the calendar of an account for an option it answered available.
+type CalDAVHold struct {
+	PollID    string `json:"poll_id"`
+	AccountID int64  `json:"account_id"`
+	OptionID  string `json:"option_id"`
+	Href      string `json:"href"`
+}
+
+// AvailabilityRule answers the options between start and end ("15:04", "24:00" for midnight) on the given days ("mon" to "sun").
+type AvailabilityRule struct {
+	Days   []string     `json:"days"`
+	Start  string       `json:"start"`
+	End    string       `json:"end"`
+	Answer OptionAnswer `json:"answer"`
+}
+
+// AvailabilityProfile is the weekly availability of an account in its time zone, used to answer new polls.
+type AvailabilityProfile struct {
+	TimeZone   string             `json:"time_zone"`
+	Rules      []AvailabilityRule `json:"rules"`
+	AutoSubmit bool               `json:"auto_submit"`
+}
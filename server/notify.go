@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	CHAT_TOP_OPTIONS = 3
+)
+
+type ChatField struct {
+	Name  string
+	Value string
+}
+
+type ChatMessage struct {
+	Title  string
+	Text   string
+	URL    string
+	Fields []ChatField
+}
+
+// ChatNotifier delivers a message to a chat incoming webhook.
+type ChatNotifier interface {
+	Notify(ctx context.Context, message ChatMessage) error
+}
+
+var (
+	// webhookBlockedNetworks are not private addresses for the net package, but are not reachable on the internet either.
+	webhookBlockedNetworks = []net.IPNet{
+		{IP: net.IP{0, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+		{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)},
+	}
+)
+
+// isPublicIP rejects the loopback, link-local, private and other addresses that are not on the internet.
+func isPublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, network := range webhookBlockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateWebhookURL only accepts https urls, hosts given as addresses have to be public.
+func validateWebhookURL(rawURL string) error {
+	webhookURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if webhookURL.Scheme != "https" || webhookURL.Hostname() == "" {
+		return fmt.Errorf("webhook url %s is not an https url", rawURL)
+	}
+	if webhookURL.Hostname() == "localhost" || strings.HasSuffix(webhookURL.Hostname(), ".localhost") {
+		return fmt.Errorf("webhook host %s is not public", webhookURL.Hostname())
+	}
+	if ip := net.ParseIP(webhookURL.Hostname()); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("webhook host %s is not public", webhookURL.Hostname())
+	}
+
+	return nil
+}
+
+// newWebhookClient posts to the urls set by the accounts, so it only connects to public addresses and https urls.
+// The address is checked when dialing, after the name was resolved, so a name resolving to an internal address is
+// refused as well.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("webhook redirected too many times")
+			}
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("webhook redirected to %s, which is not an https url", req.URL)
+			}
+			return nil
+		},
+	}
+}
+
+func NewChatNotifier(kind ChatKind, webhookURL string) (ChatNotifier, error) {
+	return newChatNotifier(kind, webhookURL, newWebhookClient())
+}
+
+func newChatNotifier(kind ChatKind, webhookURL string, client *http.Client) (ChatNotifier, error) {
+	switch kind {
+	case Mattermost:
+		return &MattermostNotifier{url: webhookURL, client: client}, nil
+	case Discord:
+		return &DiscordNotifier{url: webhookURL, client: client}, nil
+	case Teams:
+		return &TeamsNotifier{url: webhookURL, client: client}, nil
+	}
+
+	return nil, fmt.Errorf("unknown chat kind %s", kind)
+}
+
+func postWebhookJSON(ctx context.Context, client *http.Client, webhookURL string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+type MattermostNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (m *MattermostNotifier) Notify(ctx context.Context, message ChatMessage) error {
+	fields := []map[string]interface{}{}
+	for _, field := range message.Fields {
+		fields = append(fields, map[string]interface{}{"title": field.Name, "value": field.Value, "short": false})
+	}
+
+	return postWebhookJSON(ctx, m.client, m.url, map[string]interface{}{
+		"username": "roodle",
+		"attachments": []map[string]interface{}{{
+			"fallback":   message.Title,
+			"title":      message.Title,
+			"title_link": message.URL,
+			"text":       message.Text,
+			"fields":     fields,
+		}},
+	})
+}
+
+type DiscordNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (d *DiscordNotifier) Notify(ctx context.Context, message ChatMessage) error {
+	fields := []map[string]interface{}{}
+	for _, field := range message.Fields {
+		fields = append(fields, map[string]interface{}{"name": field.Name, "value": field.Value, "inline": false})
+	}
+
+	return postWebhookJSON(ctx, d.client, d.url, map[string]interface{}{
+		"username": "roodle",
+		"embeds": []map[string]interface{}{{
+			"title":       message.Title,
+			"url":         message.URL,
+			"description": message.Text,
+			"fields":      fields,
+		}},
+	})
+}
+
+type TeamsNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (t *TeamsNotifier) Notify(ctx context.Context, message ChatMessage) error {
+	facts := []map[string]interface{}{}
+	for _, field := range message.Fields {
+		facts = append(facts, map[string]interface{}{"title": field.Name, "value": field.Value})
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": message.Title, "weight": "Bolder", "size": "Medium", "wrap": true},
+	}
+	if message.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": message.Text, "wrap": true})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	return postWebhookJSON(ctx, t.client, t.url, map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+				"actions": []map[string]interface{}{
+					{"type": "Action.OpenUrl", "title": "Open poll", "url": message.URL},
+				},
+			},
+		}},
+	})
+}
+
+func formatOptionRange(option PollOption, location *time.Location) string {
+	start := option.Start.In(location)
+	end := option.End.In(location)
+
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return start.Format("Mon, 02 Jan 2006 15:04") + " - " + end.Format("15:04 MST")
+	}
+
+	return start.Format("Mon, 02 Jan 2006 15:04 MST") + " - " + end.Format("Mon, 02 Jan 2006 15:04 MST")
+}
+
+func formatOptionCounts(result OptionResult) string {
+	return fmt.Sprintf("Available: %d · Maybe: %d · Unavailable: %d",
+		result.Counts[Available], result.Counts[Maybe], result.Counts[Unavailable])
+}
+
+func chatPollCreatedMessage(poll Poll) ChatMessage {
+	fields := []ChatField{{Name: "Options", Value: strconv.Itoa(len(poll.Options))}}
+	if poll.Location != "" {
+		fields = append(fields, ChatField{Name: "Location", Value: poll.Location})
+	}
+
+	return ChatMessage{
+		Title:  "New poll: " + poll.Title,
+		Text:   poll.Description,
+		URL:    pollURL(poll.ID),
+		Fields: fields,
+	}
+}
+
+func chatThresholdMessage(poll Poll, results PollResults) ChatMessage {
+	fields := []ChatField{}
+	for idx, result := range results.Best() {
+		if idx >= CHAT_TOP_OPTIONS {
+			break
+		}
+		fields = append(fields, ChatField{Name: formatOptionRange(result.Option, time.UTC), Value: formatOptionCounts(result)})
+	}
+
+	return ChatMessage{
+		Title:  fmt.Sprintf("%s has %d participants", poll.Title, results.Participants),
+		Text:   "Best options so far:",
+		URL:    pollURL(poll.ID),
+		Fields: fields,
+	}
+}
+
+func chatFinalizedMessage(poll Poll, results PollResults) ChatMessage {
+	message := ChatMessage{
+		Title: poll.Title + " was finalized",
+		URL:   pollURL(poll.ID),
+	}
+
+	for _, result := range results.Options {
+		if result.Option.ID == poll.FinalOptionID {
+			message.Fields = append(message.Fields,
+				ChatField{Name: "When", Value: formatOptionRange(result.Option, time.UTC)},
+				ChatField{Name: "Answers", Value: formatOptionCounts(result)})
+		}
+	}
+	if poll.Location != "" {
+		message.Fields = append(message.Fields, ChatField{Name: "Location", Value: poll.Location})
+	}
+
+	return message
+}
+
+// NotificationConsumer sends chat messages to the channels configured by the poll owner.
+type NotificationConsumer struct {
+	db *sql.DB
+}
+
+func NewNotificationConsumer(db *sql.DB) *NotificationConsumer {
+	return &NotificationConsumer{db: db}
+}
+
+func (n *NotificationConsumer) Name() string {
+	return "notifications"
+}
+
+func (n *NotificationConsumer) Consume(ctx context.Context, event PollEvent) error {
+	var poll Poll
+	switch event.Type {
+	case PollCreatedEvent, PollFinalizedEvent:
+		payload := PollEventPayload{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			logger.Error("failed to unmarshal poll event payload", zap.Int64("eventID", event.ID), zap.Error(err))
+			return nil
+		}
+		poll = payload.Poll
+		poll.AccountID = payload.AccountID
+	case VoteCastEvent:
+		var err error
+		poll, err = GetPoll(ctx, n.db, event.PollID)
+		if err != nil {
+			return err
+		}
+		if reflect.ValueOf(poll).IsZero() {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	channels, err := ListPollNotificationChannels(ctx, n.db, poll.AccountID, poll.ID)
+	if err != nil || len(channels) == 0 {
+		return err
+	}
+
+	votes, err := ListVotes(ctx, n.db, poll.ID)
+	if err != nil {
+		return err
+	}
+	results := CalculateResults(poll, votes)
+
+	var deliveryErr error
+	for _, channel := range channels {
+		var message ChatMessage
+		switch event.Type {
+		case PollCreatedEvent:
+			message = chatPollCreatedMessage(poll)
+		case PollFinalizedEvent:
+			message = chatFinalizedMessage(poll, results)
+		case VoteCastEvent:
+			if channel.VoteThreshold <= 0 || results.Participants < channel.VoteThreshold {
+				continue
+			}
+			reached, err := IsNotificationThresholdReached(ctx, n.db, channel.ID, poll.ID)
+			if err != nil {
+				deliveryErr = err
+				continue
+			}
+			if reached {
+				continue
+			}
+			message = chatThresholdMessage(poll, results)
+		}
+
+		delivered, err := IsNotificationDelivered(ctx, n.db, channel.ID, event.ID)
+		if err != nil {
+			deliveryErr = err
+			continue
+		}
+		if delivered {
+			continue
+		}
+
+		notifier, err := NewChatNotifier(channel.Kind, channel.URL)
+		if err != nil {
+			logger.Error("invalid notification channel", zap.Int64("channelID", channel.ID), zap.Error(err))
+			continue
+		}
+
+		err = notifier.Notify(ctx, message)
+		if err != nil {
+			logger.Warn("failed to send chat notification", zap.Int64("channelID", channel.ID), zap.Error(err))
+			deliveryErr = err
+			continue
+		}
+
+		if event.Type == VoteCastEvent {
+			if err := MarkNotificationThresholdReached(ctx, n.db, channel.ID, poll.ID); err != nil {
+				deliveryErr = err
+			}
+		}
+		if err := MarkNotificationDelivered(ctx, n.db, channel.ID, event.ID); err != nil {
+			deliveryErr = err
+		}
+	}
+
+	return deliveryErr
+}
+
+func (a *APIServer) listNotificationChannels(ctx *gin.Context, accountID int64) {
+	channels, err := ListNotificationChannels(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": channels})
+}
+
+func (a *APIServer) newNotificationChannel(ctx *gin.Context, accountID int64) {
+	channel := NotificationChannel{}
+	err := readBody(ctx, &channel)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if !slices.Contains(AllChatKind, channel.Kind) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest,
+			gin.H{"error": fmt.Sprintf("invalid kind. needs to be one of: %s", strings.Join(AllChatKind, ", "))})
+		return
+	}
+
+	if err := validateWebhookURL(channel.URL); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid webhook url, it needs to be a public https url"})
+		return
+	}
+
+	if channel.VoteThreshold < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid vote threshold"})
+		return
+	}
+
+	if channel.PollID != "" {
+		role, err := GetPollRole(ctx, a.db, channel.PollID, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		if !HasPollRole(role, PollViewer) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+			return
+		}
+	}
+
+	createdChannel, err := NewNotificationChannel(ctx, a.db, accountID, channel)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": createdChannel})
+}
+
+func (a *APIServer) deleteNotificationChannel(ctx *gin.Context, accountID int64) {
+	channelID, err := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return
+	}
+
+	deleted, err := DeleteNotificationChannel(ctx, a.db, accountID, channelID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "notification channel not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": channelID})
+}
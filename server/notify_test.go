@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCalculateResults(t *testing.T) {
+	start := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	poll := Poll{ID: "abc", PollBase: PollBase{Options: []PollOption{
+		{ID: "o1", Start: start, End: start.Add(time.Hour)},
+		{ID: "o2", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+	}}}
+	votes := []PollAccountAvailability{
+		{AccountEmail: "a@example.com", Availabilities: []OptionAvailability{{"o1", Maybe}, {"o2", Available}}},
+		{AccountEmail: "b@example.com", Availabilities: []OptionAvailability{{"o1", Unavailable}, {"o2", Available}, {"unknown", Available}}},
+		{AccountEmail: "c@example.com", AutoFilled: true, Availabilities: []OptionAvailability{{"o1", Available}, {"o2", Available}}},
+	}
+
+	results := CalculateResults(poll, votes)
+
+	if results.Participants != 2 || results.AutoFilled != 1 {
+		t.Errorf("Expected 2 participants and 1 auto-filled vote, but got %d and %d", results.Participants, results.AutoFilled)
+	}
+	if results.Options[0].Score != -2 || results.Options[1].Score != 6 {
+		t.Errorf("Unexpected scores %d and %d", results.Options[0].Score, results.Options[1].Score)
+	}
+	if results.Options[1].Counts[Available] != 2 {
+		t.Errorf("Expected 2 available answers, but got %d", results.Options[1].Counts[Available])
+	}
+	if best := results.Best(); best[0].Option.ID != "o2" {
+		t.Errorf("Expected o2 to be the best option, but got %s", best[0].Option.ID)
+	}
+}
+
+func TestChatNotifiers(t *testing.T) {
+	message := ChatMessage{Title: "Lunch was finalized", URL: "https://roodle.test/poll/abc", Fields: []ChatField{{"When", "Thu"}}}
+
+	testCases := map[ChatKind]func(payload map[string]interface{}) bool{
+		Mattermost: func(payload map[string]interface{}) bool {
+			attachments, ok := payload["attachments"].([]interface{})
+			return ok && attachments[0].(map[string]interface{})["title"] == message.Title
+		},
+		Discord: func(payload map[string]interface{}) bool {
+			embeds, ok := payload["embeds"].([]interface{})
+			return ok && embeds[0].(map[string]interface{})["url"] == message.URL
+		},
+		Teams: func(payload map[string]interface{}) bool {
+			attachments, ok := payload["attachments"].([]interface{})
+			return ok && attachments[0].(map[string]interface{})["contentType"] == "application/vnd.microsoft.card.adaptive"
+		},
+	}
+
+	for kind, check := range testCases {
+		t.Run(kind, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				payload := map[string]interface{}{}
+				json.NewDecoder(r.Body).Decode(&payload)
+				if !check(payload) {
+					t.Errorf("Unexpected %s payload %v", kind, payload)
+				}
+			}))
+			defer server.Close()
+
+			notifier, err := newChatNotifier(kind, server.URL, server.Client())
+			if err != nil {
+				t.Fatalf("Expected notifier, but got %s", err)
+			}
+			if err := notifier.Notify(context.Background(), message); err != nil {
+				t.Errorf("Expected notification to be sent, but got %s", err)
+			}
+		})
+	}
+
+	if _, err := NewChatNotifier("irc", "https://example.com"); err == nil {
+		t.Errorf("Expected unknown chat kind to be rejected")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	testCases := map[string]bool{
+		"https://hooks.example.com/services/abc": true,
+		"https://93.184.216.34/hook":             true,
+		"http://hooks.example.com/services/abc":  false,
+		"https:///hook":                          false,
+		"https://localhost/hook":                 false,
+		"https://127.0.0.1:8080/hook":            false,
+		"https://169.254.169.254/latest":         false,
+		"https://10.0.0.12/hook":                 false,
+		"https://100.64.1.1/hook":                false,
+		"https://[::1]/hook":                     false,
+		"https://[fd00::1]/hook":                 false,
+	}
+
+	for webhookURL, valid := range testCases {
+		if err := validateWebhookURL(webhookURL); (err == nil) != valid {
+			t.Errorf("Expected %s to be valid %t, but got %v", webhookURL, valid, err)
+		}
+	}
+}
+
+func TestChatNotifierInternalAddress(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the internal address not to be reached")
+	}))
+	defer server.Close()
+
+	notifier, err := NewChatNotifier(Mattermost, server.URL)
+	if err != nil {
+		t.Fatalf("Expected notifier, but got %s", err)
+	}
+	if err := notifier.Notify(context.Background(), ChatMessage{Title: "Lunch"}); err == nil {
+		t.Errorf("Expected the loopback address to be refused at dial time")
+	}
+}
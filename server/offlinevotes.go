@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	PARTICIPANT_NAME_MAX_LENGTH = 100
+)
+
+// OfflineVoteRequest holds the answers of a participant without an account, given to the owner by phone or in person.
+type OfflineVoteRequest struct {
+	Name           string               `json:"name"`
+	Availabilities []OptionAvailability `json:"availabilities"`
+}
+
+// Validate trims the name and checks the answers are for options of the poll and within its answer scale.
+func (r *OfflineVoteRequest) Validate(poll Poll, answers []OptionAnswer) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if utf8.RuneCountInString(r.Name) > PARTICIPANT_NAME_MAX_LENGTH {
+		return fmt.Errorf("name too long, the maximum is %d characters", PARTICIPANT_NAME_MAX_LENGTH)
+	}
+
+	if r.Availabilities == nil {
+		r.Availabilities = []OptionAvailability{}
+	}
+	seen := map[string]bool{}
+	for _, availability := range r.Availabilities {
+		if !slices.ContainsFunc(poll.Options, func(option PollOption) bool { return option.ID == availability.OptionID }) {
+			return fmt.Errorf("invalid option %s", availability.OptionID)
+		}
+		if seen[availability.OptionID] {
+			return fmt.Errorf("duplicated option %s", availability.OptionID)
+		}
+		seen[availability.OptionID] = true
+
+		if !slices.Contains(answers, availability.Answer) {
+			return fmt.Errorf("invalid availability. needs to be one of: %s", strings.Join(answers, ", "))
+		}
+	}
+
+	return nil
+}
+
+// readOfflineVote loads the poll owned by the account and the validated offline vote in the request body.
+func (a *APIServer) readOfflineVote(ctx *gin.Context, accountID int64) (PollAccountAvailability, bool) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollOwner)
+	if !ok {
+		return PollAccountAvailability{}, false
+	}
+
+	request := OfflineVoteRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return PollAccountAvailability{}, false
+	}
+
+	workspace, err := a.getPollWorkspace(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return PollAccountAvailability{}, false
+	}
+	err = request.Validate(poll, workspace.Settings.PollAnswers(poll))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return PollAccountAvailability{}, false
+	}
+
+	account, err := GetAccountByID(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return PollAccountAvailability{}, false
+	}
+
+	return PollAccountAvailability{
+		PollID:          poll.ID,
+		Availabilities:  request.Availabilities,
+		ParticipantName: request.Name,
+		RecordedBy:      account.Email,
+	}, true
+}
+
+func (a *APIServer) newOfflineVote(ctx *gin.Context, accountID int64) {
+	vote, ok := a.readOfflineVote(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	vote, err := NewOfflineVote(ctx, a.db, accountID, vote)
+	if err != nil {
+		if errors.Is(err, ErrOfflineParticipantExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a participant with this name already answered the poll"})
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": vote})
+}
+
+func (a *APIServer) updateOfflineVote(ctx *gin.Context, accountID int64) {
+	offlineID, err := strconv.ParseInt(ctx.Params.ByName("voteID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter voteID"})
+		return
+	}
+
+	vote, ok := a.readOfflineVote(ctx, accountID)
+	if !ok {
+		return
+	}
+	vote.OfflineID = offlineID
+
+	updated, err := UpdateOfflineVote(ctx, a.db, accountID, vote)
+	if err != nil {
+		if errors.Is(err, ErrOfflineParticipantExists) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a participant with this name already answered the poll"})
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if updated.OfflineID == 0 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "offline vote not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+func (a *APIServer) deleteOfflineVote(ctx *gin.Context, accountID int64) {
+	offlineID, err := strconv.ParseInt(ctx.Params.ByName("voteID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter voteID"})
+		return
+	}
+
+	poll, ok := a.getManagedPoll(ctx, accountID, PollOwner)
+	if !ok {
+		return
+	}
+
+	deleted, err := DeleteOfflineVote(ctx, a.db, accountID, poll.ID, offlineID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "offline vote not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOfflineVoteRequestValidate(t *testing.T) {
+	poll := Poll{ID: "poll", PollBase: PollBase{Options: []PollOption{{ID: "o1"}, {ID: "o2"}}}}
+	answers := []OptionAnswer{Available, Unavailable}
+
+	request := OfflineVoteRequest{Name: "  Grandma  "}
+	if err := request.Validate(poll, answers); err != nil || request.Name != "Grandma" || request.Availabilities == nil {
+		t.Errorf("Expected trimmed valid vote, but got %+v (%v)", request, err)
+	}
+
+	testCases := []OfflineVoteRequest{
+		{Name: "   "},
+		{Name: strings.Repeat("a", PARTICIPANT_NAME_MAX_LENGTH+1)},
+		{Name: "Grandma", Availabilities: []OptionAvailability{{OptionID: "o3", Answer: Available}}},
+		{Name: "Grandma", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Maybe}}},
+		{Name: "Grandma", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}, {OptionID: "o1", Answer: Unavailable}}},
+	}
+	for _, request := range testCases {
+		if err := request.Validate(poll, answers); err == nil {
+			t.Errorf("Expected %+v to be invalid", request)
+		}
+	}
+}
+
+func TestCalculateResultsOfflineVotes(t *testing.T) {
+	poll := Poll{PollBase: PollBase{Options: []PollOption{{ID: "o1"}}}}
+	votes := []PollAccountAvailability{
+		{AccountEmail: "ana@example.com", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}}},
+		{OfflineID: 1, ParticipantName: "Grandma", RecordedBy: "ana@example.com", Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}}},
+	}
+
+	results := CalculateResults(poll, votes)
+	emails := results.Options[0].Emails[Available]
+	if results.Participants != 2 || len(emails) != 2 || emails[1] != "Grandma" {
+		t.Errorf("Expected the offline participant in the results, but got %+v", results)
+	}
+}
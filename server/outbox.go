@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+type EventType = string
+
+const (
+	PollCreatedEvent     EventType = "poll.created"
+	PollDeletedEvent     EventType = "poll.deleted"
+	PollFinalizedEvent   EventType = "poll.finalized"
+	PollReopenedEvent    EventType = "poll.reopened"
+	PollRestoredEvent    EventType = "poll.restored"
+	PollTransferredEvent EventType = "poll.transferred"
+	VoteCastEvent        EventType = "vote.cast"
+)
+
+const (
+	OUTBOX_POLL_INTERVAL = 2 * time.Second
+	OUTBOX_BATCH_SIZE    = 50
+	// Events are leased while being delivered, if the process dies mid delivery
+	// the lease expires and another relay picks the event up again.
+	OUTBOX_LEASE_DURATION = 1 * time.Minute
+	OUTBOX_MAX_BACKOFF    = 1 * time.Hour
+)
+
+type PollEvent struct {
+	ID        int64           `json:"id"`
+	PollID    string          `json:"poll_id"`
+	Type      EventType       `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"-"`
+}
+
+// PollEventPayload is the payload of the poll lifecycle events.
+// Vote events carry the PollAccountAvailability instead.
+type PollEventPayload struct {
+	AccountID int64 `json:"account_id"`
+	Poll      Poll  `json:"poll"`
+}
+
+// EventConsumer receives poll events from the outbox relay.
+// Delivery is at-least-once so consumers should use the event id to deduplicate.
+type EventConsumer interface {
+	Name() string
+	Consume(ctx context.Context, event PollEvent) error
+}
+
+func insertPollEvent(ctx context.Context, tx *sql.Tx, pollID string, eventType EventType, payload interface{}) error {
+	sqlStatement := `
+INSERT INTO poll_events (poll_id, type, payload)
+VALUES ($1, $2, $3);`
+
+	marshaledPayload, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("failed to marshal poll event payload", zap.String("type", eventType), zap.Error(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStatement, pollID, eventType, string(marshaledPayload))
+	if err != nil {
+		logger.Error("failed to create poll event", zap.String("type", eventType), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func ClaimPollEvents(ctx context.Context, db *sql.DB, limit int) ([]PollEvent, error) {
+	sqlStatement := `
+UPDATE poll_events
+SET available_at = now() + $2 * INTERVAL '1 second', attempts = attempts + 1
+WHERE id IN (
+	SELECT id FROM poll_events
+	WHERE delivered_at IS NULL AND available_at <= now()
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, poll_id, type, payload, created_at, attempts;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, limit, OUTBOX_LEASE_DURATION.Seconds())
+	if err != nil {
+		logger.Error("failed to claim poll events", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []PollEvent{}
+	for rows.Next() {
+		var event PollEvent
+		var payload string
+		err = rows.Scan(&event.ID, &event.PollID, &event.Type, &payload, &event.CreatedAt, &event.Attempts)
+		if err != nil {
+			logger.Error("failed to read poll event fields", zap.Error(err))
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to read poll events", zap.Error(err))
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func ListPollEventDeliveries(ctx context.Context, db *sql.DB, eventID int64) ([]string, error) {
+	sqlStatement := `SELECT consumer FROM poll_event_deliveries WHERE event_id = $1;`
+
+	rows, err := db.QueryContext(ctx, sqlStatement, eventID)
+	if err != nil {
+		logger.Error("failed to retrieve poll event deliveries", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	consumers := []string{}
+	for rows.Next() {
+		var consumer string
+		if err := rows.Scan(&consumer); err != nil {
+			logger.Error("failed to read poll event delivery fields", zap.Error(err))
+			return nil, err
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	return consumers, rows.Err()
+}
+
+func MarkPollEventConsumed(ctx context.Context, db *sql.DB, eventID int64, consumer string) error {
+	sqlStatement := `
+INSERT INTO poll_event_deliveries (event_id, consumer)
+VALUES ($1, $2)
+ON CONFLICT (event_id, consumer) DO NOTHING;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, eventID, consumer)
+	if err != nil {
+		logger.Error("failed to mark poll event consumed", zap.Int64("eventID", eventID), zap.String("consumer", consumer), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func MarkPollEventDelivered(ctx context.Context, db *sql.DB, eventID int64) error {
+	sqlStatement := `UPDATE poll_events SET delivered_at = now(), last_error = NULL WHERE id = $1;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, eventID)
+	if err != nil {
+		logger.Error("failed to mark poll event delivered", zap.Int64("eventID", eventID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func RetryPollEvent(ctx context.Context, db *sql.DB, eventID int64, backoff time.Duration, lastError string) error {
+	sqlStatement := `
+UPDATE poll_events
+SET available_at = now() + $2 * INTERVAL '1 second', last_error = $3
+WHERE id = $1;`
+
+	_, err := db.ExecContext(ctx, sqlStatement, eventID, backoff.Seconds(), lastError)
+	if err != nil {
+		logger.Error("failed to reschedule poll event", zap.Int64("eventID", eventID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// OutboxRelay publishes the events committed to the poll_events table to every consumer.
+// An event is only marked as delivered after all consumers acknowledged it,
+// consumers that already succeeded are skipped when a delivery is retried.
+type OutboxRelay struct {
+	db        *sql.DB
+	consumers []EventConsumer
+}
+
+func NewOutboxRelay(db *sql.DB, consumers ...EventConsumer) *OutboxRelay {
+	return &OutboxRelay{db: db, consumers: consumers}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(OUTBOX_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				delivered, err := r.relayBatch(ctx)
+				if err != nil || delivered < OUTBOX_BATCH_SIZE {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	events, err := ClaimPollEvents(ctx, r.db, OUTBOX_BATCH_SIZE)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		r.deliver(ctx, event)
+	}
+
+	return len(events), nil
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, event PollEvent) {
+	consumed, err := ListPollEventDeliveries(ctx, r.db, event.ID)
+	if err != nil {
+		return
+	}
+
+	var deliveryErr error
+	for _, consumer := range r.consumers {
+		if slices.Contains(consumed, consumer.Name()) {
+			continue
+		}
+
+		err := consumer.Consume(ctx, event)
+		if err != nil {
+			logger.Warn("failed to deliver poll event",
+				zap.Int64("eventID", event.ID), zap.String("consumer", consumer.Name()), zap.Error(err))
+			deliveryErr = err
+			continue
+		}
+
+		if err := MarkPollEventConsumed(ctx, r.db, event.ID, consumer.Name()); err != nil {
+			deliveryErr = err
+		}
+	}
+
+	if deliveryErr != nil {
+		RetryPollEvent(ctx, r.db, event.ID, outboxBackoff(event.Attempts), deliveryErr.Error())
+		return
+	}
+
+	MarkPollEventDelivered(ctx, r.db, event.ID)
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 12 {
+		return OUTBOX_MAX_BACKOFF
+	}
+
+	backoff := time.Duration(1<<(attempts-1)) * time.Second
+	if backoff > OUTBOX_MAX_BACKOFF {
+		return OUTBOX_MAX_BACKOFF
+	}
+
+	return backoff
+}
+
+// WebhookConsumer posts every event as JSON to a fixed URL.
+// When a secret is configured the body is signed with HMAC-SHA256 in the X-Roodle-Signature header.
+type WebhookConsumer struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func NewWebhookConsumer(url string, secret []byte) *WebhookConsumer {
+	return &WebhookConsumer{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *WebhookConsumer) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookConsumer) Consume(ctx context.Context, event PollEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Roodle-Event", event.Type)
+	req.Header.Set("X-Roodle-Event-ID", strconv.FormatInt(event.ID, 10))
+	if len(w.secret) > 0 {
+		req.Header.Set("X-Roodle-Signature", "sha256="+signWebhookBody(w.secret, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func signWebhookBody(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
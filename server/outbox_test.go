@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	testCases := map[int]time.Duration{
+		0:   1 * time.Second,
+		1:   1 * time.Second,
+		2:   2 * time.Second,
+		5:   16 * time.Second,
+		13:  OUTBOX_MAX_BACKOFF,
+		100: OUTBOX_MAX_BACKOFF,
+	}
+
+	for attempts, expected := range testCases {
+		if backoff := outboxBackoff(attempts); backoff != expected {
+			t.Errorf("Expected backoff %s for %d attempts, but got %s", expected, attempts, backoff)
+		}
+	}
+}
+
+func TestWebhookConsumer(t *testing.T) {
+	secret := []byte("secret")
+	event := PollEvent{ID: 42, PollID: "abc", Type: PollCreatedEvent, Payload: json.RawMessage(`{"poll":{}}`)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if r.Header.Get("X-Roodle-Event-ID") != "42" {
+			t.Errorf("Expected event id header 42, but got %s", r.Header.Get("X-Roodle-Event-ID"))
+		}
+		if r.Header.Get("X-Roodle-Signature") != "sha256="+signWebhookBody(secret, body) {
+			t.Errorf("Invalid webhook signature %s", r.Header.Get("X-Roodle-Signature"))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := NewWebhookConsumer(server.URL, secret).Consume(context.Background(), event)
+	if err != nil {
+		t.Errorf("Expected webhook delivery to succeed, but got %s", err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	err = NewWebhookConsumer(failing.URL, secret).Consume(context.Background(), event)
+	if err == nil {
+		t.Errorf("Expected webhook delivery to fail")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// PDF_A4_WIDTH and PDF_A4_HEIGHT are the A4 page size in points.
+	PDF_A4_WIDTH  = 595.0
+	PDF_A4_HEIGHT = 842.0
+)
+
+var (
+	// helveticaWidths are the widths of the printable ASCII characters in Helvetica, in thousandths of the font size.
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+)
+
+// PDFColor is an RGB color with components between 0 and 1.
+type PDFColor struct {
+	R, G, B float64
+}
+
+// PDFDocument writes simple documents with text, lines and filled rectangles using the standard Helvetica fonts,
+// so no font has to be embedded. The coordinates are in points from the bottom left corner of the page.
+type PDFDocument struct {
+	Width  float64
+	Height float64
+	pages  []*bytes.Buffer
+	page   int
+}
+
+func NewPDFDocument(width float64, height float64) *PDFDocument {
+	return &PDFDocument{Width: width, Height: height}
+}
+
+// AddPage starts a new page, the next drawings go to it.
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.page = len(d.pages) - 1
+}
+
+// PageCount returns the number of pages added so far.
+func (d *PDFDocument) PageCount() int {
+	return len(d.pages)
+}
+
+// SetPage makes the drawings go to an existing page, numbered from one.
+func (d *PDFDocument) SetPage(page int) {
+	d.page = page - 1
+}
+
+func (d *PDFDocument) current() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	return d.pages[d.page]
+}
+
+// Text writes the text with its baseline starting at x and y.
+func (d *PDFDocument) Text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	fmt.Fprintf(d.current(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfString(text))
+}
+
+// Line strokes a line between the two points.
+func (d *PDFDocument) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(d.current(), "%s w %s %s m %s %s l S\n", pdfNumber(width), pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+// FillRect fills a rectangle with its bottom left corner at x and y.
+func (d *PDFDocument) FillRect(x float64, y float64, width float64, height float64, color PDFColor) {
+	fmt.Fprintf(d.current(), "q %s %s %s rg %s %s %s %s re f Q\n",
+		pdfNumber(color.R), pdfNumber(color.G), pdfNumber(color.B), pdfNumber(x), pdfNumber(y), pdfNumber(width), pdfNumber(height))
+}
+
+// Bytes returns the document, with the pages sharing the fonts.
+func (d *PDFDocument) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	out := &bytes.Buffer{}
+	offsets := []int{}
+	object := func(content string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// The catalog, the page tree and the fonts come first, then each page is followed by its content stream.
+	kids := []string{}
+	for idx := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+idx*2))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for idx, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(d.Width), pdfNumber(d.Height), 6+idx*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// PDFTextWidth returns the width of the text in Helvetica, bold text is measured as regular text.
+func PDFTextWidth(text string, size float64) float64 {
+	width := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			width += helveticaWidths[r-32]
+		} else {
+			width += 556
+		}
+	}
+
+	return float64(width) * size / 1000
+}
+
+// PDFTruncate shortens the text with an ellipsis so it fits the width.
+func PDFTruncate(text string, size float64, width float64) string {
+	if PDFTextWidth(text, size) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && PDFTextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// PDFWrap splits the text in lines fitting the width, breaking between words.
+func PDFWrap(text string, size float64, width float64) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && PDFTextWidth(line+" "+word, size) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line == "" {
+				line = PDFTruncate(word, size, width)
+			} else {
+				line += " " + word
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func pdfNumber(value float64) string {
+	formatted := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+	if formatted == "" || formatted == "-0" {
+		return "0"
+	}
+
+	return formatted
+}
+
+// pdfString encodes the text for the WinAnsi encoding of the standard fonts, characters outside Latin-1 become "?".
+func pdfString(text string) string {
+	escaped := &strings.Builder{}
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r >= 32 && r <= 126:
+			escaped.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(escaped, "\\%03o", r)
+		case r == utf8.RuneError || r < 32:
+			escaped.WriteByte(' ')
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+
+	return escaped.String()
+}
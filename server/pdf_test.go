@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPDFString(t *testing.T) {
+	testCases := map[string]string{
+		"Team lunch":    "Team lunch",
+		`(a\b)`:         `\(a\\b\)`,
+		"Café":          `Caf\351`,
+		"Lunch 🍕":       "Lunch ?",
+		"Line\nbreak":   "Line break",
+		"Zürich – Bern": `Z\374rich ? Bern`,
+	}
+
+	for text, expected := range testCases {
+		if escaped := pdfString(text); escaped != expected {
+			t.Errorf("Expected %q for %q, but got %q", expected, text, escaped)
+		}
+	}
+}
+
+func TestPDFNumber(t *testing.T) {
+	testCases := map[float64]string{
+		0:       "0",
+		12:      "12",
+		12.5:    "12.5",
+		0.333:   "0.33",
+		-0.001:  "0",
+		-40.25:  "-40.25",
+		841.999: "842",
+	}
+
+	for value, expected := range testCases {
+		if formatted := pdfNumber(value); formatted != expected {
+			t.Errorf("Expected %q for %v, but got %q", expected, value, formatted)
+		}
+	}
+}
+
+func TestPDFWrap(t *testing.T) {
+	width := PDFTextWidth("Lunch at the", 10)
+	lines := PDFWrap("Lunch at the new place\n\nnear the office", 10, width)
+	expected := []string{"Lunch at the", "new place", "", "near the", "office"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected lines %q, but got %q", expected, lines)
+	}
+
+	truncated := PDFTruncate("Supercalifragilistic", 10, PDFTextWidth("Super...", 10))
+	if truncated != "Super..." {
+		t.Errorf("Expected the text to be truncated to Super..., but got %q", truncated)
+	}
+	if text := PDFTruncate("Short", 10, 100); text != "Short" {
+		t.Errorf("Expected the text to be kept, but got %q", text)
+	}
+}
+
+func TestPDFDocumentBytes(t *testing.T) {
+	doc := NewPDFDocument(PDF_A4_WIDTH, PDF_A4_HEIGHT)
+	doc.AddPage()
+	doc.Text(10, 10, 12, false, "first")
+	doc.AddPage()
+	doc.SetPage(1)
+	doc.Text(10, 20, 12, true, "again")
+	pdf := doc.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("Expected a PDF header and trailer, but got %q", pdf)
+	}
+	if !bytes.Contains(pdf, []byte("/Kids [5 0 R 7 0 R] /Count 2")) {
+		t.Errorf("Expected two pages in the page tree")
+	}
+
+	// Every cross reference entry has to point at the start of its object.
+	xref := bytes.Index(pdf, []byte("\nxref\n")) + 1
+	entries := strings.Split(string(pdf[xref:]), "\n")[3:9]
+	for idx, entry := range entries {
+		var offset int
+		fmt.Sscanf(entry, "%d", &offset)
+		if prefix := fmt.Sprintf("%d 0 obj", idx+1); !bytes.HasPrefix(pdf[offset:], []byte(prefix)) {
+			t.Errorf("Expected object %d at offset %d", idx+1, offset)
+		}
+	}
+
+	first := bytes.Index(pdf, []byte("6 0 obj"))
+	second := bytes.Index(pdf, []byte("8 0 obj"))
+	if !bytes.Contains(pdf[first:second], []byte("(first)")) || !bytes.Contains(pdf[first:second], []byte("/F2 12 Tf 10 20 Td (again)")) {
+		t.Errorf("Expected the texts on the first page")
+	}
+}
+
+func TestPollSummaryPDF(t *testing.T) {
+	poll := Poll{ID: "abc", FinalOptionID: "o1", PollBase: PollBase{Title: "Team (lunch)"}}
+	for idx := 0; idx < 8; idx++ {
+		start := time.Date(2024, 7, 1+idx, 12, 0, 0, 0, time.UTC)
+		poll.Options = append(poll.Options, PollOption{ID: fmt.Sprintf("o%d", idx), Start: start, End: start.Add(time.Hour)})
+	}
+	votes := []PollAccountAvailability{}
+	for idx := 0; idx < 40; idx++ {
+		votes = append(votes, PollAccountAvailability{
+			AccountEmail:   fmt.Sprintf("participant%d@example.com", idx),
+			Availabilities: []OptionAvailability{{OptionID: "o1", Answer: Available}},
+		})
+	}
+
+	pdf := PollSummaryPDF(poll, Account{Email: "owner@example.com"}, NewPollExport(poll, votes, time.UTC), time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC))
+
+	for _, expected := range []string{"(Team \\(lunch\\))", "(owner@example.com)", "(Finalized on 2024-07-02 12:00 - 13:00)", "(participant39@example.com)", "(Total Yes)"} {
+		if !bytes.Contains(pdf, []byte(expected)) {
+			t.Errorf("Expected the summary to contain %s", expected)
+		}
+	}
+	// The eight options do not fit one table and forty participants do not fit one page.
+	if count := bytes.Count(pdf, []byte("(Wed 03 Jul 2024)")); count < 2 {
+		t.Errorf("Expected the table header to be repeated, but got it %d times", count)
+	}
+	if !bytes.Contains(pdf, []byte("(Page 1 of ")) {
+		t.Errorf("Expected page numbers in the footer")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	POLL_LIST_PAGE_SIZE         = 50
+	POLL_LIST_MAX_PAGE_SIZE     = 200
+	POLL_LIST_SEARCH_MAX_LENGTH = 200
+
+	POLL_LIST_ROLE_OWNER       = "owner"
+	POLL_LIST_ROLE_PARTICIPANT = "participant"
+	POLL_LIST_ROLE_INVITED     = "invited"
+
+	POLL_LIST_STATE_OPEN      = "open"
+	POLL_LIST_STATE_FINALIZED = "finalized"
+
+	POLL_LIST_SORT_CREATED = "created"
+	POLL_LIST_SORT_TITLE   = "title"
+	POLL_LIST_SORT_START   = "start"
+)
+
+var (
+	pollListRoles  = []string{POLL_LIST_ROLE_OWNER, POLL_LIST_ROLE_PARTICIPANT, POLL_LIST_ROLE_INVITED}
+	pollListStates = []string{POLL_LIST_STATE_OPEN, POLL_LIST_STATE_FINALIZED}
+	pollListSorts  = []string{POLL_LIST_SORT_CREATED, POLL_LIST_SORT_TITLE, POLL_LIST_SORT_START}
+)
+
+// PollListQuery filters and pages the polls of an account.
+// Owners see their polls, participants the polls they organize as members or voted in, and invitees the polls they did not answer yet.
+// From and To keep the polls with options overlapping the range, and the page continues after the poll of the cursor in the sort order.
+type PollListQuery struct {
+	Role     string
+	State    string
+	From     time.Time
+	To       time.Time
+	Search   string
+	Sort     string
+	AfterKey string
+	AfterID  string
+	Limit    int
+}
+
+// pollListCursor is the position of the last poll of a page, encoded so clients treat it as opaque.
+type pollListCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+// parsePollListTime accepts a full timestamp or a date, the start of that day in UTC.
+func parsePollListTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(HOLIDAY_DATE_FORMAT, value)
+}
+
+func parsePollListQuery(query url.Values) (PollListQuery, error) {
+	result := PollListQuery{
+		Role:   query.Get("role"),
+		State:  query.Get("state"),
+		Search: strings.TrimSpace(query.Get("q")),
+		Sort:   query.Get("sort"),
+		Limit:  POLL_LIST_PAGE_SIZE,
+	}
+
+	if result.Role != "" && !slices.Contains(pollListRoles, result.Role) {
+		return PollListQuery{}, fmt.Errorf("invalid parameter role, use one of %s", strings.Join(pollListRoles, ", "))
+	}
+	if result.State != "" && !slices.Contains(pollListStates, result.State) {
+		return PollListQuery{}, fmt.Errorf("invalid parameter state, use one of %s", strings.Join(pollListStates, ", "))
+	}
+	if result.Sort == "" {
+		result.Sort = POLL_LIST_SORT_CREATED
+	}
+	if !slices.Contains(pollListSorts, result.Sort) {
+		return PollListQuery{}, fmt.Errorf("invalid parameter sort, use one of %s", strings.Join(pollListSorts, ", "))
+	}
+	if utf8.RuneCountInString(result.Search) > POLL_LIST_SEARCH_MAX_LENGTH {
+		return PollListQuery{}, fmt.Errorf("invalid parameter q, the maximum is %d characters", POLL_LIST_SEARCH_MAX_LENGTH)
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &result.From}, {"to", &result.To}} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := parsePollListTime(value)
+			if err != nil {
+				return PollListQuery{}, fmt.Errorf("invalid parameter %s", param.name)
+			}
+			*param.value = parsed
+		}
+	}
+	if !result.From.IsZero() && !result.To.IsZero() && !result.From.Before(result.To) {
+		return PollListQuery{}, fmt.Errorf("invalid date range, from must be before to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > POLL_LIST_MAX_PAGE_SIZE {
+			return PollListQuery{}, fmt.Errorf("invalid parameter limit")
+		}
+		result.Limit = parsed
+	}
+
+	if value := query.Get("after"); value != "" {
+		cursor, err := decodePollListCursor(value)
+		if err != nil || cursor.Sort != result.Sort || cursor.ID == "" {
+			return PollListQuery{}, fmt.Errorf("invalid parameter after")
+		}
+		result.AfterKey = cursor.Key
+		result.AfterID = cursor.ID
+	}
+
+	return result, nil
+}
+
+// Cursor returns the cursor of the page after the poll, with the key it is sorted by.
+func (q PollListQuery) Cursor(summary PollSummary) string {
+	var key string
+	switch q.Sort {
+	case POLL_LIST_SORT_TITLE:
+		key = summary.SortTitle
+	case POLL_LIST_SORT_START:
+		startsAt := summary.CreatedAt
+		if summary.StartsAt != nil {
+			startsAt = *summary.StartsAt
+		}
+		key = startsAt.Format(time.RFC3339Nano)
+	default:
+		key = summary.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	encoded, _ := json.Marshal(pollListCursor{Sort: q.Sort, Key: key, ID: summary.ID})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodePollListCursor(value string) (pollListCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return pollListCursor{}, err
+	}
+
+	cursor := pollListCursor{}
+	err = json.Unmarshal(decoded, &cursor)
+	return cursor, err
+}
+
+func (a *APIServer) listPolls(ctx *gin.Context, accountID int64) {
+	query, err := parsePollListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fetching one more poll than requested tells if there is a next page.
+	limit := query.Limit
+	query.Limit = limit + 1
+	summaries, err := ListPollSummaries(ctx, a.db, accountID, query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	response := gin.H{"data": summaries}
+	if len(summaries) > limit {
+		response["data"] = summaries[:limit]
+		response["next"] = query.Cursor(summaries[limit-1])
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
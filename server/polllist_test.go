@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParsePollListQuery(t *testing.T) {
+	query, err := parsePollListQuery(url.Values{})
+	if err != nil || query.Sort != POLL_LIST_SORT_CREATED || query.Limit != POLL_LIST_PAGE_SIZE || query.AfterID != "" {
+		t.Errorf("Expected the default query, but got %+v (%v)", query, err)
+	}
+
+	query, err = parsePollListQuery(url.Values{
+		"role":  {"invited"},
+		"state": {"open"},
+		"q":     {"  lunch  "},
+		"sort":  {"title"},
+		"from":  {"2024-05-01"},
+		"to":    {"2024-05-02T12:00:00+01:00"},
+		"limit": {"10"},
+	})
+	expectedFrom := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	expectedTo := time.Date(2024, 5, 2, 11, 0, 0, 0, time.UTC)
+	if err != nil || query.Role != POLL_LIST_ROLE_INVITED || query.State != POLL_LIST_STATE_OPEN || query.Search != "lunch" ||
+		query.Sort != POLL_LIST_SORT_TITLE || !query.From.Equal(expectedFrom) || !query.To.Equal(expectedTo) || query.Limit != 10 {
+		t.Errorf("Expected the parsed query, but got %+v (%v)", query, err)
+	}
+
+	testCases := []url.Values{
+		{"role": {"editor"}},
+		{"state": {"closed"}},
+		{"sort": {"votes"}},
+		{"from": {"yesterday"}},
+		{"from": {"2024-05-02"}, "to": {"2024-05-01"}},
+		{"limit": {"0"}},
+		{"limit": {"201"}},
+		{"after": {"not a cursor"}},
+	}
+	for _, values := range testCases {
+		if _, err := parsePollListQuery(values); err == nil {
+			t.Errorf("Expected %v to be invalid", values)
+		}
+	}
+}
+
+func TestPollListCursor(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 123000, time.UTC)
+	startsAt := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	summary := PollSummary{ID: "abc", Title: "Team Lunch", SortTitle: "team lunch", CreatedAt: createdAt}
+
+	testCases := []struct {
+		sort     string
+		summary  PollSummary
+		expected string
+	}{
+		{POLL_LIST_SORT_CREATED, summary, "2024-05-01T10:30:00.000123Z"},
+		{POLL_LIST_SORT_TITLE, summary, "team lunch"},
+		{POLL_LIST_SORT_START, summary, "2024-05-01T10:30:00.000123Z"},
+		{POLL_LIST_SORT_START, PollSummary{ID: "abc", CreatedAt: createdAt, StartsAt: &startsAt}, "2024-06-01T09:00:00Z"},
+	}
+	for _, testCase := range testCases {
+		cursor := PollListQuery{Sort: testCase.sort}.Cursor(testCase.summary)
+
+		query, err := parsePollListQuery(url.Values{"sort": {testCase.sort}, "after": {cursor}})
+		if err != nil || query.AfterKey != testCase.expected || query.AfterID != "abc" {
+			t.Errorf("Expected cursor after %s and abc, but got %+v (%v)", testCase.expected, query, err)
+		}
+	}
+
+	cursor := PollListQuery{Sort: POLL_LIST_SORT_TITLE}.Cursor(summary)
+	if _, err := parsePollListQuery(url.Values{"after": {cursor}}); err == nil {
+		t.Errorf("Expected a cursor of another sort to be invalid")
+	}
+}
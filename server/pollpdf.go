@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	POLL_PDF_MARGIN        = 40.0
+	POLL_PDF_NAME_WIDTH    = 170.0
+	POLL_PDF_OPTION_WIDTH  = 88.0
+	POLL_PDF_ROW_HEIGHT    = 16.0
+	POLL_PDF_HEADER_HEIGHT = 30.0
+	POLL_PDF_FONT_SIZE     = 9.0
+)
+
+var (
+	pollPDFAnswerColors = map[OptionAnswer]PDFColor{
+		Available:   {0.78, 0.92, 0.78},
+		Maybe:       {1, 0.93, 0.7},
+		Unavailable: {0.96, 0.82, 0.82},
+	}
+	pollPDFAnswerLabels = map[OptionAnswer]string{Available: "Yes", Maybe: "If need be", Unavailable: "No"}
+	pollPDFHeaderColor  = PDFColor{0.9, 0.9, 0.9}
+	pollPDFFinalColor   = PDFColor{0.75, 0.85, 1}
+)
+
+// pollPDF lays out a poll summary on landscape A4 pages, moving to a new page when the next row does not fit.
+type pollPDF struct {
+	doc *PDFDocument
+	y   float64
+}
+
+func (p *pollPDF) newPage() {
+	p.doc.AddPage()
+	p.y = p.doc.Height - POLL_PDF_MARGIN
+}
+
+// reserve moves to a new page unless the height fits above the bottom margin.
+func (p *pollPDF) reserve(height float64) {
+	if p.y-height < POLL_PDF_MARGIN {
+		p.newPage()
+	}
+}
+
+func (p *pollPDF) line(label string, value string) {
+	width := p.doc.Width - 2*POLL_PDF_MARGIN - 90
+	for idx, text := range PDFWrap(value, POLL_PDF_FONT_SIZE+1, width) {
+		p.reserve(14)
+		if idx == 0 {
+			p.doc.Text(POLL_PDF_MARGIN, p.y-10, POLL_PDF_FONT_SIZE+1, true, label)
+		}
+		p.doc.Text(POLL_PDF_MARGIN+90, p.y-10, POLL_PDF_FONT_SIZE+1, false, text)
+		p.y -= 14
+	}
+}
+
+// PollSummaryPDF renders the poll details, the answers of each participant, the totals and the final option.
+// The options are split in several tables when they do not fit the page width.
+func PollSummaryPDF(poll Poll, organizer Account, export PollExport, now time.Time) []byte {
+	p := &pollPDF{doc: NewPDFDocument(PDF_A4_HEIGHT, PDF_A4_WIDTH)}
+	p.newPage()
+
+	title := poll.Title
+	if title == "" {
+		title = poll.ID
+	}
+	for _, text := range PDFWrap(title, 18, p.doc.Width-2*POLL_PDF_MARGIN) {
+		p.doc.Text(POLL_PDF_MARGIN, p.y-18, 18, true, text)
+		p.y -= 24
+	}
+	p.y -= 4
+
+	if poll.Description != "" {
+		p.line("Description", poll.Description)
+	}
+	if poll.Location != "" {
+		p.line("Location", poll.Location)
+	}
+	p.line("Organizer", organizer.Email)
+	status := "Open"
+	for _, option := range export.Options {
+		if option.ID == poll.FinalOptionID {
+			status = "Finalized on " + option.Label
+		}
+	}
+	p.line("Status", status)
+	p.line("Participants", strconv.Itoa(len(export.Participants)))
+	p.line("Time zone", export.TimeZone)
+	p.line("Generated", now.Format("2006-01-02 15:04 MST"))
+	p.y -= 10
+
+	perTable := int((p.doc.Width - 2*POLL_PDF_MARGIN - POLL_PDF_NAME_WIDTH) / POLL_PDF_OPTION_WIDTH)
+	for first := 0; first < len(export.Options); first += perTable {
+		last := min(first+perTable, len(export.Options))
+		p.table(poll, export, first, last)
+		p.y -= 16
+	}
+
+	pages := p.doc.PageCount()
+	for page := 1; page <= pages; page++ {
+		p.doc.SetPage(page)
+		footer := fmt.Sprintf("Page %d of %d", page, pages)
+		p.doc.Text(p.doc.Width-POLL_PDF_MARGIN-PDFTextWidth(footer, 8), POLL_PDF_MARGIN/2, 8, false, footer)
+	}
+
+	return p.doc.Bytes()
+}
+
+// table draws the options between first and last, the header is repeated on every page the table spans.
+func (p *pollPDF) table(poll Poll, export PollExport, first int, last int) {
+	options := export.Options[first:last]
+	totals := export.Totals[first:last]
+	optionX := func(idx int) float64 {
+		return POLL_PDF_MARGIN + POLL_PDF_NAME_WIDTH + float64(idx)*POLL_PDF_OPTION_WIDTH
+	}
+	tableWidth := POLL_PDF_NAME_WIDTH + float64(len(options))*POLL_PDF_OPTION_WIDTH
+
+	header := func() {
+		p.reserve(POLL_PDF_HEADER_HEIGHT + POLL_PDF_ROW_HEIGHT)
+		bottom := p.y - POLL_PDF_HEADER_HEIGHT
+		p.doc.FillRect(POLL_PDF_MARGIN, bottom, tableWidth, POLL_PDF_HEADER_HEIGHT, pollPDFHeaderColor)
+		p.doc.Text(POLL_PDF_MARGIN+4, bottom+11, POLL_PDF_FONT_SIZE, true, "Participant")
+		for idx, option := range options {
+			x := optionX(idx)
+			if option.ID == poll.FinalOptionID {
+				p.doc.FillRect(x, bottom, POLL_PDF_OPTION_WIDTH, POLL_PDF_HEADER_HEIGHT, pollPDFFinalColor)
+			}
+			date := option.Start.Format("Mon 02 Jan 2006")
+			hours := option.Start.Format("15:04") + " - " + option.End.Format("15:04")
+			if option.ID == poll.FinalOptionID {
+				hours += " (final)"
+			}
+			p.doc.Text(x+4, bottom+18, POLL_PDF_FONT_SIZE-1, true, PDFTruncate(date, POLL_PDF_FONT_SIZE-1, POLL_PDF_OPTION_WIDTH-8))
+			p.doc.Text(x+4, bottom+7, POLL_PDF_FONT_SIZE-1, false, PDFTruncate(hours, POLL_PDF_FONT_SIZE-1, POLL_PDF_OPTION_WIDTH-8))
+		}
+		p.y = bottom
+	}
+
+	row := func(name string, bold bool, cells func(idx int) (string, *PDFColor)) {
+		if p.y-POLL_PDF_ROW_HEIGHT < POLL_PDF_MARGIN {
+			p.newPage()
+			header()
+		}
+		bottom := p.y - POLL_PDF_ROW_HEIGHT
+		p.doc.Text(POLL_PDF_MARGIN+4, bottom+5, POLL_PDF_FONT_SIZE, bold, PDFTruncate(name, POLL_PDF_FONT_SIZE, POLL_PDF_NAME_WIDTH-8))
+		for idx := range options {
+			text, color := cells(idx)
+			if color != nil {
+				p.doc.FillRect(optionX(idx), bottom, POLL_PDF_OPTION_WIDTH, POLL_PDF_ROW_HEIGHT, *color)
+			}
+			p.doc.Text(optionX(idx)+4, bottom+5, POLL_PDF_FONT_SIZE, bold, text)
+		}
+		p.doc.Line(POLL_PDF_MARGIN, bottom, POLL_PDF_MARGIN+tableWidth, bottom, 0.25)
+		p.y = bottom
+	}
+
+	header()
+	for _, participant := range export.Participants {
+		name := participant.Name
+		if participant.RecordedBy != "" {
+			name += " (recorded)"
+		}
+		if participant.AutoFilled {
+			name += " (auto-filled)"
+		}
+		row(name, false, func(idx int) (string, *PDFColor) {
+			answer, ok := participant.Answers[options[idx].ID]
+			if !ok {
+				return "", nil
+			}
+			color := pollPDFAnswerColors[answer]
+			return pollPDFAnswerLabels[answer], &color
+		})
+	}
+	for _, answer := range AllOptionAnswer {
+		row("Total "+pollPDFAnswerLabels[answer], true, func(idx int) (string, *PDFColor) {
+			return strconv.Itoa(totals[idx].Counts[answer]), nil
+		})
+	}
+}
+
+// getPollPDF downloads a printable summary of the poll, the "tz" parameter sets the time zone of the option times.
+func (a *APIServer) getPollPDF(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	location, ok := exportLocation(ctx)
+	if !ok {
+		return
+	}
+
+	organizer, err := GetAccountByID(ctx, a.db, poll.AccountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	votes, err := ListVotes(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	pdf := PollSummaryPDF(poll, organizer, NewPollExport(poll, votes, location), time.Now().In(location))
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, poll.ID))
+	ctx.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slices"
+)
+
+var profileWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// rankedAnswers orders the answers from the least to the most available.
+var rankedAnswers = []OptionAnswer{Unavailable, Maybe, Available}
+
+// parseClock parses "15:04" into minutes since midnight, "24:00" is accepted as the end of the day.
+func parseClock(value string) (int, error) {
+	hours, minutes, found := strings.Cut(value, ":")
+	if !found {
+		return 0, fmt.Errorf("invalid time %s", value)
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s", value)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || len(minutes) != 2 || m < 0 || m > 59 || h < 0 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %s", value)
+	}
+
+	return h*60 + m, nil
+}
+
+func (p AvailabilityProfile) Validate() error {
+	if _, err := time.LoadLocation(p.TimeZone); err != nil || p.TimeZone == "" {
+		return fmt.Errorf("invalid time zone %s", p.TimeZone)
+	}
+
+	for _, rule := range p.Rules {
+		if len(rule.Days) == 0 {
+			return fmt.Errorf("missing days")
+		}
+		for _, day := range rule.Days {
+			if _, ok := profileWeekdays[day]; !ok {
+				return fmt.Errorf("invalid day %s", day)
+			}
+		}
+
+		start, err := parseClock(rule.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(rule.End)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return fmt.Errorf("end %s must be after start %s", rule.End, rule.Start)
+		}
+
+		if !slices.Contains(AllOptionAnswer, rule.Answer) {
+			return fmt.Errorf("invalid answer %s", rule.Answer)
+		}
+	}
+
+	return nil
+}
+
+type profileInterval struct {
+	start time.Time
+	end   time.Time
+	rank  int
+}
+
+// Suggest answers each option with the worst answer over its duration, where each moment gets the best answer
+// of the rules covering it and moments outside every rule are unavailable.
+func (p AvailabilityProfile) Suggest(options []PollOption) []OptionAvailability {
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	availabilities := []OptionAvailability{}
+	for _, option := range options {
+		availabilities = append(availabilities, OptionAvailability{OptionID: option.ID, Answer: p.answer(option, location)})
+	}
+
+	return availabilities
+}
+
+func (p AvailabilityProfile) answer(option PollOption, location *time.Location) OptionAnswer {
+	start := option.Start.In(location)
+	end := option.End.In(location)
+
+	intervals := []profileInterval{}
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location); !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, rule := range p.Rules {
+			if !slices.ContainsFunc(rule.Days, func(d string) bool { return profileWeekdays[d] == day.Weekday() }) {
+				continue
+			}
+			ruleStart, _ := parseClock(rule.Start)
+			ruleEnd, _ := parseClock(rule.End)
+			intervals = append(intervals, profileInterval{
+				start: time.Date(day.Year(), day.Month(), day.Day(), ruleStart/60, ruleStart%60, 0, 0, location),
+				end:   time.Date(day.Year(), day.Month(), day.Day(), ruleEnd/60, ruleEnd%60, 0, 0, location),
+				rank:  slices.Index(rankedAnswers, rule.Answer),
+			})
+		}
+	}
+
+	boundaries := []time.Time{start}
+	for _, interval := range intervals {
+		for _, edge := range []time.Time{interval.start, interval.end} {
+			if edge.After(start) && edge.Before(end) {
+				boundaries = append(boundaries, edge)
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+
+	// Each segment between boundaries is covered by the same rules, so checking its start is enough.
+	worst := len(rankedAnswers) - 1
+	for _, boundary := range boundaries {
+		best := 0
+		for _, interval := range intervals {
+			if !boundary.Before(interval.start) && boundary.Before(interval.end) && interval.rank > best {
+				best = interval.rank
+			}
+		}
+		if best < worst {
+			worst = best
+		}
+	}
+
+	return rankedAnswers[worst]
+}
+
+func (a *APIServer) getAvailabilityProfile(ctx *gin.Context, accountID int64) {
+	profile, found, err := GetAvailabilityProfile(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !found {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "availability profile not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+func (a *APIServer) setAvailabilityProfile(ctx *gin.Context, accountID int64) {
+	profile := AvailabilityProfile{}
+	err := readBody(ctx, &profile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := profile.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = SetAvailabilityProfile(ctx, a.db, accountID, profile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+func (a *APIServer) deleteAvailabilityProfile(ctx *gin.Context, accountID int64) {
+	err := DeleteAvailabilityProfile(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+// profileVote suggests a vote from the availability profile of the account, like importVoteICS.
+func (a *APIServer) profileVote(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getVisiblePoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	profile, found, err := GetAvailabilityProfile(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !found {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "availability profile not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"vote": PollAccountAvailability{
+			PollID:         poll.ID,
+			AccountID:      accountID,
+			Availabilities: profile.Suggest(poll.Options),
+		},
+	}})
+}
+
+// autoFillVote submits the vote suggested by the availability profile as a provisional vote, when the account
+// opted in for it and has not voted yet. Auto-filled votes are left out of the results until the account confirms them.
+func (a *APIServer) autoFillVote(ctx *gin.Context, accountID int64) {
+	pollID, err := getPathParam(ctx, "id")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return
+	}
+
+	poll, answers, ok := a.getPollAnswers(ctx, pollID, accountID)
+	if !ok {
+		return
+	}
+	if poll.FinalOptionID != "" {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "poll is finalized"})
+		return
+	}
+
+	profile, found, err := GetAvailabilityProfile(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !found || !profile.AutoSubmit {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "availability profile not found"})
+		return
+	}
+
+	// Answers outside the answer scale of the poll fall back to unavailable.
+	availabilities := profile.Suggest(poll.Options)
+	for idx, availability := range availabilities {
+		if !slices.Contains(answers, availability.Answer) {
+			availabilities[idx].Answer = Unavailable
+		}
+	}
+
+	created, err := AutoFillVote(ctx, a.db, PollAccountAvailability{
+		PollID:         poll.ID,
+		AccountID:      accountID,
+		Availabilities: availabilities,
+	})
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !created {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "poll already voted"})
+		return
+	}
+
+	vote, err := GetVote(ctx, a.db, accountID, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": vote})
+}
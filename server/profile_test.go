@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAvailabilityProfileSuggest(t *testing.T) {
+	profile := AvailabilityProfile{
+		TimeZone: "Europe/Lisbon",
+		Rules: []AvailabilityRule{
+			{Days: []string{"mon", "tue", "wed", "thu"}, Start: "09:00", End: "17:00", Answer: Available},
+			{Days: []string{"fri"}, Start: "09:00", End: "17:00", Answer: Maybe},
+			{Days: []string{"thu"}, Start: "17:00", End: "24:00", Answer: Maybe},
+		},
+	}
+	if err := profile.Validate(); err != nil {
+		t.Fatalf("Expected profile to be valid, but got %s", err)
+	}
+
+	option := func(id string, start string, duration time.Duration) PollOption {
+		s, _ := time.Parse(time.RFC3339, start)
+		return PollOption{ID: id, Start: s, End: s.Add(duration)}
+	}
+	options := []PollOption{
+		// 10:00 to 11:00 in Lisbon (summer time).
+		option("monday", "2024-05-06T09:00:00Z", time.Hour),
+		option("monday-evening", "2024-05-06T16:30:00Z", time.Hour),
+		option("thursday-evening", "2024-05-09T15:30:00Z", 2*time.Hour),
+		option("friday", "2024-05-10T08:00:00Z", time.Hour),
+		option("saturday", "2024-05-11T10:00:00Z", time.Hour),
+		// 09:00 to 10:00 in Lisbon (winter time).
+		option("winter-monday", "2024-12-02T09:00:00Z", time.Hour),
+	}
+
+	expected := []OptionAvailability{
+		{"monday", Available},
+		{"monday-evening", Unavailable},
+		{"thursday-evening", Maybe},
+		{"friday", Maybe},
+		{"saturday", Unavailable},
+		{"winter-monday", Available},
+	}
+	if availabilities := profile.Suggest(options); !reflect.DeepEqual(availabilities, expected) {
+		t.Errorf("Expected %v, but got %v", expected, availabilities)
+	}
+}
+
+func TestAvailabilityProfileValidate(t *testing.T) {
+	testCases := []AvailabilityProfile{
+		{TimeZone: "Mars/Olympus"},
+		{TimeZone: "UTC", Rules: []AvailabilityRule{{Days: []string{"monday"}, Start: "09:00", End: "17:00", Answer: Available}}},
+		{TimeZone: "UTC", Rules: []AvailabilityRule{{Days: []string{"mon"}, Start: "17:00", End: "09:00", Answer: Available}}},
+		{TimeZone: "UTC", Rules: []AvailabilityRule{{Days: []string{"mon"}, Start: "9", End: "24:30", Answer: Available}}},
+		{TimeZone: "UTC", Rules: []AvailabilityRule{{Days: []string{"mon"}, Start: "09:00", End: "17:00", Answer: "busy"}}},
+	}
+
+	for _, profile := range testCases {
+		if err := profile.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", profile)
+		}
+	}
+}
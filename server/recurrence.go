@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Upper bound of generated periods, protects against rules that never match.
+	RECURRENCE_MAX_ITERATIONS = 10000
+)
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+type weekdayRule struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+// RecurrenceRule is the subset of RFC 5545 RRULE used by calendar exports:
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH.
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []weekdayRule
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+func ParseRecurrenceRule(value string, location *time.Location) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return RecurrenceRule{}, fmt.Errorf("invalid INTERVAL %s", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return RecurrenceRule{}, fmt.Errorf("invalid COUNT %s", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, _, err := parseICalTimeValue(val, false, location)
+			if err != nil {
+				return RecurrenceRule{}, fmt.Errorf("invalid UNTIL %s", val)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				day = strings.ToUpper(strings.TrimSpace(day))
+				if len(day) < 2 {
+					return RecurrenceRule{}, fmt.Errorf("invalid BYDAY %s", val)
+				}
+				weekday, ok := icalWeekdays[day[len(day)-2:]]
+				if !ok {
+					return RecurrenceRule{}, fmt.Errorf("invalid BYDAY %s", val)
+				}
+				ordinal := 0
+				if len(day) > 2 {
+					var err error
+					ordinal, err = strconv.Atoi(day[:len(day)-2])
+					if err != nil {
+						return RecurrenceRule{}, fmt.Errorf("invalid BYDAY %s", val)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, weekdayRule{ordinal: ordinal, weekday: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return RecurrenceRule{}, fmt.Errorf("invalid BYMONTHDAY %s", val)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(val, ",") {
+				monthNumber, err := strconv.Atoi(month)
+				if err != nil || monthNumber < 1 || monthNumber > 12 {
+					return RecurrenceRule{}, fmt.Errorf("invalid BYMONTH %s", val)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(monthNumber))
+			}
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return RecurrenceRule{}, fmt.Errorf("unsupported FREQ %s", rule.Freq)
+	}
+
+	return rule, nil
+}
+
+// Occurrences returns the start of the occurrences that begin in [from, end), the first occurrence being start itself.
+// The periods ending before from are skipped, only expanding them when COUNT needs to know how many occurrences they had.
+func (r RecurrenceRule) Occurrences(start time.Time, from time.Time, end time.Time) []time.Time {
+	occurrences := []time.Time{}
+	emitted := 0
+
+	first := r.firstPeriod(start, from)
+	if r.Count > 0 {
+		for period := 0; period < first; period++ {
+			emitted += len(r.periodOccurrences(start, period))
+			if emitted >= r.Count {
+				return occurrences
+			}
+		}
+	}
+
+	for period := first; period < first+RECURRENCE_MAX_ITERATIONS; period++ {
+		for _, candidate := range r.periodOccurrences(start, period) {
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return occurrences
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return occurrences
+			}
+			if !candidate.Before(end) {
+				return occurrences
+			}
+
+			emitted++
+			if !candidate.Before(from) {
+				occurrences = append(occurrences, candidate)
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// periodOccurrences returns the sorted occurrences of the n-th period, before applying UNTIL and COUNT.
+func (r RecurrenceRule) periodOccurrences(start time.Time, period int) []time.Time {
+	candidates := r.periodCandidates(start, period)
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	occurrences := []time.Time{}
+	for _, candidate := range candidates {
+		if !candidate.Before(start) && r.matches(candidate) {
+			occurrences = append(occurrences, candidate)
+		}
+	}
+
+	return occurrences
+}
+
+// firstPeriod is the first period that can have occurrences at or after from, all the periods before it end before from.
+func (r RecurrenceRule) firstPeriod(start time.Time, from time.Time) int {
+	if !from.After(start) {
+		return 0
+	}
+	from = from.In(start.Location())
+
+	units := 0
+	switch r.Freq {
+	case "DAILY":
+		units = daysBetween(start, from)
+	case "WEEKLY":
+		units = daysBetween(start, from) / 7
+	case "MONTHLY":
+		units = (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	case "YEARLY":
+		units = from.Year() - start.Year()
+	}
+
+	// One period earlier, weeks start on the monday before start and days may be shorter across daylight saving changes.
+	return max(units/r.Interval-1, 0)
+}
+
+func daysBetween(from time.Time, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay) / (24 * time.Hour))
+}
+
+func (r RecurrenceRule) matches(t time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, t.Month()) {
+		return false
+	}
+
+	if r.Freq == "DAILY" {
+		if len(r.ByDay) > 0 && !r.hasWeekday(t.Weekday()) {
+			return false
+		}
+		if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r RecurrenceRule) hasWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// periodCandidates expands the n-th period (day, week, month or year) of the rule.
+func (r RecurrenceRule) periodCandidates(start time.Time, period int) []time.Time {
+	step := period * r.Interval
+	hour, minute, second := start.Clock()
+	location := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, location)
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		return []time.Time{start.AddDate(0, 0, step)}
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			return []time.Time{start.AddDate(0, 0, 7*step)}
+		}
+		// Weeks start on monday (WKST=MO).
+		weekStart := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		candidates := []time.Time{}
+		for _, day := range r.ByDay {
+			offset := (int(day.weekday) + 6) % 7
+			candidates = append(candidates, at(weekStart.Year(), weekStart.Month(), weekStart.Day()+offset))
+		}
+		return candidates
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1, hour, minute, second, 0, location)
+		return r.monthCandidates(month, start.Day())
+	case "YEARLY":
+		year := start.Year() + step
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		candidates := []time.Time{}
+		for _, month := range months {
+			candidates = append(candidates, r.monthCandidates(time.Date(year, month, 1, hour, minute, second, 0, location), start.Day())...)
+		}
+		return candidates
+	}
+
+	return nil
+}
+
+func (r RecurrenceRule) monthCandidates(month time.Time, defaultDay int) []time.Time {
+	daysInMonth := time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	at := func(day int) time.Time {
+		return month.AddDate(0, 0, day-1)
+	}
+
+	candidates := []time.Time{}
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, day := range r.ByMonthDay {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			if day >= 1 && day <= daysInMonth {
+				candidates = append(candidates, at(day))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, rule := range r.ByDay {
+			days := []int{}
+			for day := 1; day <= daysInMonth; day++ {
+				if at(day).Weekday() == rule.weekday {
+					days = append(days, day)
+				}
+			}
+
+			switch {
+			case rule.ordinal == 0:
+				for _, day := range days {
+					candidates = append(candidates, at(day))
+				}
+			case rule.ordinal > 0 && rule.ordinal <= len(days):
+				candidates = append(candidates, at(days[rule.ordinal-1]))
+			case rule.ordinal < 0 && -rule.ordinal <= len(days):
+				candidates = append(candidates, at(days[len(days)+rule.ordinal]))
+			}
+		}
+	default:
+		// Months without the day are skipped, as defined by RFC 5545.
+		if defaultDay <= daysInMonth {
+			candidates = append(candidates, at(defaultDay))
+		}
+	}
+
+	return candidates
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthDay(monthDays []int, t time.Time) bool {
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, day := range monthDays {
+		if day == t.Day() || (day < 0 && daysInMonth+day+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseICalTimeValue parses DATE and DATE-TIME values, floating times are read in the given location.
+func parseICalTimeValue(value string, isDate bool, location *time.Location) (time.Time, bool, error) {
+	if isDate || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, location)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(ICAL_UTC_FORMAT, value)
+		return t, false, err
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	return t, false, err
+}
+
+// parseICalTime parses a DTSTART like property, honouring its TZID and VALUE parameters.
+func parseICalTime(property ICalProperty, floating *time.Location) (time.Time, bool, error) {
+	location := floating
+	if tzid := property.Params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			location = tz
+		}
+	}
+
+	return parseICalTimeValue(property.Value, property.Params["VALUE"] == "DATE", location)
+}
+
+// parseICalDuration parses RFC 5545 durations such as P1W, P1DT2H or -PT15M.
+func parseICalDuration(value string) (time.Duration, error) {
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+
+	var duration time.Duration
+	inTime := false
+	number := ""
+	components := 0
+	for _, char := range value[1:] {
+		switch {
+		case char >= '0' && char <= '9':
+			number += string(char)
+		case char == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %s", value)
+			}
+			number = ""
+			components++
+
+			switch {
+			case char == 'W' && !inTime:
+				duration += time.Duration(n) * 7 * 24 * time.Hour
+			case char == 'D' && !inTime:
+				duration += time.Duration(n) * 24 * time.Hour
+			case char == 'H' && inTime:
+				duration += time.Duration(n) * time.Hour
+			case char == 'M' && inTime:
+				duration += time.Duration(n) * time.Minute
+			case char == 'S' && inTime:
+				duration += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration %s", value)
+			}
+		}
+	}
+	if number != "" || components == 0 {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+
+	if negative {
+		return -duration, nil
+	}
+	return duration, nil
+}
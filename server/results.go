@@ -0,0 +1,74 @@
+package main
+
+import "sort"
+
+// Answer weights used to rank the options, same as the web client.
+var answerScores = map[OptionAnswer]int{
+	Available:   3,
+	Maybe:       1,
+	Unavailable: -3,
+}
+
+type OptionResult struct {
+	Option PollOption                `json:"option"`
+	Counts map[OptionAnswer]int      `json:"counts"`
+	Emails map[OptionAnswer][]string `json:"emails"`
+	Score  int                       `json:"score"`
+}
+
+type PollResults struct {
+	Options      []OptionResult `json:"options"`
+	Participants int            `json:"participants"`
+	AutoFilled   int            `json:"auto_filled"`
+}
+
+// CalculateResults aggregates the votes of a poll per option, keeping the options in the poll order.
+// Auto-filled votes are only counted apart, until their accounts confirm them.
+func CalculateResults(poll Poll, votes []PollAccountAvailability) PollResults {
+	results := PollResults{
+		Options: make([]OptionResult, len(poll.Options)),
+	}
+
+	optionIndex := make(map[string]int, len(poll.Options))
+	for idx, option := range poll.Options {
+		optionIndex[option.ID] = idx
+		results.Options[idx] = OptionResult{
+			Option: option,
+			Counts: map[OptionAnswer]int{Available: 0, Maybe: 0, Unavailable: 0},
+			Emails: map[OptionAnswer][]string{},
+		}
+	}
+
+	for _, vote := range votes {
+		if vote.AutoFilled {
+			results.AutoFilled++
+			continue
+		}
+		results.Participants++
+		for _, availability := range vote.Availabilities {
+			idx, ok := optionIndex[availability.OptionID]
+			if !ok {
+				continue
+			}
+
+			result := &results.Options[idx]
+			result.Counts[availability.Answer]++
+			result.Emails[availability.Answer] = append(result.Emails[availability.Answer], vote.Participant())
+			result.Score += answerScores[availability.Answer]
+		}
+	}
+
+	return results
+}
+
+// Best returns the option results sorted by score, highest first.
+func (r PollResults) Best() []OptionResult {
+	sorted := make([]OptionResult, len(r.Options))
+	copy(sorted, r.Options)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	return sorted
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	SEARCH_PAGE_SIZE        = 20
+	SEARCH_MAX_PAGE_SIZE    = 100
+	SEARCH_QUERY_MAX_LENGTH = 200
+	SEARCH_SNIPPET_LENGTH   = 160
+)
+
+var (
+	// fullTextSearch is set when preparing the database, see supportsFullTextSearch.
+	fullTextSearch bool
+
+	searchTokenPattern = regexp.MustCompile(`-?"[^"]*"?|\S+`)
+)
+
+// PollSearchQuery follows the web search syntax of Postgres, quoted phrases match as a whole and terms starting with a dash are excluded.
+// Terms and Excluded are the parsed query, used to highlight the results and to match them when full-text search is not available.
+type PollSearchQuery struct {
+	Text     string
+	Terms    []string
+	Excluded []string
+	Owner    string
+	State    string
+	Limit    int
+}
+
+func parseSearchTerms(text string) ([]string, []string) {
+	terms := []string{}
+	excluded := []string{}
+	for _, token := range searchTokenPattern.FindAllString(text, -1) {
+		negated := strings.HasPrefix(token, "-")
+		token = strings.TrimPrefix(token, "-")
+		quoted := strings.HasPrefix(token, `"`)
+		token = strings.ToLower(strings.Join(strings.Fields(strings.Trim(token, `"`)), " "))
+		if token == "" || (!quoted && token == "or") {
+			continue
+		}
+
+		if negated {
+			if !slices.Contains(excluded, token) {
+				excluded = append(excluded, token)
+			}
+		} else if !slices.Contains(terms, token) {
+			terms = append(terms, token)
+		}
+	}
+
+	return terms, excluded
+}
+
+func parsePollSearchQuery(query url.Values) (PollSearchQuery, error) {
+	result := PollSearchQuery{
+		Text:  strings.TrimSpace(query.Get("q")),
+		Owner: strings.TrimSpace(query.Get("owner")),
+		State: query.Get("state"),
+		Limit: SEARCH_PAGE_SIZE,
+	}
+
+	if utf8.RuneCountInString(result.Text) > SEARCH_QUERY_MAX_LENGTH {
+		return PollSearchQuery{}, fmt.Errorf("invalid parameter q, the maximum is %d characters", SEARCH_QUERY_MAX_LENGTH)
+	}
+	result.Terms, result.Excluded = parseSearchTerms(result.Text)
+	if len(result.Terms) == 0 {
+		return PollSearchQuery{}, fmt.Errorf("missing search terms")
+	}
+
+	if result.Owner != "" && !strings.Contains(result.Owner, "@") {
+		return PollSearchQuery{}, fmt.Errorf("invalid parameter owner, use the email of the owner")
+	}
+	if result.State != "" && !slices.Contains(pollListStates, result.State) {
+		return PollSearchQuery{}, fmt.Errorf("invalid parameter state, use one of %s", strings.Join(pollListStates, ", "))
+	}
+
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > SEARCH_MAX_PAGE_SIZE {
+			return PollSearchQuery{}, fmt.Errorf("invalid parameter limit")
+		}
+		result.Limit = parsed
+	}
+
+	return result, nil
+}
+
+// searchTermsPattern matches any of the terms ignoring case, preferring the longest ones.
+func searchTermsPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	quoted := []string{}
+	for _, term := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(term))
+	}
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+
+	return regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+}
+
+// highlightSearchTerms escapes the text for HTML and wraps the terms in mark tags.
+func highlightSearchTerms(text string, pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return html.EscapeString(text)
+	}
+
+	var highlighted strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		highlighted.WriteString(html.EscapeString(text[last:match[0]]))
+		highlighted.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	highlighted.WriteString(html.EscapeString(text[last:]))
+
+	return highlighted.String()
+}
+
+// searchSnippet highlights an excerpt of the description or the location around the first matched term.
+func searchSnippet(result PollSearchResult, pattern *regexp.Regexp) string {
+	text := strings.Join(strings.Fields(result.Description), " ")
+	if pattern != nil && !pattern.MatchString(text) && pattern.MatchString(result.Location) {
+		text = result.Location
+	}
+
+	runes := []rune(text)
+	if len(runes) <= SEARCH_SNIPPET_LENGTH {
+		return highlightSearchTerms(text, pattern)
+	}
+
+	start := 0
+	if pattern != nil {
+		if match := pattern.FindStringIndex(text); match != nil {
+			start = utf8.RuneCountInString(text[:match[0]]) - SEARCH_SNIPPET_LENGTH/4
+		}
+	}
+	start = max(0, min(start, len(runes)-SEARCH_SNIPPET_LENGTH))
+	end := start + SEARCH_SNIPPET_LENGTH
+
+	snippet := highlightSearchTerms(string(runes[start:end]), pattern)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+
+	return snippet
+}
+
+func (a *APIServer) searchPolls(ctx *gin.Context, accountID int64) {
+	query, err := parsePollSearchQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := SearchPolls(ctx, a.db, accountID, query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	pattern := searchTermsPattern(query.Terms)
+	for idx := range results {
+		results[idx].TitleHighlight = highlightSearchTerms(results[idx].Title, pattern)
+		results[idx].Snippet = searchSnippet(results[idx], pattern)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": results})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchTerms(t *testing.T) {
+	testCases := []struct {
+		text     string
+		terms    []string
+		excluded []string
+	}{
+		{"Team lunch", []string{"team", "lunch"}, []string{}},
+		{`"team  lunch" lisbon -porto`, []string{"team lunch", "lisbon"}, []string{"porto"}},
+		{`lunch or dinner Lunch`, []string{"lunch", "dinner"}, []string{}},
+		{`-"board meeting" "or"`, []string{"or"}, []string{"board meeting"}},
+		{`"unterminated phrase`, []string{"unterminated phrase"}, []string{}},
+	}
+
+	for _, testCase := range testCases {
+		terms, excluded := parseSearchTerms(testCase.text)
+		if !reflect.DeepEqual(terms, testCase.terms) || !reflect.DeepEqual(excluded, testCase.excluded) {
+			t.Errorf("Expected %q to be %v without %v, but got %v without %v", testCase.text, testCase.terms, testCase.excluded, terms, excluded)
+		}
+	}
+}
+
+func TestParsePollSearchQuery(t *testing.T) {
+	query, err := parsePollSearchQuery(url.Values{"q": {"lunch"}, "owner": {"ana@example.com"}, "state": {"finalized"}, "limit": {"5"}})
+	if err != nil || query.Owner != "ana@example.com" || query.State != POLL_LIST_STATE_FINALIZED || query.Limit != 5 {
+		t.Errorf("Expected the parsed query, but got %+v (%v)", query, err)
+	}
+
+	testCases := []url.Values{
+		{},
+		{"q": {"-lunch"}},
+		{"q": {strings.Repeat("a", SEARCH_QUERY_MAX_LENGTH+1)}},
+		{"q": {"lunch"}, "owner": {"ana"}},
+		{"q": {"lunch"}, "state": {"closed"}},
+		{"q": {"lunch"}, "limit": {"101"}},
+	}
+	for _, values := range testCases {
+		if _, err := parsePollSearchQuery(values); err == nil {
+			t.Errorf("Expected %v to be invalid", values)
+		}
+	}
+}
+
+func TestHighlightSearchTerms(t *testing.T) {
+	pattern := searchTermsPattern([]string{"lunch", "team lunch"})
+
+	highlighted := highlightSearchTerms("<b>Team Lunch</b> & lunch", pattern)
+	expected := "&lt;b&gt;<mark>Team Lunch</mark>&lt;/b&gt; &amp; <mark>lunch</mark>"
+	if highlighted != expected {
+		t.Errorf("Expected %q, but got %q", expected, highlighted)
+	}
+
+	if highlighted := highlightSearchTerms("a < b", nil); highlighted != "a &lt; b" {
+		t.Errorf("Expected the escaped text without terms, but got %q", highlighted)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	pattern := searchTermsPattern([]string{"lisbon"})
+
+	snippet := searchSnippet(PollSearchResult{Description: "Dinner", Location: "Lisbon"}, pattern)
+	if snippet != "<mark>Lisbon</mark>" {
+		t.Errorf("Expected the location snippet, but got %q", snippet)
+	}
+
+	description := strings.Repeat("word ", 100) + "in Lisbon " + strings.Repeat("word ", 100)
+	snippet = searchSnippet(PollSearchResult{Description: description}, pattern)
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") || !strings.Contains(snippet, "<mark>Lisbon</mark>") {
+		t.Errorf("Expected an excerpt around the match, but got %q", snippet)
+	}
+
+	snippet = searchSnippet(PollSearchResult{Description: description}, searchTermsPattern([]string{"missing"}))
+	if strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("Expected the start of the description, but got %q", snippet)
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	SLACK_DEFAULT_API_URL  = "https://slack.com/api"
+	SLACK_MAX_REQUEST_AGE  = 5 * time.Minute
+	SLACK_MAX_POLL_OPTIONS = 20
+)
+
+type SlackBlock = map[string]interface{}
+
+type SlackUser struct {
+	ID       string
+	Name     string
+	RealName string
+	Email    string
+	TZ       string
+}
+
+// SlackClient is a minimal client of the Slack Web API.
+// The api url is configurable so it can point to a local fake in tests.
+type SlackClient struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+func NewSlackClient(apiURL string, token string) *SlackClient {
+	if apiURL == "" {
+		apiURL = SLACK_DEFAULT_API_URL
+	}
+
+	return &SlackClient{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *SlackClient) call(ctx context.Context, method string, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s responded with status %d", method, res.StatusCode)
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(resBody, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s failed: %s", method, status.Error)
+	}
+
+	if out != nil {
+		return json.Unmarshal(resBody, out)
+	}
+
+	return nil
+}
+
+func (s *SlackClient) callJSON(ctx context.Context, method string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.call(ctx, method, "application/json; charset=utf-8", bytes.NewReader(body), out)
+}
+
+func (s *SlackClient) PostMessage(ctx context.Context, channel string, text string, blocks []SlackBlock) (string, error) {
+	var res struct {
+		TS string `json:"ts"`
+	}
+	err := s.callJSON(ctx, "chat.postMessage", map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+		"blocks":  blocks,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.TS, nil
+}
+
+func (s *SlackClient) UpdateMessage(ctx context.Context, channel string, ts string, text string, blocks []SlackBlock) error {
+	return s.callJSON(ctx, "chat.update", map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+		"text":    text,
+		"blocks":  blocks,
+	}, nil)
+}
+
+func (s *SlackClient) UserInfo(ctx context.Context, userID string) (SlackUser, error) {
+	var res struct {
+		User struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			TZ      string `json:"tz"`
+			Profile struct {
+				RealName string `json:"real_name"`
+				Email    string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+
+	form := url.Values{"user": {userID}}
+	err := s.call(ctx, "users.info", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &res)
+	if err != nil {
+		return SlackUser{}, err
+	}
+
+	return SlackUser{
+		ID:       res.User.ID,
+		Name:     res.User.Name,
+		RealName: res.User.Profile.RealName,
+		Email:    res.User.Profile.Email,
+		TZ:       res.User.TZ,
+	}, nil
+}
+
+// VerifySlackRequest rejects requests that are not signed with the Slack app signing secret.
+func VerifySlackRequest(secret []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			logger.Error("failed to read slack request body", zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+
+		err = verifySlackSignature(secret, ctx.GetHeader("X-Slack-Request-Timestamp"), ctx.GetHeader("X-Slack-Signature"), body, time.Now())
+		if err != nil {
+			logger.Warn("invalid slack request signature", zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+			return
+		}
+
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		ctx.Next()
+	}
+}
+
+func verifySlackSignature(secret []byte, timestamp string, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > SLACK_MAX_REQUEST_AGE || age < -SLACK_MAX_REQUEST_AGE {
+		return fmt.Errorf("request timestamp too old")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signSlackRequest(secret, timestamp, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
+
+func signSlackRequest(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type SlackHandler struct {
+	db     *sql.DB
+	client *SlackClient
+}
+
+func NewSlackHandler(db *sql.DB, client *SlackClient) *SlackHandler {
+	return &SlackHandler{db: db, client: client}
+}
+
+// slackAccount maps a Slack user to a roodle account through the email of the Slack profile.
+func (h *SlackHandler) slackAccount(ctx context.Context, userID string) (int64, SlackUser, error) {
+	user, err := h.client.UserInfo(ctx, userID)
+	if err != nil {
+		logger.Error("failed to retrieve slack user", zap.String("user", userID), zap.Error(err))
+		return -1, SlackUser{}, err
+	}
+	if user.Email == "" {
+		return -1, SlackUser{}, fmt.Errorf("slack user %s has no email", userID)
+	}
+
+	name := user.RealName
+	if name == "" {
+		name = user.Name
+	}
+
+	accountID, err := GetOrCreateAccount(ctx, h.db, Account{
+		Email:    user.Email,
+		Name:     name,
+		Username: strings.Split(user.Email, "@")[0],
+	})
+	if err != nil {
+		return -1, SlackUser{}, err
+	}
+
+	return accountID, user, nil
+}
+
+func (h *SlackHandler) command(ctx *gin.Context) {
+	text := strings.TrimSpace(ctx.PostForm("text"))
+	if text == "" || text == "help" {
+		ctx.JSON(http.StatusOK, slackEphemeral(slackCommandUsage))
+		return
+	}
+
+	accountID, user, err := h.slackAccount(ctx, ctx.PostForm("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral("Could not find your roodle account. Make sure your Slack profile has an email."))
+		return
+	}
+
+	pollsNumber, err := CountPolls(ctx, h.db, accountID)
+	if err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral("An unexpected error occurred"))
+		return
+	}
+	if err := pollQuotaError(pollsNumber, Workspace{}); err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral(err.Error()))
+		return
+	}
+
+	location, err := time.LoadLocation(user.TZ)
+	if err != nil {
+		location = time.UTC
+	}
+
+	pollBase, err := parseSlackPollCommand(text, location)
+	if err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral(err.Error()+"\n"+slackCommandUsage))
+		return
+	}
+
+	poll, err := NewPoll(ctx, h.db, accountID, Poll{PollBase: pollBase})
+	if err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral("An unexpected error occurred"))
+		return
+	}
+
+	channelID := ctx.PostForm("channel_id")
+	ts, err := h.client.PostMessage(ctx, channelID, poll.Title, slackPollBlocks(poll, WorkspaceSettings{}.PollAnswers(poll), CalculateResults(poll, nil)))
+	if err != nil {
+		logger.Error("failed to post slack poll message", zap.String("poll", poll.ID), zap.Error(err))
+		ctx.JSON(http.StatusOK, slackEphemeral("Poll created but it could not be posted to this channel: "+pollURL(poll.ID)))
+		return
+	}
+
+	err = NewSlackMessage(ctx, h.db, poll.ID, channelID, ts)
+	if err != nil {
+		ctx.JSON(http.StatusOK, slackEphemeral("Poll posted but it will not be updated with new answers: "+pollURL(poll.ID)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, slackEphemeral("Poll created: "+pollURL(poll.ID)))
+}
+
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+func (h *SlackHandler) interaction(ctx *gin.Context) {
+	var payload slackInteraction
+	err := json.Unmarshal([]byte(ctx.PostForm("payload")), &payload)
+	if err != nil {
+		logger.Error("failed to parse slack interaction payload", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	if payload.Type != "block_actions" {
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	for _, action := range payload.Actions {
+		pollID, optionID, answer, err := parseSlackVoteValue(action.Value)
+		if err != nil {
+			logger.Warn("invalid slack vote action", zap.String("value", action.Value))
+			continue
+		}
+
+		accountID, _, err := h.slackAccount(ctx, payload.User.ID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve account"})
+			return
+		}
+
+		_, err = RecordOptionAnswer(ctx, h.db, accountID, pollID, optionID, answer)
+		if errors.Is(err, ErrAnswerNotAllowed) {
+			logger.Warn("slack vote answer not allowed in the poll", zap.String("value", action.Value))
+			continue
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+	}
+
+	// The message is updated by the SlackConsumer once the vote event is relayed.
+	ctx.Status(http.StatusOK)
+}
+
+const slackCommandUsage = "Usage: `/roodle Title | 2024-05-02 10:00-11:00 | 2024-05-03 14:00-15:30`"
+
+func slackEphemeral(text string) gin.H {
+	return gin.H{"response_type": "ephemeral", "text": text}
+}
+
+// parseSlackPollCommand parses `Title | YYYY-MM-DD HH:MM-HH:MM | ...` with the times in the user time zone.
+func parseSlackPollCommand(text string, location *time.Location) (PollBase, error) {
+	parts := strings.Split(text, "|")
+	title := strings.TrimSpace(parts[0])
+	if title == "" {
+		return PollBase{}, errors.New("missing poll title")
+	}
+	if len(parts) < 2 {
+		return PollBase{}, errors.New("missing poll options")
+	}
+	if len(parts)-1 > SLACK_MAX_POLL_OPTIONS {
+		return PollBase{}, fmt.Errorf("too many options, the maximum is %d", SLACK_MAX_POLL_OPTIONS)
+	}
+
+	options := []PollOption{}
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return PollBase{}, fmt.Errorf("invalid option %q", strings.TrimSpace(part))
+		}
+
+		times := strings.Split(fields[1], "-")
+		if len(times) != 2 {
+			return PollBase{}, fmt.Errorf("invalid option %q", strings.TrimSpace(part))
+		}
+
+		start, err := time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+times[0], location)
+		if err != nil {
+			return PollBase{}, fmt.Errorf("invalid option start %q", strings.TrimSpace(part))
+		}
+		end, err := time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+times[1], location)
+		if err != nil {
+			return PollBase{}, fmt.Errorf("invalid option end %q", strings.TrimSpace(part))
+		}
+		if !end.After(start) {
+			return PollBase{}, fmt.Errorf("option %q ends before it starts", strings.TrimSpace(part))
+		}
+
+		options = append(options, PollOption{Start: start, End: end})
+	}
+
+	return PollBase{Title: title, Options: options}, nil
+}
+
+func slackVoteValue(pollID string, optionID string, answer OptionAnswer) string {
+	return strings.Join([]string{pollID, optionID, answer}, "|")
+}
+
+func parseSlackVoteValue(value string) (string, string, OptionAnswer, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !slices.Contains(AllOptionAnswer, parts[2]) {
+		return "", "", "", errors.New("invalid vote value")
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
+
+func pollURL(pollID string) string {
+	return baseURL + "/poll/" + pollID
+}
+
+// slackDate renders a timestamp in the time zone of whoever is reading the message.
+func slackDate(t time.Time, format string) string {
+	return fmt.Sprintf("<!date^%d^%s|%s>", t.Unix(), format, t.UTC().Format(time.RFC1123))
+}
+
+var slackAnswerButtons = []struct {
+	Answer OptionAnswer
+	Label  string
+	Style  string
+}{
+	{Available, "Available", "primary"},
+	{Maybe, "Maybe", ""},
+	{Unavailable, "Unavailable", "danger"},
+}
+
+// slackPollBlocks shows the results of the poll, with a button for each of the answers allowed in the poll.
+func slackPollBlocks(poll Poll, answers []OptionAnswer, results PollResults) []SlackBlock {
+	header := "*" + poll.Title + "*"
+	if poll.Description != "" {
+		header += "\n" + poll.Description
+	}
+	if poll.Location != "" {
+		header += "\n:round_pushpin: " + poll.Location
+	}
+
+	blocks := []SlackBlock{
+		{"type": "section", "text": SlackBlock{"type": "mrkdwn", "text": header}},
+		{"type": "context", "elements": []SlackBlock{{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("%d participants · <%s|Open in roodle>", results.Participants, pollURL(poll.ID)),
+		}}},
+	}
+
+	for _, result := range results.Options {
+		text := fmt.Sprintf("%s - %s\n:white_check_mark: %d   :grey_question: %d   :x: %d",
+			slackDate(result.Option.Start, "{date_short_pretty} {time}"),
+			slackDate(result.Option.End, "{time}"),
+			result.Counts[Available], result.Counts[Maybe], result.Counts[Unavailable])
+
+		buttons := []SlackBlock{}
+		for _, button := range slackAnswerButtons {
+			if !slices.Contains(answers, button.Answer) {
+				continue
+			}
+			element := SlackBlock{
+				"type":      "button",
+				"action_id": "vote_" + button.Answer,
+				"text":      SlackBlock{"type": "plain_text", "text": button.Label},
+				"value":     slackVoteValue(poll.ID, result.Option.ID, button.Answer),
+			}
+			if button.Style != "" {
+				element["style"] = button.Style
+			}
+			buttons = append(buttons, element)
+		}
+
+		blocks = append(blocks,
+			SlackBlock{"type": "section", "text": SlackBlock{"type": "mrkdwn", "text": text}},
+			SlackBlock{"type": "actions", "block_id": result.Option.ID, "elements": buttons},
+		)
+	}
+
+	return blocks
+}
+
+// SlackConsumer keeps the poll messages posted to Slack in sync with the votes.
+type SlackConsumer struct {
+	db     *sql.DB
+	client *SlackClient
+}
+
+func NewSlackConsumer(db *sql.DB, client *SlackClient) *SlackConsumer {
+	return &SlackConsumer{db: db, client: client}
+}
+
+func (s *SlackConsumer) Name() string {
+	return "slack"
+}
+
+func (s *SlackConsumer) Consume(ctx context.Context, event PollEvent) error {
+	if event.Type != VoteCastEvent && event.Type != PollDeletedEvent && event.Type != PollRestoredEvent {
+		return nil
+	}
+
+	messages, err := ListSlackMessages(ctx, s.db, event.PollID)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+
+	// The messages are kept while the poll is in the trash, so they show the poll again if it is restored.
+	if event.Type == PollDeletedEvent {
+		blocks := []SlackBlock{{"type": "section", "text": SlackBlock{"type": "mrkdwn", "text": "_This poll was deleted._"}}}
+		for _, message := range messages {
+			err := s.client.UpdateMessage(ctx, message.ChannelID, message.TS, "This poll was deleted.", blocks)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	poll, err := GetPoll(ctx, s.db, event.PollID)
+	if err != nil {
+		return err
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		return nil
+	}
+
+	votes, err := ListVotes(ctx, s.db, event.PollID)
+	if err != nil {
+		return err
+	}
+
+	answers, err := GetPollAnswers(ctx, s.db, poll)
+	if err != nil {
+		return err
+	}
+
+	blocks := slackPollBlocks(poll, answers, CalculateResults(poll, votes))
+	for _, message := range messages {
+		err := s.client.UpdateMessage(ctx, message.ChannelID, message.TS, poll.Title, blocks)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySlackSignature(t *testing.T) {
+	secret := []byte("8f742231b10e8888abcd99yyyzzz85a5")
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&command=%2Froodle&text=help")
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := signSlackRequest(secret, timestamp, body)
+
+	if err := verifySlackSignature(secret, timestamp, signature, body, now); err != nil {
+		t.Errorf("Expected valid signature, but got %s", err)
+	}
+
+	if err := verifySlackSignature(secret, timestamp, signature, append(body, 'x'), now); err == nil {
+		t.Errorf("Expected tampered body to be rejected")
+	}
+
+	if err := verifySlackSignature([]byte("other"), timestamp, signature, body, now); err == nil {
+		t.Errorf("Expected signature with another secret to be rejected")
+	}
+
+	if err := verifySlackSignature(secret, timestamp, signature, body, now.Add(10*time.Minute)); err == nil {
+		t.Errorf("Expected replayed request to be rejected")
+	}
+}
+
+func TestParseSlackPollCommand(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Lisbon")
+
+	poll, err := parseSlackPollCommand("Team lunch | 2024-05-02 12:00-13:00 | 2024-05-03 12:30-14:00", location)
+	if err != nil {
+		t.Fatalf("Expected command to parse, but got %s", err)
+	}
+	if poll.Title != "Team lunch" {
+		t.Errorf("Expected title Team lunch, but got %s", poll.Title)
+	}
+	if len(poll.Options) != 2 {
+		t.Fatalf("Expected 2 options, but got %d", len(poll.Options))
+	}
+	expectedStart := time.Date(2024, 5, 2, 11, 0, 0, 0, time.UTC)
+	if !poll.Options[0].Start.Equal(expectedStart) {
+		t.Errorf("Expected start %s, but got %s", expectedStart, poll.Options[0].Start.UTC())
+	}
+
+	invalidCommands := []string{"| 2024-05-02 12:00-13:00", "Lunch", "Lunch | tomorrow", "Lunch | 2024-05-02 13:00-12:00"}
+	for _, command := range invalidCommands {
+		if _, err := parseSlackPollCommand(command, location); err == nil {
+			t.Errorf("Expected command %q to be rejected", command)
+		}
+	}
+}
+
+func TestSlackClient(t *testing.T) {
+	fakeSlack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/users.info":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok": true,
+				"user": map[string]interface{}{
+					"id": r.FormValue("user"), "name": "jane", "tz": "Europe/Lisbon",
+					"profile": map[string]interface{}{"real_name": "Jane Doe", "email": "jane@example.com"},
+				},
+			})
+		case "/chat.postMessage":
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload["channel"] != "C123" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "channel_not_found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "ts": "1714640000.000100"})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "unknown_method"})
+		}
+	}))
+	defer fakeSlack.Close()
+
+	client := NewSlackClient(fakeSlack.URL, "xoxb-test")
+
+	user, err := client.UserInfo(context.Background(), "U123")
+	if err != nil {
+		t.Fatalf("Expected user info, but got %s", err)
+	}
+	if user.Email != "jane@example.com" || user.TZ != "Europe/Lisbon" {
+		t.Errorf("Unexpected user %+v", user)
+	}
+
+	poll := Poll{ID: "abc", PollBase: PollBase{Title: "Lunch", Options: []PollOption{{ID: "o1", Start: time.Now(), End: time.Now().Add(time.Hour)}}}}
+	ts, err := client.PostMessage(context.Background(), "C123", poll.Title, slackPollBlocks(poll, AllOptionAnswer, CalculateResults(poll, nil)))
+	if err != nil {
+		t.Fatalf("Expected message to be posted, but got %s", err)
+	}
+	if ts != "1714640000.000100" {
+		t.Errorf("Unexpected message ts %s", ts)
+	}
+
+	if _, err := client.PostMessage(context.Background(), "C999", poll.Title, nil); err == nil {
+		t.Errorf("Expected slack error to be returned")
+	}
+}
+
+func TestSlackPollBlocksAnswers(t *testing.T) {
+	poll := Poll{ID: "abc", PollBase: PollBase{Title: "Lunch", Options: []PollOption{{ID: "o1", Start: time.Now(), End: time.Now().Add(time.Hour)}}}}
+
+	blocks := slackPollBlocks(poll, []OptionAnswer{Available, Unavailable}, CalculateResults(poll, nil))
+	values := []string{}
+	for _, element := range blocks[len(blocks)-1]["elements"].([]SlackBlock) {
+		values = append(values, element["value"].(string))
+	}
+	expected := []string{slackVoteValue("abc", "o1", Available), slackVoteValue("abc", "o1", Unavailable)}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected the buttons %v, but got %v", expected, values)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	SMTP_COMMAND_TIMEOUT = 5 * time.Minute
+	SMTP_MAX_RECIPIENTS  = 50
+)
+
+type MessageHandler func(ctx context.Context, recipients []string, raw []byte) error
+
+// SMTPServer is a receive-only SMTP server for the replies to poll emails.
+// It is meant to run behind a MTA that handles TLS and spam filtering, so it does not support STARTTLS or AUTH,
+// and it only accepts recipients in its domain.
+type SMTPServer struct {
+	addr    string
+	domain  string
+	handler MessageHandler
+}
+
+func NewSMTPServer(addr string, domain string, handler MessageHandler) *SMTPServer {
+	return &SMTPServer{addr: addr, domain: strings.ToLower(domain), handler: handler}
+}
+
+func (s *SMTPServer) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+func (s *SMTPServer) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	logger.Infof("Listening for inbound emails on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go s.serveConn(ctx, conn)
+	}
+}
+
+type smtpSession struct {
+	started    bool
+	from       string
+	recipients []string
+}
+
+func (s *SMTPServer) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) bool {
+		conn.SetWriteDeadline(time.Now().Add(SMTP_COMMAND_TIMEOUT))
+		return text.PrintfLine("%d %s", code, message) == nil
+	}
+
+	if !reply(220, s.domain+" ESMTP roodle") {
+		return
+	}
+
+	session := smtpSession{}
+	greeted := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(SMTP_COMMAND_TIMEOUT))
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			greeted = true
+			reply(250, s.domain)
+		case "EHLO":
+			greeted = true
+			text.PrintfLine("250-%s", s.domain)
+			text.PrintfLine("250-SIZE %d", INBOUND_MAX_MESSAGE_SIZE)
+			reply(250, "8BITMIME")
+		case "MAIL":
+			if !greeted {
+				reply(503, "Send HELO first")
+				continue
+			}
+			address, ok := smtpPathArg(arg, "FROM:")
+			if !ok {
+				reply(501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			session = smtpSession{started: true, from: address}
+			reply(250, "OK")
+		case "RCPT":
+			if !session.started {
+				reply(503, "Need MAIL command")
+				continue
+			}
+			address, ok := smtpPathArg(arg, "TO:")
+			if !ok || address == "" {
+				reply(501, "Syntax: RCPT TO:<address>")
+				continue
+			}
+			if _, domain, _ := strings.Cut(address, "@"); domain != s.domain {
+				reply(550, "Relay not permitted")
+				continue
+			}
+			if len(session.recipients) >= SMTP_MAX_RECIPIENTS {
+				reply(452, "Too many recipients")
+				continue
+			}
+			session.recipients = append(session.recipients, address)
+			reply(250, "OK")
+		case "DATA":
+			if len(session.recipients) == 0 {
+				reply(503, "Need RCPT command")
+				continue
+			}
+			if !reply(354, "End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+
+			raw, err := io.ReadAll(io.LimitReader(text.DotReader(), INBOUND_MAX_MESSAGE_SIZE+1))
+			if err != nil {
+				return
+			}
+			if len(raw) > INBOUND_MAX_MESSAGE_SIZE {
+				// Drain the rest of the message before answering.
+				io.Copy(io.Discard, text.DotReader())
+				reply(552, "Message too big")
+				session = smtpSession{}
+				continue
+			}
+
+			err = s.handler(ctx, session.recipients, raw)
+			switch {
+			case err == nil:
+				reply(250, "OK")
+			case errors.Is(err, ErrUnknownRecipient):
+				reply(550, "No such poll")
+			default:
+				logger.Warn("failed to handle inbound email", zap.Error(err))
+				reply(554, "Message could not be processed")
+			}
+			session = smtpSession{}
+		case "RSET":
+			session = smtpSession{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "VRFY":
+			reply(252, "Cannot verify user")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// smtpPathArg parses arguments like `FROM:<user@example.com> SIZE=100`.
+func smtpPathArg(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	path := strings.TrimSpace(arg[len(prefix):])
+	if idx := strings.Index(path, ">"); idx != -1 {
+		path = path[:idx+1]
+	}
+	if path == "<>" {
+		return "", true
+	}
+
+	address, err := mail.ParseAddress(path)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.ToLower(address.Address), true
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TEMPLATE_NAME_MAX_LENGTH  = 100
+	TEMPLATE_MAX_DURATION     = 7 * 24 * 60
+	TEMPLATE_MAX_INVITES      = 500
+	MAX_TEMPLATES_PER_ACCOUNT = 100
+	CLONE_MAX_SHIFT_DAYS      = 3660
+)
+
+// Validate trims the template, names it after its title when it has no name and normalizes the invites.
+func (t *PollTemplate) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Name == "" {
+		t.Name = t.Title
+	}
+	if t.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if utf8.RuneCountInString(t.Name) > TEMPLATE_NAME_MAX_LENGTH {
+		return fmt.Errorf("name too long, the maximum is %d characters", TEMPLATE_NAME_MAX_LENGTH)
+	}
+
+	if t.Duration < 1 || t.Duration > TEMPLATE_MAX_DURATION {
+		return fmt.Errorf("invalid duration, it must be between 1 and %d minutes", TEMPLATE_MAX_DURATION)
+	}
+
+	if err := validateAnswerScale(t.AnswerScale); err != nil {
+		return err
+	}
+
+	invites, err := normalizeInviteEmails(t.Invites, WorkspaceSettings{})
+	if err != nil {
+		return err
+	}
+	if len(invites) > TEMPLATE_MAX_INVITES {
+		return fmt.Errorf("too many invites, the maximum is %d", TEMPLATE_MAX_INVITES)
+	}
+	t.Invites = invites
+
+	return nil
+}
+
+// Poll creates the poll of the template with an option of the template duration at each start.
+func (t PollTemplate) Poll(starts []time.Time, workspaceID int64) Poll {
+	options := []PollOption{}
+	for _, start := range starts {
+		options = append(options, PollOption{Start: start, End: start.Add(time.Duration(t.Duration) * time.Minute)})
+	}
+
+	return Poll{
+		PollBase: PollBase{
+			Title:       t.Title,
+			Description: t.Description,
+			Location:    t.Location,
+			Options:     options,
+		},
+		WorkspaceID: workspaceID,
+		AnswerScale: t.AnswerScale,
+	}
+}
+
+type UsePollTemplateRequest struct {
+	Starts      []time.Time `json:"starts"`
+	WorkspaceID int64       `json:"workspace_id"`
+}
+
+func (r UsePollTemplateRequest) Validate() error {
+	if len(r.Starts) == 0 {
+		return fmt.Errorf("missing starts")
+	}
+	if len(r.Starts) > OPTION_GENERATOR_MAX_OPTIONS {
+		return fmt.Errorf("too many starts, the maximum is %d", OPTION_GENERATOR_MAX_OPTIONS)
+	}
+
+	return nil
+}
+
+// ClonePollRequest shifts the options of the copy, in the time zone so the options keep their local time across daylight saving changes.
+// Settings are the workspace, the answer scale, the comments lock and the members of the poll.
+type ClonePollRequest struct {
+	ShiftDays    int    `json:"shift_days"`
+	ShiftWeeks   int    `json:"shift_weeks"`
+	TimeZone     string `json:"time_zone"`
+	CopySettings bool   `json:"copy_settings"`
+	CopyInvites  bool   `json:"copy_invites"`
+}
+
+// Shift returns the days to shift the options by and the time zone to shift them in.
+func (r ClonePollRequest) Shift() (int, *time.Location, error) {
+	days := r.ShiftDays + 7*r.ShiftWeeks
+	if days < -CLONE_MAX_SHIFT_DAYS || days > CLONE_MAX_SHIFT_DAYS {
+		return 0, nil, fmt.Errorf("invalid shift, the maximum is %d days", CLONE_MAX_SHIFT_DAYS)
+	}
+
+	location, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid time zone %s", r.TimeZone)
+	}
+
+	return days, location, nil
+}
+
+// shiftPollOptions copies the options moved by a number of days, the copies get new ids when the poll is created.
+func shiftPollOptions(options []PollOption, days int, location *time.Location) []PollOption {
+	shifted := []PollOption{}
+	for _, option := range options {
+		shifted = append(shifted, PollOption{
+			Start: option.Start.In(location).AddDate(0, 0, days),
+			End:   option.End.In(location).AddDate(0, 0, days),
+		})
+	}
+
+	return shifted
+}
+
+// createPollWithInvites creates the poll for the account and invites the emails to it, a cloned poll also brings
+// the members and comments lock of the original.
+func (a *APIServer) createPollWithInvites(ctx *gin.Context, accountID int64, poll Poll, emails []string, members []PollMember, commentsLocked bool) (Poll, bool) {
+	if !a.checkPollQuota(ctx, accountID, poll.WorkspaceID) {
+		return Poll{}, false
+	}
+
+	workspace, err := a.getPollWorkspace(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+	if err := workspace.Settings.ValidatePollAnswerScale(poll.AnswerScale); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return Poll{}, false
+	}
+	emails, err = normalizeInviteEmails(emails, workspace.Settings)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return Poll{}, false
+	}
+
+	createdPoll, err := NewPollWithInvites(ctx, a.db, accountID, poll, emails, members, commentsLocked)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, false
+	}
+
+	return createdPoll, true
+}
+
+// clonePoll copies a poll for a new round, like a monthly planning, as a poll of the account cloning it.
+func (a *APIServer) clonePoll(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	request := ClonePollRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if request.TimeZone == "" {
+		workspace, err := a.getPollWorkspace(ctx, poll, accountID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		request.TimeZone = workspace.Settings.TimeZone
+	}
+	days, location, err := request.Shift()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clone := Poll{
+		PollBase: PollBase{
+			Title:       poll.Title,
+			Description: poll.Description,
+			Location:    poll.Location,
+			Options:     shiftPollOptions(poll.Options, days, location),
+		},
+	}
+	if request.CopySettings {
+		clone.WorkspaceID = poll.WorkspaceID
+		clone.AnswerScale = poll.AnswerScale
+	}
+
+	emails := []string{}
+	if request.CopyInvites {
+		invites, err := ListPollInvites(ctx, a.db, poll.ID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		for _, invite := range invites {
+			emails = append(emails, invite.Email)
+		}
+	}
+
+	members := []PollMember{}
+	commentsLocked := false
+	if request.CopySettings {
+		commentsLocked, err = GetPollCommentsLocked(ctx, a.db, poll.ID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+
+		pollMembers, err := ListPollMembers(ctx, a.db, poll.ID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			return
+		}
+		for _, member := range pollMembers {
+			if member.AccountID == accountID {
+				continue
+			}
+			// The clone belongs to the account cloning it, the owner of the original poll joins it as an editor.
+			if member.Role == PollOwner {
+				member.Role = PollEditor
+			}
+			members = append(members, member)
+		}
+	}
+
+	clone, ok = a.createPollWithInvites(ctx, accountID, clone, emails, members, commentsLocked)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": clone})
+}
+
+// getPollTemplate loads the template in the id path parameter, templates are private to the account that saved them.
+func (a *APIServer) getPollTemplate(ctx *gin.Context, accountID int64) (PollTemplate, bool) {
+	templateID, err := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return PollTemplate{}, false
+	}
+
+	template, err := GetPollTemplate(ctx, a.db, accountID, templateID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return PollTemplate{}, false
+	}
+	if template.ID == 0 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return PollTemplate{}, false
+	}
+
+	return template, true
+}
+
+func (a *APIServer) listPollTemplates(ctx *gin.Context, accountID int64) {
+	templates, err := ListPollTemplates(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+func (a *APIServer) newPollTemplate(ctx *gin.Context, accountID int64) {
+	template := PollTemplate{}
+	err := readBody(ctx, &template)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := template.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	templatesNumber, err := CountPollTemplates(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if templatesNumber >= MAX_TEMPLATES_PER_ACCOUNT {
+		ctx.AbortWithStatusJSON(http.StatusTeapot, gin.H{"error": fmt.Sprintf("template limit of %d reached. delete some templates before creating a new one.", MAX_TEMPLATES_PER_ACCOUNT)})
+		return
+	}
+
+	template, err = NewPollTemplate(ctx, a.db, accountID, template)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": template})
+}
+
+func (a *APIServer) updatePollTemplate(ctx *gin.Context, accountID int64) {
+	existing, ok := a.getPollTemplate(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	template := PollTemplate{}
+	err := readBody(ctx, &template)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := template.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID = existing.ID
+
+	template, err = UpdatePollTemplate(ctx, a.db, accountID, template)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if template.ID == 0 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": template})
+}
+
+func (a *APIServer) deletePollTemplate(ctx *gin.Context, accountID int64) {
+	template, ok := a.getPollTemplate(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	deleted, err := DeletePollTemplate(ctx, a.db, accountID, template.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": template})
+}
+
+// newPollFromTemplate creates a poll from the template and invites its invite list.
+func (a *APIServer) newPollFromTemplate(ctx *gin.Context, accountID int64) {
+	template, ok := a.getPollTemplate(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	request := UsePollTemplateRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	poll, ok := a.createPollWithInvites(ctx, accountID, template.Poll(request.Starts, request.WorkspaceID), template.Invites, nil, false)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": poll})
+}
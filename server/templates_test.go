@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPollTemplateValidate(t *testing.T) {
+	template := PollTemplate{Title: "  Monthly planning ", Duration: 60, Invites: []string{" Ana@Example.com", "ana@example.com", "joao@example.com"}}
+	if err := template.Validate(); err != nil {
+		t.Errorf("Expected a valid template, but got %v", err)
+	}
+	if template.Name != "Monthly planning" || !reflect.DeepEqual(template.Invites, []string{"ana@example.com", "joao@example.com"}) {
+		t.Errorf("Expected the normalized template, but got %+v", template)
+	}
+
+	testCases := []PollTemplate{
+		{Duration: 60},
+		{Name: "Planning", Duration: 0},
+		{Name: "Planning", Duration: TEMPLATE_MAX_DURATION + 1},
+		{Name: "Planning", Duration: 60, AnswerScale: []OptionAnswer{Available, Maybe}},
+		{Name: "Planning", Duration: 60, Invites: []string{"ana"}},
+	}
+	for _, template := range testCases {
+		if err := template.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", template)
+		}
+	}
+}
+
+func TestPollTemplatePoll(t *testing.T) {
+	template := PollTemplate{Title: "Planning", Location: "Lisbon", Duration: 90, AnswerScale: []OptionAnswer{Available, Unavailable}}
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	poll := template.Poll([]time.Time{start}, 3)
+	expected := []PollOption{{Start: start, End: start.Add(90 * time.Minute)}}
+	if poll.Title != "Planning" || poll.Location != "Lisbon" || poll.WorkspaceID != 3 || !reflect.DeepEqual(poll.AnswerScale, template.AnswerScale) ||
+		!reflect.DeepEqual(poll.Options, expected) {
+		t.Errorf("Expected the poll of the template, but got %+v", poll)
+	}
+}
+
+func TestClonePollRequestShift(t *testing.T) {
+	days, location, err := ClonePollRequest{ShiftDays: 2, ShiftWeeks: 4, TimeZone: "Europe/Lisbon"}.Shift()
+	if err != nil || days != 30 || location.String() != "Europe/Lisbon" {
+		t.Errorf("Expected a shift of 30 days in Europe/Lisbon, but got %d in %v (%v)", days, location, err)
+	}
+
+	testCases := []ClonePollRequest{
+		{ShiftDays: CLONE_MAX_SHIFT_DAYS + 1},
+		{ShiftWeeks: -CLONE_MAX_SHIFT_DAYS},
+		{TimeZone: "Europe/Nowhere"},
+	}
+	for _, request := range testCases {
+		if _, _, err := request.Shift(); err == nil {
+			t.Errorf("Expected %+v to be invalid", request)
+		}
+	}
+}
+
+func TestShiftPollOptions(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	options := []PollOption{{
+		ID:    "o1",
+		Start: time.Date(2024, 3, 28, 9, 0, 0, 0, lisbon),
+		End:   time.Date(2024, 3, 28, 10, 0, 0, 0, lisbon),
+	}}
+
+	// Shifting across the start of the summer time keeps the local time of the options.
+	shifted := shiftPollOptions(options, 7, lisbon)
+	if len(shifted) != 1 || shifted[0].ID != "" ||
+		!shifted[0].Start.Equal(time.Date(2024, 4, 4, 9, 0, 0, 0, lisbon)) || !shifted[0].End.Equal(time.Date(2024, 4, 4, 10, 0, 0, 0, lisbon)) {
+		t.Errorf("Expected the options a week later at 9:00, but got %+v", shifted)
+	}
+
+	shifted = shiftPollOptions(options, 14, time.UTC)
+	if !shifted[0].Start.Equal(options[0].Start.Add(14 * 24 * time.Hour)) {
+		t.Errorf("Expected the options shifted by 14 days in UTC, but got %+v", shifted)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	DEFAULT_POLL_RETENTION = 30 * 24 * time.Hour
+	POLL_PURGE_INTERVAL    = time.Hour
+)
+
+var (
+	// pollRetention is how long deleted polls stay in the trash before being purged.
+	pollRetention = DEFAULT_POLL_RETENTION
+)
+
+// pollPurgeAt is when a poll deleted at the time leaves the trash for good.
+func pollPurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(pollRetention)
+}
+
+// pollPurgeBefore is the deletion time before which the polls in the trash are purged, the polls past their pollPurgeAt.
+func pollPurgeBefore(now time.Time) time.Time {
+	return now.Add(-pollRetention)
+}
+
+// purgeDeletedPolls deletes for good the polls that stayed in the trash for longer than the retention.
+func purgeDeletedPolls(ctx context.Context, db *sql.DB) {
+	purged, err := PurgeDeletedPolls(ctx, db, pollPurgeBefore(time.Now()))
+	if err != nil {
+		return
+	}
+	if purged > 0 {
+		logger.Info("purged deleted polls", zap.Int64("count", purged))
+	}
+}
+
+func (a *APIServer) listTrashedPolls(ctx *gin.Context, accountID int64) {
+	polls, err := ListTrashedPolls(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	for idx := range polls {
+		polls[idx].PurgeAt = pollPurgeAt(polls[idx].DeletedAt)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": polls})
+}
+
+// getTrashedPoll loads the poll in the id path parameter from the trash of the account.
+func (a *APIServer) getTrashedPoll(ctx *gin.Context, accountID int64) (TrashedPoll, bool) {
+	pollID, err := getPathParam(ctx, "id")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return TrashedPoll{}, false
+	}
+
+	poll, err := GetTrashedPoll(ctx, a.db, accountID, pollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return TrashedPoll{}, false
+	}
+	if poll.ID == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found in the trash"})
+		return TrashedPoll{}, false
+	}
+
+	return poll, true
+}
+
+// restorePoll takes a poll out of the trash, it counts towards the poll limit again.
+func (a *APIServer) restorePoll(ctx *gin.Context, accountID int64) {
+	trashed, ok := a.getTrashedPoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	if !a.checkPollQuota(ctx, accountID, trashed.WorkspaceID) {
+		return
+	}
+
+	poll, err := RestorePoll(ctx, a.db, accountID, trashed.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if poll.ID == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found in the trash"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// purgePoll deletes a poll in the trash for good, without waiting for the retention.
+func (a *APIServer) purgePoll(ctx *gin.Context, accountID int64) {
+	trashed, ok := a.getTrashedPoll(ctx, accountID)
+	if !ok {
+		return
+	}
+
+	purged, err := PurgePoll(ctx, a.db, accountID, trashed.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !purged {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found in the trash"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": trashed})
+}
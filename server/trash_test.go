@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollPurgeAt(t *testing.T) {
+	defer func(retention time.Duration) { pollRetention = retention }(pollRetention)
+	pollRetention = 7 * 24 * time.Hour
+
+	deletedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if purgeAt := pollPurgeAt(deletedAt); !purgeAt.Equal(time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the poll to be purged a week after its deletion, but got %s", purgeAt)
+	}
+
+	// The purge removes the polls deleted before the cutoff, which are the ones past the purge time shown in the trash.
+	testCases := []struct {
+		now      time.Time
+		expected bool
+	}{
+		{deletedAt, false},
+		{time.Date(2024, 5, 8, 9, 59, 0, 0, time.UTC), false},
+		{time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 5, 8, 10, 1, 0, 0, time.UTC), true},
+	}
+	for _, testCase := range testCases {
+		purged := deletedAt.Before(pollPurgeBefore(testCase.now))
+		if purged != testCase.expected || purged != testCase.now.After(pollPurgeAt(deletedAt)) {
+			t.Errorf("Expected the poll to be purged at %s to be %v, but got %v", testCase.now, testCase.expected, purged)
+		}
+	}
+}
+
+func TestPollQuotaError(t *testing.T) {
+	workspace := Workspace{ID: 1, Settings: WorkspaceSettings{MaxPolls: 10}}
+
+	testCases := []struct {
+		pollsNumber int64
+		workspace   Workspace
+		expected    bool
+	}{
+		{0, Workspace{}, false},
+		{MAX_POLLS_PER_ACCOUNT - 1, Workspace{}, false},
+		{MAX_POLLS_PER_ACCOUNT, Workspace{}, true},
+		{9, workspace, false},
+		{10, workspace, true},
+		{MAX_POLLS_PER_ACCOUNT - 1, workspace, true},
+	}
+
+	for _, testCase := range testCases {
+		if err := pollQuotaError(testCase.pollsNumber, testCase.workspace); (err != nil) != testCase.expected {
+			t.Errorf("Expected %d polls in workspace %d to be over the limit %v, but got %v", testCase.pollsNumber, testCase.workspace.ID, testCase.expected, err)
+		}
+	}
+}
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
 	"math/rand"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 const (
@@ -9,6 +19,14 @@ const (
 	charsetLen = len(charset)
 )
 
+var (
+	// secretEncryptionKey is the AES-256 key of the credentials stored in the database.
+	secretEncryptionKey []byte
+
+	ErrMissingEncryptionKey = errors.New("missing secret encryption key")
+	ErrInvalidSecret        = errors.New("invalid encrypted secret")
+)
+
 func randomAlphanumeric(length int) string {
 	result := make([]byte, length)
 
@@ -18,3 +36,103 @@ func randomAlphanumeric(length int) string {
 
 	return string(result)
 }
+
+// randomToken returns an alphanumeric string from a cryptographically secure source,
+// to be used in urls that grant access without a login.
+func randomToken(length int) string {
+	result := make([]byte, length)
+
+	for i := 0; i < length; i++ {
+		n, err := crand.Int(crand.Reader, big.NewInt(int64(charsetLen)))
+		if err != nil {
+			panic(err)
+		}
+		result[i] = charset[n.Int64()]
+	}
+
+	return string(result)
+}
+
+// runPeriodically runs the job every interval until the context is cancelled.
+func runPeriodically(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// encryptSecret seals a credential with AES-GCM, the random nonce is prepended to the ciphertext.
+func encryptSecret(plaintext string) ([]byte, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+func decryptSecret(ciphertext []byte) (string, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+
+	return string(plaintext), nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	if len(secretEncryptionKey) == 0 {
+		return nil, ErrMissingEncryptionKey
+	}
+
+	block, err := aes.NewCipher(secretEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// parsePage reads the "after" cursor, the id of the last item already seen, and the page "limit" of an id ordered list.
+func parsePage(query url.Values, pageSize int, maxPageSize int) (int64, int, error) {
+	var after int64
+	if value := query.Get("after"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid parameter after")
+		}
+		after = parsed
+	}
+
+	limit := pageSize
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid parameter limit")
+		}
+		limit = parsed
+	}
+
+	return after, limit, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -25,3 +26,49 @@ func TestRandomAlphanumeric(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptSecret(t *testing.T) {
+	secretEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+	defer func() { secretEncryptionKey = nil }()
+
+	ciphertext, err := encryptSecret("refresh-token")
+	if err != nil {
+		t.Fatalf("Expected secret to be encrypted, but got %s", err)
+	}
+	if strings.Contains(string(ciphertext), "refresh-token") {
+		t.Errorf("Expected ciphertext to not contain the secret")
+	}
+
+	plaintext, err := decryptSecret(ciphertext)
+	if err != nil || plaintext != "refresh-token" {
+		t.Errorf("Expected refresh-token, but got %q (%v)", plaintext, err)
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err := decryptSecret(ciphertext); err != ErrInvalidSecret {
+		t.Errorf("Expected tampered secret to be rejected, but got %v", err)
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	testCases := []struct {
+		query string
+		after int64
+		limit int
+		valid bool
+	}{
+		{"", 0, COMMENTS_PAGE_SIZE, true},
+		{"after=42&limit=10", 42, 10, true},
+		{"after=-1", 0, 0, false},
+		{"limit=0", 0, 0, false},
+		{"limit=1000", 0, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		query, _ := url.ParseQuery(testCase.query)
+		after, limit, err := parsePage(query, COMMENTS_PAGE_SIZE, COMMENTS_MAX_PAGE_SIZE)
+		if (err == nil) != testCase.valid || after != testCase.after || limit != testCase.limit {
+			t.Errorf("Expected %q to give (%d, %d, %v), but got (%d, %d, %v)", testCase.query, testCase.after, testCase.limit, testCase.valid, after, limit, err)
+		}
+	}
+}
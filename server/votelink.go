@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	DEFAULT_VOTE_LINK_TTL = 14 * 24 * time.Hour
+)
+
+var (
+	voteLinkSecret []byte
+	voteLinkTTL    = DEFAULT_VOTE_LINK_TTL
+
+	ErrInvalidVoteLink = errors.New("invalid vote link")
+	ErrExpiredVoteLink = errors.New("expired vote link")
+)
+
+// VoteLink records a single answer for an invitee when opened, without a login.
+// Every field is covered by the signature so links can not be changed to vote for other options or invitees.
+type VoteLink struct {
+	InviteID  int64
+	PollID    string
+	OptionID  string
+	Answer    OptionAnswer
+	ExpiresAt time.Time
+	Nonce     string
+}
+
+func NewVoteLink(invite PollInvite, optionID string, answer OptionAnswer) VoteLink {
+	return VoteLink{
+		InviteID:  invite.ID,
+		PollID:    invite.PollID,
+		OptionID:  optionID,
+		Answer:    answer,
+		ExpiresAt: time.Now().Add(voteLinkTTL).Truncate(time.Second),
+		Nonce:     randomToken(16),
+	}
+}
+
+func (l VoteLink) signature(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		strconv.FormatInt(l.InviteID, 10),
+		l.PollID,
+		l.OptionID,
+		l.Answer,
+		strconv.FormatInt(l.ExpiresAt.Unix(), 10),
+		l.Nonce,
+	}, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l VoteLink) Query(secret []byte) url.Values {
+	return url.Values{
+		"i": {strconv.FormatInt(l.InviteID, 10)},
+		"p": {l.PollID},
+		"o": {l.OptionID},
+		"a": {l.Answer},
+		"e": {strconv.FormatInt(l.ExpiresAt.Unix(), 10)},
+		"n": {l.Nonce},
+		"s": {l.signature(secret)},
+	}
+}
+
+func (l VoteLink) URL(secret []byte) string {
+	return baseURL + "/vote/link?" + l.Query(secret).Encode()
+}
+
+func ParseVoteLink(secret []byte, query url.Values, now time.Time) (VoteLink, error) {
+	inviteID, err := strconv.ParseInt(query.Get("i"), 10, 64)
+	if err != nil {
+		return VoteLink{}, ErrInvalidVoteLink
+	}
+	expiresAt, err := strconv.ParseInt(query.Get("e"), 10, 64)
+	if err != nil {
+		return VoteLink{}, ErrInvalidVoteLink
+	}
+
+	link := VoteLink{
+		InviteID:  inviteID,
+		PollID:    query.Get("p"),
+		OptionID:  query.Get("o"),
+		Answer:    query.Get("a"),
+		ExpiresAt: time.Unix(expiresAt, 0),
+		Nonce:     query.Get("n"),
+	}
+	if link.PollID == "" || link.OptionID == "" || link.Nonce == "" || !slices.Contains(AllOptionAnswer, link.Answer) {
+		return VoteLink{}, ErrInvalidVoteLink
+	}
+
+	if !hmac.Equal([]byte(query.Get("s")), []byte(link.signature(secret))) {
+		return VoteLink{}, ErrInvalidVoteLink
+	}
+
+	if now.After(link.ExpiresAt) {
+		return VoteLink{}, ErrExpiredVoteLink
+	}
+
+	return link, nil
+}
+
+// InviteVoteLinks are the links of an invitee, per option and answer.
+type InviteVoteLinks struct {
+	PollInvite
+	Links   map[string]map[OptionAnswer]string `json:"links"`
+	ReplyTo string                             `json:"reply_to,omitempty"`
+}
+
+// inviteVoteLinks signs a link for each answer allowed in the poll to each option.
+func inviteVoteLinks(poll Poll, answers []OptionAnswer, invite PollInvite) InviteVoteLinks {
+	links := map[string]map[OptionAnswer]string{}
+	for _, option := range poll.Options {
+		links[option.ID] = map[OptionAnswer]string{}
+		for _, answer := range answers {
+			links[option.ID][answer] = NewVoteLink(invite, option.ID, answer).URL(voteLinkSecret)
+		}
+	}
+
+	return InviteVoteLinks{PollInvite: invite, Links: links, ReplyTo: inviteReplyAddress(invite)}
+}
+
+// normalizeInviteEmails lowercases and deduplicates the emails, checking they can be invited to a poll of the workspace.
+func normalizeInviteEmails(emails []string, settings WorkspaceSettings) ([]string, error) {
+	normalized := []string{}
+	for _, email := range emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if !strings.Contains(email, "@") {
+			return nil, errors.New("invalid email " + email)
+		}
+		if !settings.AllowsEmail(email) {
+			return nil, errors.New("email domain not allowed in the workspace " + email)
+		}
+		if !slices.Contains(normalized, email) {
+			normalized = append(normalized, email)
+		}
+	}
+
+	return normalized, nil
+}
+
+type NewPollInvitesRequest struct {
+	Emails []string `json:"emails"`
+}
+
+func (a *APIServer) newPollInvites(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	request := NewPollInvitesRequest{}
+	err := readBody(ctx, &request)
+	if err != nil || len(request.Emails) == 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	workspace, err := a.getPollWorkspace(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	emails, err := normalizeInviteEmails(request.Emails, workspace.Settings)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invites, err := NewPollInvites(ctx, a.db, poll.ID, emails)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": invites})
+}
+
+// listPollInvites returns the invites of the poll, with their vote links and reply address for the editors.
+// Viewers only see who was invited, the links and the reply address let anyone vote as the invitee.
+func (a *APIServer) listPollInvites(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollViewer)
+	if !ok {
+		return
+	}
+
+	role, err := GetPollRole(ctx, a.db, poll.ID, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	invites, err := ListPollInvites(ctx, a.db, poll.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	if !HasPollRole(role, PollEditor) {
+		ctx.JSON(http.StatusOK, gin.H{"data": invites})
+		return
+	}
+
+	answers, err := GetPollAnswers(ctx, a.db, poll)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	invitesWithLinks := []InviteVoteLinks{}
+	for _, invite := range invites {
+		invitesWithLinks = append(invitesWithLinks, inviteVoteLinks(poll, answers, invite))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": invitesWithLinks})
+}
+
+func (a *APIServer) deletePollInvite(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollEditor)
+	if !ok {
+		return
+	}
+
+	inviteID, err := strconv.ParseInt(ctx.Params.ByName("inviteID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter inviteID"})
+		return
+	}
+
+	deleted, err := DeletePollInvite(ctx, a.db, poll.ID, inviteID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": inviteID})
+}
+
+// voteLinkConfirmation asks to confirm the answer of a vote link. Opening the link does not vote, mail scanners and
+// link previews fetch the links of the emails, only submitting the form records the answer.
+var voteLinkConfirmation = template.Must(template.New("vote-link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem;">
+<h1>{{.Title}}</h1>
+<p>Answer <strong>{{.Answer}}</strong> for {{.Option}}?</p>
+<form method="post" action="/vote/link">
+{{- range $name, $values := .Query}}{{range $values}}
+<input type="hidden" name="{{$name}}" value="{{.}}">
+{{- end}}{{end}}
+<button type="submit" autofocus>Confirm</button>
+</form>
+</body>
+</html>
+`))
+
+type voteLinkPage struct {
+	Title  string
+	Option string
+	Answer string
+	Query  url.Values
+}
+
+// readVoteLink checks the signature of the vote link and that its invite still exists.
+func (a *APIServer) readVoteLink(ctx *gin.Context, query url.Values) (VoteLink, PollInvite, bool) {
+	link, err := ParseVoteLink(voteLinkSecret, query, time.Now())
+	if err != nil {
+		logger.Warn("rejected vote link", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrExpiredVoteLink) {
+			status = http.StatusGone
+		}
+		ctx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return VoteLink{}, PollInvite{}, false
+	}
+
+	invite, err := GetPollInvite(ctx, a.db, link.InviteID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return VoteLink{}, PollInvite{}, false
+	}
+	if invite.ID == 0 || invite.PollID != link.PollID {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+		return VoteLink{}, PollInvite{}, false
+	}
+
+	return link, invite, true
+}
+
+// confirmVoteLink shows the answer of a vote link with a form to record it, the link stays unused.
+func (a *APIServer) confirmVoteLink(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	link, _, ok := a.readVoteLink(ctx, query)
+	if !ok {
+		return
+	}
+
+	poll, err := GetPoll(ctx, a.db, link.PollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	optionIdx := slices.IndexFunc(poll.Options, func(option PollOption) bool { return option.ID == link.OptionID })
+	if optionIdx == -1 {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "option not found"})
+		return
+	}
+
+	page := &bytes.Buffer{}
+	err = voteLinkConfirmation.Execute(page, voteLinkPage{
+		Title:  poll.Title,
+		Option: formatOptionRange(poll.Options[optionIdx], time.UTC),
+		Answer: pollPDFAnswerLabels[link.Answer],
+		Query:  query,
+	})
+	if err != nil {
+		logger.Error("failed to render vote link confirmation", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// voteLink records the answer of a signed vote link submitted from the confirmation, each link can only be used once.
+func (a *APIServer) voteLink(ctx *gin.Context) {
+	if err := ctx.Request.ParseForm(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidVoteLink.Error()})
+		return
+	}
+	link, invite, ok := a.readVoteLink(ctx, ctx.Request.PostForm)
+	if !ok {
+		return
+	}
+
+	unused, err := UseVoteLinkNonce(ctx, a.db, link.Nonce, invite.ID, link.ExpiresAt)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !unused {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "vote link already used"})
+		return
+	}
+
+	accountID, err := GetOrCreateAccount(ctx, a.db, Account{
+		Email:    invite.Email,
+		Name:     invite.Email,
+		Username: strings.Split(invite.Email, "@")[0],
+	})
+	if err != nil {
+		ReleaseVoteLinkNonce(ctx, a.db, link.Nonce)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve account"})
+		return
+	}
+
+	_, err = RecordOptionAnswer(ctx, a.db, accountID, link.PollID, link.OptionID, link.Answer)
+	if errors.Is(err, ErrAnswerNotAllowed) {
+		ReleaseVoteLinkNonce(ctx, a.db, link.Nonce)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "answer not allowed in the poll"})
+		return
+	}
+	if err != nil {
+		ReleaseVoteLinkNonce(ctx, a.db, link.Nonce)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/poll/"+link.PollID)
+}
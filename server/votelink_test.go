@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoteLink(t *testing.T) {
+	secret := []byte("secret")
+	invite := PollInvite{ID: 7, PollID: "abc", Email: "jane@example.com"}
+	link := NewVoteLink(invite, "o1", Available)
+
+	parsed, err := ParseVoteLink(secret, link.Query(secret), time.Now())
+	if err != nil {
+		t.Fatalf("Expected vote link to be valid, but got %s", err)
+	}
+	if parsed.InviteID != 7 || parsed.OptionID != "o1" || parsed.Answer != Available || parsed.Nonce != link.Nonce {
+		t.Errorf("Unexpected parsed vote link %+v", parsed)
+	}
+
+	if _, err := ParseVoteLink(secret, link.Query(secret), link.ExpiresAt.Add(time.Second)); !errors.Is(err, ErrExpiredVoteLink) {
+		t.Errorf("Expected expired vote link, but got %v", err)
+	}
+
+	if _, err := ParseVoteLink([]byte("other"), link.Query(secret), time.Now()); !errors.Is(err, ErrInvalidVoteLink) {
+		t.Errorf("Expected vote link signed with another secret to be rejected, but got %v", err)
+	}
+
+	tamperedFields := map[string]string{"i": "8", "o": "o2", "a": Unavailable, "e": "9999999999", "n": "other"}
+	for field, value := range tamperedFields {
+		query := link.Query(secret)
+		query.Set(field, value)
+		if _, err := ParseVoteLink(secret, query, time.Now()); !errors.Is(err, ErrInvalidVoteLink) {
+			t.Errorf("Expected vote link with tampered %s to be rejected, but got %v", field, err)
+		}
+	}
+}
+
+func TestVoteLinkConfirmation(t *testing.T) {
+	secret := []byte("secret")
+	link := NewVoteLink(PollInvite{ID: 7, PollID: "abc", Email: "jane@example.com"}, "o1", Available)
+	query := link.Query(secret)
+
+	page := &bytes.Buffer{}
+	err := voteLinkConfirmation.Execute(page, voteLinkPage{Title: "<Lunch>", Option: "Mon, 01 Jul 2024 12:00 - 13:00 UTC", Answer: "Yes", Query: query})
+	if err != nil {
+		t.Fatalf("Expected the confirmation to be rendered, but got %s", err)
+	}
+
+	html := page.String()
+	if !strings.Contains(html, `<form method="post" action="/vote/link">`) || !strings.Contains(html, "autofocus") {
+		t.Errorf("Expected a form posting the vote link, but got %s", html)
+	}
+	if strings.Contains(html, "<Lunch>") || !strings.Contains(html, "&lt;Lunch&gt;") {
+		t.Errorf("Expected the title to be escaped, but got %s", html)
+	}
+	for name, values := range query {
+		if input := `<input type="hidden" name="` + name + `" value="` + values[0] + `">`; !strings.Contains(html, input) {
+			t.Errorf("Expected the hidden input %s, but got %s", input, html)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"golang.org/x/exp/slices"
+)
+
+var ErrAnswerNotAllowed = errors.New("answer not allowed in the poll")
+
+// GetPollAnswers returns the answers allowed in the poll, its answer scale narrowed by the one of its workspace.
+func GetPollAnswers(ctx context.Context, db *sql.DB, poll Poll) ([]OptionAnswer, error) {
+	if poll.WorkspaceID == 0 {
+		return WorkspaceSettings{}.PollAnswers(poll), nil
+	}
+
+	workspace, err := GetWorkspace(ctx, db, poll.WorkspaceID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace.Settings.PollAnswers(poll), nil
+}
+
+// RecordOptionAnswer changes the answer of a single option, keeping the other answers of the account.
+func RecordOptionAnswer(ctx context.Context, db *sql.DB, accountID int64, pollID string, optionID string, answer OptionAnswer) (PollAccountAvailability, error) {
+	return RecordOptionAnswers(ctx, db, accountID, pollID, []OptionAvailability{{OptionID: optionID, Answer: answer}})
+}
+
+// RecordOptionAnswers changes the answers of some options, keeping the other answers of the account.
+// Answers outside the answer scale of the poll fail with ErrAnswerNotAllowed.
+func RecordOptionAnswers(ctx context.Context, db *sql.DB, accountID int64, pollID string, answers []OptionAvailability) (PollAccountAvailability, error) {
+	poll, err := GetPoll(ctx, db, pollID)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		return PollAccountAvailability{}, fmt.Errorf("poll %s not found", pollID)
+	}
+
+	allowed, err := GetPollAnswers(ctx, db, poll)
+	if err != nil {
+		return PollAccountAvailability{}, err
+	}
+	for _, answer := range answers {
+		if !slices.ContainsFunc(poll.Options, func(option PollOption) bool { return option.ID == answer.OptionID }) {
+			return PollAccountAvailability{}, fmt.Errorf("option %s not found in poll %s", answer.OptionID, pollID)
+		}
+		if !slices.Contains(allowed, answer.Answer) {
+			return PollAccountAvailability{}, fmt.Errorf("%w: %s", ErrAnswerNotAllowed, answer.Answer)
+		}
+	}
+
+	return MergeVote(ctx, db, accountID, pollID, func(availabilities []OptionAvailability) []OptionAvailability {
+		for _, answer := range answers {
+			availabilities = setOptionAnswer(availabilities, answer.OptionID, answer.Answer)
+		}
+		return availabilities
+	})
+}
+
+func setOptionAnswer(availabilities []OptionAvailability, optionID string, answer OptionAnswer) []OptionAvailability {
+	result := []OptionAvailability{}
+	for _, availability := range availabilities {
+		if availability.OptionID != optionID {
+			result = append(result, availability)
+		}
+	}
+
+	return append(result, OptionAvailability{OptionID: optionID, Answer: answer})
+}
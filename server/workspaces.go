@@ -0,0 +1,509 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rtfpessoa/roodle/server/logger"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	WORKSPACE_NAME_MAX_LENGTH = 100
+	WORKSPACE_MAX_POLLS_LIMIT = 100000
+)
+
+// Normalize fills the defaults of the settings and checks they are valid.
+func (s *WorkspaceSettings) Normalize() error {
+	if s.Visibility == "" {
+		s.Visibility = VisibleWithLink
+	}
+	if s.Visibility != VisibleWithLink && s.Visibility != VisibleInWorkspace {
+		return fmt.Errorf("invalid visibility %s", s.Visibility)
+	}
+
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone %s", s.TimeZone)
+		}
+	}
+
+	if err := validateAnswerScale(s.AnswerScale); err != nil {
+		return err
+	}
+
+	for idx, domain := range s.AllowedEmailDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain == "" || strings.ContainsAny(domain, "@ ") {
+			return fmt.Errorf("invalid email domain %s", s.AllowedEmailDomains[idx])
+		}
+		s.AllowedEmailDomains[idx] = domain
+	}
+
+	if s.MaxPolls == 0 {
+		s.MaxPolls = MAX_POLLS_PER_ACCOUNT
+	}
+	if s.MaxPolls < 0 || s.MaxPolls > WORKSPACE_MAX_POLLS_LIMIT {
+		return fmt.Errorf("invalid max polls, the maximum is %d", WORKSPACE_MAX_POLLS_LIMIT)
+	}
+
+	return nil
+}
+
+// validateAnswerScale checks the answers of a scale are known and unique, an empty scale allows every answer.
+func validateAnswerScale(scale []OptionAnswer) error {
+	for idx, answer := range scale {
+		if !slices.Contains(AllOptionAnswer, answer) || slices.Contains(scale[:idx], answer) {
+			return fmt.Errorf("invalid answer %s", answer)
+		}
+	}
+	if len(scale) > 0 && (!slices.Contains(scale, Available) || !slices.Contains(scale, Unavailable)) {
+		return fmt.Errorf("the answer scale must include %s and %s", Available, Unavailable)
+	}
+
+	return nil
+}
+
+// Answers returns the answers allowed in the polls of the workspace.
+func (s WorkspaceSettings) Answers() []OptionAnswer {
+	if len(s.AnswerScale) == 0 {
+		return AllOptionAnswer
+	}
+
+	return s.AnswerScale
+}
+
+// AllowsEmail tells if an email can join the workspace or be invited to its polls.
+func (s WorkspaceSettings) AllowsEmail(email string) bool {
+	if len(s.AllowedEmailDomains) == 0 {
+		return true
+	}
+
+	_, domain, found := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	return found && slices.Contains(s.AllowedEmailDomains, domain)
+}
+
+// ValidatePollAnswerScale checks the answer scale of a poll is valid and within the answers of the workspace,
+// a poll can narrow the answers of its workspace but not add to them.
+func (s WorkspaceSettings) ValidatePollAnswerScale(scale []OptionAnswer) error {
+	if err := validateAnswerScale(scale); err != nil {
+		return err
+	}
+	for _, answer := range scale {
+		if !slices.Contains(s.Answers(), answer) {
+			return fmt.Errorf("answer %s not allowed in the workspace", answer)
+		}
+	}
+
+	return nil
+}
+
+// PollAnswers narrows the answers allowed in the workspace to the answer scale of the poll.
+func (s WorkspaceSettings) PollAnswers(poll Poll) []OptionAnswer {
+	if len(poll.AnswerScale) == 0 {
+		return s.Answers()
+	}
+
+	answers := []OptionAnswer{}
+	for _, answer := range s.Answers() {
+		if slices.Contains(poll.AnswerScale, answer) {
+			answers = append(answers, answer)
+		}
+	}
+
+	return answers
+}
+
+// getWorkspace loads the workspace in the id path parameter, aborting the request
+// when it does not exist or the account does not have the role in it.
+func (a *APIServer) getWorkspace(ctx *gin.Context, accountID int64, minimum WorkspaceRole) (Workspace, bool) {
+	workspaceID, err := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter id"})
+		return Workspace{}, false
+	}
+
+	workspace, err := GetWorkspace(ctx, a.db, workspaceID, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Workspace{}, false
+	}
+	if workspace.ID == 0 || workspace.Role == "" || (minimum == WorkspaceAdmin && workspace.Role != WorkspaceAdmin) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return Workspace{}, false
+	}
+
+	return workspace, true
+}
+
+// getPollWorkspace returns the workspace of the poll, with a zero id for personal polls.
+func (a *APIServer) getPollWorkspace(ctx *gin.Context, poll Poll, accountID int64) (Workspace, error) {
+	if poll.WorkspaceID == 0 {
+		return Workspace{}, nil
+	}
+
+	return GetWorkspace(ctx, a.db, poll.WorkspaceID, accountID)
+}
+
+// canViewPoll applies the visibility of the poll workspace, personal polls are visible to anyone with the link.
+func (a *APIServer) canViewPoll(ctx *gin.Context, poll Poll, accountID int64) (bool, error) {
+	if poll.AccountID == accountID {
+		return true, nil
+	}
+
+	workspace, err := a.getPollWorkspace(ctx, poll, accountID)
+	if err != nil {
+		return false, err
+	}
+	if workspace.ID == 0 || workspace.Settings.Visibility != VisibleInWorkspace || workspace.Role != "" {
+		return true, nil
+	}
+
+	return IsPollParticipant(ctx, a.db, poll, accountID)
+}
+
+// checkPollQuota aborts the request when the workspace, or the personal space when there is no workspace, is full.
+func (a *APIServer) checkPollQuota(ctx *gin.Context, accountID int64, workspaceID int64) bool {
+	if workspaceID == 0 {
+		pollsNumber, err := CountPolls(ctx, a.db, accountID)
+		if err != nil {
+			logger.Error("failed to retrieve poll count", zap.Any("error", err))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "invalid request payload"})
+			return false
+		}
+		if err := pollQuotaError(pollsNumber, Workspace{}); err != nil {
+			logger.Error("Poll limit reached", zap.Int64("accountID", accountID))
+			ctx.AbortWithStatusJSON(http.StatusTeapot, gin.H{"error": err.Error()})
+			return false
+		}
+		return true
+	}
+
+	workspace, err := GetWorkspace(ctx, a.db, workspaceID, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return false
+	}
+	if workspace.ID == 0 || workspace.Role == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return false
+	}
+
+	pollsNumber, err := CountWorkspacePolls(ctx, a.db, workspaceID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return false
+	}
+	if err := pollQuotaError(pollsNumber, workspace); err != nil {
+		logger.Error("Workspace poll limit reached", zap.Int64("workspaceID", workspaceID))
+		ctx.AbortWithStatusJSON(http.StatusTeapot, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// pollQuotaError tells if one more poll, created or restored from the trash, goes over the limit of the workspace,
+// or of the account for the polls out of workspaces.
+func pollQuotaError(pollsNumber int64, workspace Workspace) error {
+	if workspace.ID == 0 {
+		if pollsNumber >= MAX_POLLS_PER_ACCOUNT {
+			return fmt.Errorf("poll limit of %d reached. delete some polls before creating a new one.", MAX_POLLS_PER_ACCOUNT)
+		}
+		return nil
+	}
+
+	if pollsNumber >= workspace.Settings.MaxPolls {
+		return fmt.Errorf("workspace poll limit of %d reached. delete some polls before creating a new one.", workspace.Settings.MaxPolls)
+	}
+	return nil
+}
+
+type WorkspaceRequest struct {
+	Name     string            `json:"name"`
+	Settings WorkspaceSettings `json:"settings"`
+}
+
+func (r *WorkspaceRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > WORKSPACE_NAME_MAX_LENGTH {
+		return fmt.Errorf("invalid name")
+	}
+
+	return r.Settings.Normalize()
+}
+
+func (a *APIServer) newWorkspace(ctx *gin.Context, accountID int64) {
+	request := WorkspaceRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := NewWorkspace(ctx, a.db, accountID, Workspace{Name: request.Name, Settings: request.Settings})
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+func (a *APIServer) listWorkspaces(ctx *gin.Context, accountID int64) {
+	workspaces, err := ListWorkspaces(ctx, a.db, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": workspaces})
+}
+
+func (a *APIServer) getWorkspaceDetails(ctx *gin.Context, accountID int64) {
+	workspace, ok := a.getWorkspace(ctx, accountID, WorkspaceMember)
+	if !ok {
+		return
+	}
+
+	members, err := ListWorkspaceMembers(ctx, a.db, workspace.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"workspace": workspace,
+		"members":   members,
+	}})
+}
+
+func (a *APIServer) updateWorkspace(ctx *gin.Context, accountID int64) {
+	workspace, ok := a.getWorkspace(ctx, accountID, WorkspaceAdmin)
+	if !ok {
+		return
+	}
+
+	request := WorkspaceRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace.Name = request.Name
+	workspace.Settings = request.Settings
+	err = UpdateWorkspace(ctx, a.db, workspace)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+func (a *APIServer) deleteWorkspace(ctx *gin.Context, accountID int64) {
+	workspace, ok := a.getWorkspace(ctx, accountID, WorkspaceAdmin)
+	if !ok {
+		return
+	}
+
+	err := DeleteWorkspace(ctx, a.db, workspace.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+type WorkspaceMemberRequest struct {
+	Email string        `json:"email"`
+	Role  WorkspaceRole `json:"role"`
+}
+
+// checkLastAdmin aborts the request when it would leave the workspace without admins.
+func (a *APIServer) checkLastAdmin(ctx *gin.Context, workspaceID int64, memberID int64) bool {
+	members, err := ListWorkspaceMembers(ctx, a.db, workspaceID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return false
+	}
+
+	for _, member := range members {
+		if member.Role == WorkspaceAdmin && member.AccountID != memberID {
+			return true
+		}
+	}
+
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the workspace needs at least one admin"})
+	return false
+}
+
+func (a *APIServer) setWorkspaceMember(ctx *gin.Context, accountID int64) {
+	workspace, ok := a.getWorkspace(ctx, accountID, WorkspaceAdmin)
+	if !ok {
+		return
+	}
+
+	request := WorkspaceMemberRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+	if request.Role != WorkspaceAdmin && request.Role != WorkspaceMember {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+		return
+	}
+	if !workspace.Settings.AllowsEmail(request.Email) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "email domain not allowed in the workspace"})
+		return
+	}
+
+	memberID, ok := a.getMemberAccount(ctx, request.Email)
+	if !ok {
+		return
+	}
+	if request.Role != WorkspaceAdmin && !a.checkLastAdmin(ctx, workspace.ID, memberID) {
+		return
+	}
+
+	err = SetWorkspaceMember(ctx, a.db, workspace.ID, memberID, request.Role)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": WorkspaceMembership{AccountID: memberID, AccountEmail: request.Email, Role: request.Role}})
+}
+
+// deleteWorkspaceMember removes a member from the workspace, either by an admin or by the member leaving it.
+func (a *APIServer) deleteWorkspaceMember(ctx *gin.Context, accountID int64) {
+	memberID, err := strconv.ParseInt(ctx.Params.ByName("accountID"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid parameter accountID"})
+		return
+	}
+
+	minimum := WorkspaceAdmin
+	if memberID == accountID {
+		minimum = WorkspaceMember
+	}
+	workspace, ok := a.getWorkspace(ctx, accountID, minimum)
+	if !ok {
+		return
+	}
+	if !a.checkLastAdmin(ctx, workspace.ID, memberID) {
+		return
+	}
+
+	deleted, err := DeleteWorkspaceMember(ctx, a.db, workspace.ID, memberID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+	if !deleted {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": nil})
+}
+
+func (a *APIServer) listWorkspacePolls(ctx *gin.Context, accountID int64) {
+	workspace, ok := a.getWorkspace(ctx, accountID, WorkspaceMember)
+	if !ok {
+		return
+	}
+
+	polls, err := ListWorkspacePolls(ctx, a.db, workspace.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": polls})
+}
+
+type PollWorkspaceRequest struct {
+	WorkspaceID int64 `json:"workspace_id"`
+}
+
+// setPollWorkspace moves a poll into one of the workspaces of its owner, or back to its personal polls.
+func (a *APIServer) setPollWorkspace(ctx *gin.Context, accountID int64) {
+	poll, ok := a.getManagedPoll(ctx, accountID, PollOwner)
+	if !ok {
+		return
+	}
+
+	request := PollWorkspaceRequest{}
+	err := readBody(ctx, &request)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if request.WorkspaceID == poll.WorkspaceID {
+		ctx.JSON(http.StatusOK, gin.H{"data": poll})
+		return
+	}
+
+	if !a.checkPollQuota(ctx, accountID, request.WorkspaceID) {
+		return
+	}
+
+	err = SetPollWorkspace(ctx, a.db, accountID, poll.ID, request.WorkspaceID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return
+	}
+
+	poll.WorkspaceID = request.WorkspaceID
+	ctx.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// getPollAnswers returns the answers allowed in the poll, restricted by its answer scale and the one of its workspace.
+func (a *APIServer) getPollAnswers(ctx *gin.Context, pollID string, accountID int64) (Poll, []OptionAnswer, bool) {
+	poll, err := GetPoll(ctx, a.db, pollID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, nil, false
+	}
+	if reflect.ValueOf(poll).IsZero() {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, nil, false
+	}
+
+	visible, err := a.canViewPoll(ctx, poll, accountID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, nil, false
+	}
+	if !visible {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "poll not found"})
+		return Poll{}, nil, false
+	}
+
+	answers, err := GetPollAnswers(ctx, a.db, poll)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+		return Poll{}, nil, false
+	}
+
+	return poll, answers, true
+}
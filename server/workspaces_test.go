@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaceSettingsNormalize(t *testing.T) {
+	settings := WorkspaceSettings{AllowedEmailDomains: []string{" @Example.com "}}
+	if err := settings.Normalize(); err != nil {
+		t.Fatalf("Expected settings to be valid, but got %s", err)
+	}
+	if settings.Visibility != VisibleWithLink || settings.MaxPolls != MAX_POLLS_PER_ACCOUNT {
+		t.Errorf("Expected default visibility and quota, but got %+v", settings)
+	}
+	if !reflect.DeepEqual(settings.AllowedEmailDomains, []string{"example.com"}) {
+		t.Errorf("Expected normalized domains, but got %v", settings.AllowedEmailDomains)
+	}
+
+	testCases := []WorkspaceSettings{
+		{Visibility: "secret"},
+		{TimeZone: "Mars/Olympus"},
+		{AnswerScale: []OptionAnswer{Available, "perhaps"}},
+		{AnswerScale: []OptionAnswer{Available, Maybe}},
+		{AnswerScale: []OptionAnswer{Available, Unavailable, Available}},
+		{AllowedEmailDomains: []string{"jane@example.com"}},
+		{MaxPolls: -1},
+		{MaxPolls: WORKSPACE_MAX_POLLS_LIMIT + 1},
+	}
+	for _, settings := range testCases {
+		if err := settings.Normalize(); err == nil {
+			t.Errorf("Expected %+v to be invalid", settings)
+		}
+	}
+}
+
+func TestWorkspaceSettingsAnswers(t *testing.T) {
+	if answers := (WorkspaceSettings{}).Answers(); !reflect.DeepEqual(answers, AllOptionAnswer) {
+		t.Errorf("Expected all answers, but got %v", answers)
+	}
+
+	scale := []OptionAnswer{Available, Unavailable}
+	if answers := (WorkspaceSettings{AnswerScale: scale}).Answers(); !reflect.DeepEqual(answers, scale) {
+		t.Errorf("Expected %v, but got %v", scale, answers)
+	}
+}
+
+func TestWorkspaceSettingsPollAnswers(t *testing.T) {
+	poll := Poll{AnswerScale: []OptionAnswer{Available, Unavailable}}
+	if answers := (WorkspaceSettings{}).PollAnswers(poll); !reflect.DeepEqual(answers, poll.AnswerScale) {
+		t.Errorf("Expected %v, but got %v", poll.AnswerScale, answers)
+	}
+
+	settings := WorkspaceSettings{AnswerScale: []OptionAnswer{Unavailable, Available}}
+	if answers := settings.PollAnswers(Poll{AnswerScale: AllOptionAnswer}); !reflect.DeepEqual(answers, settings.AnswerScale) {
+		t.Errorf("Expected %v, but got %v", settings.AnswerScale, answers)
+	}
+
+	if answers := settings.PollAnswers(Poll{}); !reflect.DeepEqual(answers, settings.AnswerScale) {
+		t.Errorf("Expected %v, but got %v", settings.AnswerScale, answers)
+	}
+}
+
+func TestWorkspaceSettingsValidatePollAnswerScale(t *testing.T) {
+	settings := WorkspaceSettings{AnswerScale: []OptionAnswer{Available, Unavailable}}
+
+	testCases := []struct {
+		settings WorkspaceSettings
+		scale    []OptionAnswer
+		valid    bool
+	}{
+		{settings, nil, true},
+		{settings, []OptionAnswer{Unavailable, Available}, true},
+		{settings, AllOptionAnswer, false},
+		{settings, []OptionAnswer{Available, "perhaps"}, false},
+		{WorkspaceSettings{}, AllOptionAnswer, true},
+		{WorkspaceSettings{}, []OptionAnswer{Available, Maybe}, false},
+	}
+
+	for _, testCase := range testCases {
+		if err := testCase.settings.ValidatePollAnswerScale(testCase.scale); (err == nil) != testCase.valid {
+			t.Errorf("Expected %v in %v to be valid %v, but got %v", testCase.scale, testCase.settings.AnswerScale, testCase.valid, err)
+		}
+	}
+}
+
+func TestWorkspaceSettingsAllowsEmail(t *testing.T) {
+	settings := WorkspaceSettings{AllowedEmailDomains: []string{"example.com"}}
+
+	testCases := map[string]bool{
+		"jane@example.com":     true,
+		" John@EXAMPLE.com ":   true,
+		"jane@sub.example.com": false,
+		"jane@example.org":     false,
+		"example.com":          false,
+	}
+	for email, expected := range testCases {
+		if allowed := settings.AllowsEmail(email); allowed != expected {
+			t.Errorf("Expected %q to be allowed %v, but got %v", email, expected, allowed)
+		}
+	}
+
+	if !(WorkspaceSettings{}).AllowsEmail("anyone@anywhere.test") {
+		t.Errorf("Expected any email to be allowed without domains")
+	}
+}
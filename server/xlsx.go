@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XLSX_SHEET_NAME_MAX_LENGTH is the longest sheet name spreadsheet applications accept.
+const XLSX_SHEET_NAME_MAX_LENGTH = 31
+
+var (
+	xlsxStaticParts = []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+	}
+)
+
+// XLSXCell is a cell of a sheet, the value is written as text unless the cell is a number,
+// so names and answers that look like numbers keep their leading zeros.
+type XLSXCell struct {
+	Value  string
+	Number bool
+}
+
+// XLSXTextRow makes a row of text cells.
+func XLSXTextRow(values []string) []XLSXCell {
+	row := []XLSXCell{}
+	for _, value := range values {
+		row = append(row, XLSXCell{Value: value})
+	}
+
+	return row
+}
+
+// WriteXLSX writes a workbook with a single sheet holding the rows.
+// Only the parts required by spreadsheet applications are written, the strings are inlined instead of shared.
+func WriteXLSX(w io.Writer, sheetName string, rows [][]XLSXCell) error {
+	archive := zip.NewWriter(w)
+
+	for _, part := range xlsxStaticParts {
+		if err := writeZipFile(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(xlsxSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+	if err := writeZipFile(archive, "xl/workbook.xml", workbook); err != nil {
+		return err
+	}
+
+	sheet := &strings.Builder{}
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for rowIdx, row := range rows {
+		sheet.WriteString(`<row r="` + strconv.Itoa(rowIdx+1) + `">`)
+		for colIdx, cell := range row {
+			ref := xlsxColumn(colIdx) + strconv.Itoa(rowIdx+1)
+			if cell.Number {
+				sheet.WriteString(`<c r="` + ref + `"><v>` + xmlEscape(cell.Value) + `</v></c>`)
+				continue
+			}
+			sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(cell.Value) + `</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if err := writeZipFile(archive, "xl/worksheets/sheet1.xml", sheet.String()); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func writeZipFile(archive *zip.Writer, name string, content string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(file, content)
+	return err
+}
+
+// xlsxColumn returns the letters of the zero based column index, "A" to "Z", then "AA" and so on.
+func xlsxColumn(idx int) string {
+	column := ""
+	for idx >= 0 {
+		column = string(rune('A'+idx%26)) + column
+		idx = idx/26 - 1
+	}
+
+	return column
+}
+
+// xlsxSheetName removes the characters not allowed in sheet names and shortens the name to the maximum length.
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Sheet1"
+	}
+
+	runes := []rune(name)
+	if len(runes) > XLSX_SHEET_NAME_MAX_LENGTH {
+		return string(runes[:XLSX_SHEET_NAME_MAX_LENGTH])
+	}
+
+	return name
+}
+
+func xmlEscape(value string) string {
+	escaped := &strings.Builder{}
+	xml.EscapeText(escaped, []byte(value))
+	return escaped.String()
+}
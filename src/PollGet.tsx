@@ -198,7 +198,9 @@ function PollGet() {
         ...(answers.get(availability.option_id) ?? []),
         {
           answer: availability.answer,
-          email: pollAccountAvailability.account_email,
+          email: pollAccountAvailability.offline_id
+            ? `${pollAccountAvailability.participant_name} (recorded by ${pollAccountAvailability.recorded_by || "an organizer"})`
+            : pollAccountAvailability.account_email,
         },
       ]);
     });
@@ -1,5 +1,5 @@
 import { useEffect, useState } from "react";
-import { Poll, PollListResponse } from "./models";
+import { PollListResponse, PollSummary } from "./models";
 import { useNavigate } from "react-router-dom";
 import {
   Group,
@@ -60,7 +60,7 @@ export function ClipboardIcon(props: React.ComponentPropsWithoutRef<"svg">) {
 }
 
 function PollList() {
-  const [polls, setPolls] = useState<Poll[]>([]);
+  const [polls, setPolls] = useState<PollSummary[]>([]);
   const navigate = useNavigate();
 
   useEffect(() => {
@@ -89,7 +89,7 @@ function PollList() {
                   {poll.title || poll.id}
                 </Text>
                 <Text mt="xs" mb="md">
-                  {poll.options.length} options
+                  {poll.option_count} options
                 </Text>
                 <Group wrap="nowrap" gap={0}>
                   <Button
@@ -137,7 +137,7 @@ function PollList() {
   );
 }
 
-async function listPolls(): Promise<Poll[]> {
+async function listPolls(): Promise<PollSummary[]> {
   var response = await fetch("/api/v1/poll", {
     method: "GET",
     headers: {
@@ -152,17 +152,10 @@ async function listPolls(): Promise<Poll[]> {
   const polls: PollListResponse = await response.json();
   return polls.data.map((poll) => {
     return {
-      id: poll.id,
-      title: poll.title,
-      description: poll.description,
-      location: poll.location,
-      options: poll.options.map((option) => {
-        return {
-          id: option.id,
-          start: new Date(option.start),
-          end: new Date(option.end),
-        };
-      }),
+      ...poll,
+      starts_at: poll.starts_at ? new Date(poll.starts_at) : undefined,
+      ends_at: poll.ends_at ? new Date(poll.ends_at) : undefined,
+      created_at: new Date(poll.created_at),
     };
   });
 }
@@ -289,32 +289,25 @@ export const handlers = [
         data: [
           {
             id: "asdas8d7asn",
-            options: [
-              {
-                id: "ojhbgd6d98",
-                start: date1S,
-                end: date1E,
-              },
-            ],
+            title: "",
+            location: "",
+            role: "owner",
+            option_count: 1,
+            starts_at: date1S,
+            ends_at: date1E,
+            participants: 2,
+            created_at: date2S,
           },
           {
             id: "asasasa",
             title: "My nice title",
-            description:
-              "Such a great and nice description for my amazing event",
             location: "Machado's house",
-            options: [
-              {
-                id: "bhjgh675",
-                start: date1S,
-                end: date1E,
-              },
-              {
-                id: "assass06",
-                start: date2S,
-                end: date2E,
-              },
-            ],
+            role: "participant",
+            option_count: 2,
+            starts_at: date2S,
+            ends_at: date1E,
+            participants: 1,
+            created_at: date2S,
           },
         ],
       })
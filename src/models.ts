@@ -1,5 +1,20 @@
 export type PollListResponse = {
-  data: Poll[];
+  data: PollSummary[];
+  next?: string;
+};
+
+export type PollSummary = {
+  id: string;
+  title: string;
+  location: string;
+  role: string;
+  final_option_id?: string;
+  workspace_id?: number;
+  option_count: number;
+  starts_at?: Date;
+  ends_at?: Date;
+  participants: number;
+  created_at: Date;
 };
 
 export type Poll = {
@@ -38,6 +53,9 @@ export type PollAccountAvailability = {
   account_id: number;
   account_email: string;
   availabilities: Answer[];
+  offline_id?: number;
+  participant_name?: string;
+  recorded_by?: string;
 };
 
 export type Answer = {